@@ -0,0 +1,88 @@
+package application
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/catalog-service/repository/product"
+	"github.com/redis/go-redis/v9"
+)
+
+type App struct {
+	router http.Handler
+	rdb    *redis.Client
+	config Config
+}
+
+func New(config Config) (*App, error) {
+	// Các API sửa catalog chỉ dành cho admin nên bắt buộc phải có secret
+	if config.JwtSecret == "" {
+		return nil, errors.New("missing JWT_SECRET_KEY")
+	}
+
+	app := &App{
+		rdb: redis.NewClient(&redis.Options{
+			Addr:     config.RedisAddress,
+			Username: config.Username,
+			Password: config.Password,
+		}),
+		config: config,
+	}
+
+	app.loadRoutes()
+
+	return app, nil
+}
+
+func (a *App) Start(ctx context.Context) error {
+	// Khởi tạo HTTP server với port lấy từ config và gán router làm handler
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", a.config.ServerPort),
+		Handler: a.router,
+	}
+
+	err := a.rdb.Ping(ctx).Err()
+	if err != nil {
+		return fmt.Errorf("failed to connect to redis: %w", err)
+	}
+
+	// Dữ liệu của phiên bản trước liệt kê sản phẩm bằng set "products", chuyển sang index mới
+	if err := (&product.RedisRepo{Client: a.rdb}).MigrateIndex(ctx); err != nil {
+		return err
+	}
+
+	// Đảm bảo đóng kết nối Redis khi hàm Start() kết thúc
+	defer func() {
+		if err := a.rdb.Close(); err != nil {
+			fmt.Println("failed to close redis", err)
+		}
+	}()
+
+	fmt.Println("Starting server")
+
+	// Tạo channel để nhận lỗi nếu server khởi động thất bại
+	ch := make(chan error, 1)
+
+	// chạy server trong goroutine, tránh chặn luồng chính
+	go func() {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			ch <- fmt.Errorf("failed to start server: %w", err)
+		}
+		close(ch)
+	}()
+
+	// Chờ server lỗi hoặc context bị hủy để tắt server an toàn
+	select {
+	case err = <-ch:
+		return err
+	case <-ctx.Done():
+		timeout, cancel := context.WithTimeout(context.Background(), time.Second*10)
+		defer cancel()
+
+		return server.Shutdown(timeout)
+	}
+}
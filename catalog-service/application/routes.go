@@ -0,0 +1,54 @@
+package application
+
+import (
+	"net/http"
+
+	"github.com/RibunLoc/microservices-learn/catalog-service/handler"
+	"github.com/RibunLoc/microservices-learn/catalog-service/repository/product"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// Dùng để khởi tạo và cấu hình các routes chính cho ứng dụng
+func (a *App) loadRoutes() {
+	router := chi.NewRouter()
+
+	// Ghi log cho tất cả request - ghi lại method, URL, thời gian xử lý
+	router.Use(middleware.Logger)
+
+	// Định nghĩa endpoint "/" kiểm tra app đang chạy
+	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	// Gắn nhóm route con /products
+	router.Route("/products", a.loadProductRoutes)
+
+	a.router = router
+}
+
+// định nghĩa các route con bên trong /products
+func (a *App) loadProductRoutes(router chi.Router) {
+	productHandler := &handler.Product{
+		Repo: &product.RedisRepo{
+			Client: a.rdb,
+		},
+	}
+
+	// Đọc catalog không cần đăng nhập, order-service gọi GET /products/{id} khi tạo đơn
+	router.Get("/", productHandler.List)              // Danh sách sản phẩm
+	router.Get("/{id}", productHandler.GetByID)       // Sản phẩm theo id
+	router.Get("/sku/{sku}", productHandler.GetBySKU) // Sản phẩm theo SKU
+
+	// Sửa catalog chỉ dành cho admin
+	router.Group(func(router chi.Router) {
+		router.Use(handler.RequireAdmin(a.config.JwtSecret))
+
+		router.Post("/", productHandler.Create)                              // Tạo sản phẩm
+		router.Put("/{id}", productHandler.UpdateByID)                       // Sửa sản phẩm
+		router.Delete("/{id}", productHandler.DeleteByID)                    // Xóa sản phẩm
+		router.Put("/{id}/prices/{currency}", productHandler.SetPrice)       // Thêm / sửa giá theo tiền tệ
+		router.Delete("/{id}/prices/{currency}", productHandler.DeletePrice) // Xóa giá theo tiền tệ
+	})
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Role của admin trong JWT do user-service cấp
+const roleAdmin = "admin"
+
+// RequireAdmin chỉ cho request có JWT hợp lệ của admin đi tiếp.
+// JWT được ký bằng HS256 với secret chung với user-service.
+func RequireAdmin(secret string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// 1. Lấy token từ header "Authorization: Bearer <token>"
+			tokenStr, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || tokenStr == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="catalog-service"`)
+				http.Error(w, "missing bearer token", http.StatusUnauthorized)
+				return
+			}
+
+			// 2. Kiểm tra chữ ký và hạn của token
+			role, err := parseRole(tokenStr, secret)
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="catalog-service", error="invalid_token"`)
+				http.Error(w, err.Error(), http.StatusUnauthorized)
+				return
+			}
+
+			// 3. Chỉ admin được sửa catalog
+			if role != roleAdmin {
+				http.Error(w, "admin role required", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// parseRole xác thực token và trả về claim "role"
+func parseRole(tokenStr, secret string) (string, error) {
+	token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
+		return []byte(secret), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil {
+		return "", err
+	}
+
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return "", errors.New("invalid token claims")
+	}
+	role, _ := claims["role"].(string)
+	return role, nil
+}
@@ -0,0 +1,289 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/catalog-service/model"
+	"github.com/RibunLoc/microservices-learn/catalog-service/repository/product"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Product là HTTP handler quản lý sản phẩm, SKU và giá trong catalog
+type Product struct {
+	Repo *product.RedisRepo
+}
+
+// Dữ liệu client gửi lên khi tạo hoặc sửa sản phẩm
+type productBody struct {
+	SKU         string        `json:"sku"`
+	Name        string        `json:"name"`
+	Description string        `json:"description"`
+	Prices      []model.Price `json:"prices"`
+	Status      model.Status  `json:"status"`
+}
+
+// writeJSON mã hóa v thành JSON và ghi response với status code
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Println("failed to marshal: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// parseProductID lấy tham số "id" từ URL path
+func parseProductID(r *http.Request) (uuid.UUID, error) {
+	return uuid.Parse(chi.URLParam(r, "id"))
+}
+
+// writeRepoError chuyển lỗi của repository thành status code
+func writeRepoError(w http.ResponseWriter, action string, err error) {
+	if errors.Is(err, product.ErrNotExist) {
+		w.WriteHeader(http.StatusNotFound)
+	} else if errors.Is(err, product.ErrSKUExists) || errors.Is(err, product.ErrConflict) {
+		http.Error(w, err.Error(), http.StatusConflict)
+	} else {
+		fmt.Printf("failed to %s: %v\n", action, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// Create là HTTP handler tạo sản phẩm mới (POST /products)
+func (h *Product) Create(w http.ResponseWriter, r *http.Request) {
+	var body productBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now().UTC()
+	newProduct := model.Product{
+		ProductID:   uuid.New(),
+		SKU:         body.SKU,
+		Name:        body.Name,
+		Description: body.Description,
+		Prices:      body.Prices,
+		Status:      body.Status,
+		Version:     1,
+		CreatedAt:   &now,
+	}
+	if err := newProduct.Normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Repo.Insert(r.Context(), newProduct); err != nil {
+		writeRepoError(w, "insert", err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, newProduct)
+}
+
+// Giới hạn số sản phẩm mỗi trang khi liệt kê
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+/*
+List là HTTP handler liệt kê sản phẩm (GET /products?limit=...&cursor=...) theo thứ tự product ID.
+Response có has_more và next, gửi lại next trong ?cursor=... để lấy trang sau.
+Trang sau bắt đầu ngay sau sản phẩm cuối của trang trước nên không bị lặp hay bỏ sót
+sản phẩm khi có sản phẩm được thêm hoặc xóa giữa hai lần gọi.
+*/
+func (h *Product) List(w http.ResponseWriter, r *http.Request) {
+	page := product.FindAllPage{Size: defaultListLimit}
+
+	// Số sản phẩm mỗi trang, từ 1 đến maxListLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || limit == 0 || limit > maxListLimit {
+			http.Error(w, fmt.Sprintf("invalid limit: must be between 1 and %d", maxListLimit), http.StatusBadRequest)
+			return
+		}
+		page.Size = limit
+	}
+
+	// Cursor là ID của sản phẩm cuối trang trước
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		after, err := uuid.Parse(v)
+		if err != nil {
+			http.Error(w, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		page.After = after
+	}
+
+	res, err := h.Repo.FindAll(r.Context(), page)
+	if err != nil {
+		fmt.Println("failed to find all: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	var response struct {
+		Items   []model.Product `json:"items"`          // Danh sách sản phẩm
+		Next    string          `json:"next,omitempty"` // Cursor tiếp theo, dùng để lấy trang kế tiếp
+		HasMore bool            `json:"has_more"`       // Còn trang sau hay không
+	}
+	response.Items = res.Products
+	if res.Next != uuid.Nil {
+		response.HasMore = true
+		response.Next = res.Next.String()
+	}
+
+	writeJSON(w, http.StatusOK, response)
+}
+
+// GetByID là HTTP handler lấy sản phẩm theo ID (GET /products/{id}),
+// order-service dùng endpoint này để lấy giá và tên khi tạo đơn
+func (h *Product) GetByID(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProductID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	p, err := h.Repo.FindByID(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, "find by id", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// GetBySKU là HTTP handler lấy sản phẩm theo SKU (GET /products/sku/{sku})
+func (h *Product) GetBySKU(w http.ResponseWriter, r *http.Request) {
+	p, err := h.Repo.FindBySKU(r.Context(), chi.URLParam(r, "sku"))
+	if err != nil {
+		writeRepoError(w, "find by sku", err)
+		return
+	}
+	writeJSON(w, http.StatusOK, p)
+}
+
+// UpdateByID là HTTP handler sửa toàn bộ thông tin sản phẩm (PUT /products/{id})
+func (h *Product) UpdateByID(w http.ResponseWriter, r *http.Request) {
+	var body productBody
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	h.update(w, r, func(p *model.Product) error {
+		p.SKU = body.SKU
+		p.Name = body.Name
+		p.Description = body.Description
+		p.Prices = body.Prices
+		p.Status = body.Status
+		return nil
+	})
+}
+
+// SetPrice là HTTP handler thêm hoặc sửa giá theo một tiền tệ
+// (PUT /products/{id}/prices/{currency}, body {"amount": 1999})
+func (h *Product) SetPrice(w http.ResponseWriter, r *http.Request) {
+	currency, err := model.ParseCurrency(chi.URLParam(r, "currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Amount *uint64 `json:"amount"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Amount == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	h.update(w, r, func(p *model.Product) error {
+		p.SetPrice(model.Price{Amount: *body.Amount, Currency: currency})
+		return nil
+	})
+}
+
+// DeletePrice là HTTP handler xóa giá theo một tiền tệ (DELETE /products/{id}/prices/{currency})
+func (h *Product) DeletePrice(w http.ResponseWriter, r *http.Request) {
+	currency, err := model.ParseCurrency(chi.URLParam(r, "currency"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.update(w, r, func(p *model.Product) error {
+		if !p.RemovePrice(currency) {
+			return product.ErrNotExist
+		}
+		return nil
+	})
+}
+
+/*
+update là các bước chung khi sửa sản phẩm:
+ 1. Đọc sản phẩm hiện tại
+ 2. Gọi edit để sửa rồi chuẩn hóa, kiểm tra dữ liệu
+ 3. Ghi với version mới, bị sửa đồng thời thì trả về 409
+*/
+func (h *Product) update(w http.ResponseWriter, r *http.Request, edit func(p *model.Product) error) {
+	id, err := parseProductID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// 1. Đọc sản phẩm
+	p, err := h.Repo.FindByID(r.Context(), id)
+	if err != nil {
+		writeRepoError(w, "find by id", err)
+		return
+	}
+
+	// 2. Sửa và kiểm tra dữ liệu
+	if err := edit(&p); err != nil {
+		writeRepoError(w, "edit", err)
+		return
+	}
+	if err := p.Normalize(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// 3. Ghi sản phẩm với version mới
+	now := time.Now().UTC()
+	p.UpdatedAt = &now
+	p.Version++
+	if err := h.Repo.Update(r.Context(), p); err != nil {
+		writeRepoError(w, "update", err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, p)
+}
+
+// DeleteByID là HTTP handler xóa sản phẩm (DELETE /products/{id}).
+// Muốn ngừng bán nhưng vẫn giữ sản phẩm thì đổi status sang "discontinued".
+func (h *Product) DeleteByID(w http.ResponseWriter, r *http.Request) {
+	id, err := parseProductID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if err := h.Repo.DeleteByID(r.Context(), id); err != nil {
+		writeRepoError(w, "delete", err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
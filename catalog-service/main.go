@@ -0,0 +1,26 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/RibunLoc/microservices-learn/catalog-service/application"
+)
+
+func main() {
+	app, err := application.New(application.LoadConfig()) // Khởi tạo cấu hình cho server
+	if err != nil {
+		fmt.Println("failed to create app:", err)
+		return
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt) // kiểm tra ngắt đột ngột
+	defer cancel()
+
+	err = app.Start(ctx) // Chạy Server
+	if err != nil {
+		fmt.Println("failed to start app:", err)
+	}
+}
@@ -0,0 +1,123 @@
+package model
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Trạng thái bán của sản phẩm
+type Status string
+
+const (
+	StatusActive       Status = "active"       // đang bán, order-service được đặt hàng
+	StatusDiscontinued Status = "discontinued" // ngừng bán, order-service từ chối đặt hàng
+)
+
+// Price là giá bán theo đơn vị nhỏ nhất của tiền tệ (minor units) kèm mã ISO 4217,
+// cùng định dạng với Money bên order-service
+type Price struct {
+	Amount   uint64 `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+/*
+Product là một mặt hàng trong catalog:
+  - ProductID chính là item_id trong line item của order-service
+  - SKU là mã hàng do người bán đặt, không trùng giữa các sản phẩm
+  - Prices có tối đa một giá cho mỗi tiền tệ
+*/
+type Product struct {
+	ProductID   uuid.UUID  `json:"product_id"`
+	SKU         string     `json:"sku"`
+	Name        string     `json:"name"`
+	Description string     `json:"description,omitempty"`
+	Prices      []Price    `json:"prices"`
+	Status      Status     `json:"status"`
+	Version     uint64     `json:"version"` // tăng 1 sau mỗi lần cập nhật
+	CreatedAt   *time.Time `json:"created_at"`
+	UpdatedAt   *time.Time `json:"updated_at,omitempty"`
+}
+
+// Các lỗi khi dữ liệu sản phẩm không hợp lệ
+var (
+	ErrInvalidSKU      = errors.New("sku is required")
+	ErrInvalidName     = errors.New("name is required")
+	ErrInvalidStatus   = errors.New("status must be active or discontinued")
+	ErrInvalidCurrency = errors.New("currency must be a 3-letter ISO 4217 code")
+	ErrDuplicatePrice  = errors.New("only one price per currency is allowed")
+)
+
+// ParseCurrency kiểm tra mã tiền tệ có dạng 3 chữ cái và chuẩn hóa về chữ hoa
+func ParseCurrency(s string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	if len(code) != 3 {
+		return "", ErrInvalidCurrency
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return "", ErrInvalidCurrency
+		}
+	}
+	return code, nil
+}
+
+// Normalize chuẩn hóa SKU, mã tiền tệ và gán trạng thái mặc định rồi kiểm tra dữ liệu
+func (p *Product) Normalize() error {
+	p.SKU = strings.TrimSpace(p.SKU)
+	p.Name = strings.TrimSpace(p.Name)
+	if p.SKU == "" {
+		return ErrInvalidSKU
+	}
+	if p.Name == "" {
+		return ErrInvalidName
+	}
+
+	if p.Status == "" {
+		p.Status = StatusActive
+	}
+	if p.Status != StatusActive && p.Status != StatusDiscontinued {
+		return ErrInvalidStatus
+	}
+
+	if p.Prices == nil {
+		p.Prices = []Price{}
+	}
+	seen := make(map[string]bool, len(p.Prices))
+	for i := range p.Prices {
+		code, err := ParseCurrency(p.Prices[i].Currency)
+		if err != nil {
+			return err
+		}
+		if seen[code] {
+			return ErrDuplicatePrice
+		}
+		seen[code] = true
+		p.Prices[i].Currency = code
+	}
+	return nil
+}
+
+// SetPrice thêm hoặc thay giá của một tiền tệ
+func (p *Product) SetPrice(price Price) {
+	for i := range p.Prices {
+		if p.Prices[i].Currency == price.Currency {
+			p.Prices[i].Amount = price.Amount
+			return
+		}
+	}
+	p.Prices = append(p.Prices, price)
+}
+
+// RemovePrice xóa giá của một tiền tệ, trả về false nếu sản phẩm không có giá đó
+func (p *Product) RemovePrice(currency string) bool {
+	for i := range p.Prices {
+		if p.Prices[i].Currency == currency {
+			p.Prices = append(p.Prices[:i], p.Prices[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,307 @@
+package product
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/RibunLoc/microservices-learn/catalog-service/model"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Dùng để báo lỗi khi không tìm thấy sản phẩm
+var ErrNotExist = errors.New("product does not exist")
+
+// Dùng để báo lỗi khi SKU đã thuộc về sản phẩm khác
+var ErrSKUExists = errors.New("sku already exists")
+
+// Dùng để báo lỗi khi sản phẩm bị request khác sửa trong lúc đang ghi
+var ErrConflict = errors.New("product was modified concurrently")
+
+/*
+RedisRepo lưu sản phẩm trong Redis:
+  - "product:{id}": JSON của sản phẩm
+  - "product:sku:{sku}": product ID đang giữ SKU, đảm bảo SKU không trùng
+  - "products:by_id": sorted set các product ID (cùng score 0, sắp theo thứ tự chữ),
+    dùng để liệt kê theo keyset: trang sau bắt đầu ngay sau ID cuối của trang trước
+*/
+type RedisRepo struct {
+	Client *redis.Client // Redis client từ go-redis
+}
+
+// Sorted set các product ID dùng để liệt kê
+const productIndexKey = "products:by_id"
+
+// Set "products" chứa key sản phẩm của phiên bản trước, được chuyển sang productIndexKey
+// bởi MigrateIndex
+const legacyProductsKey = "products"
+
+// Tạo key Redis dạng: "product:{uuid}"
+func productIDKey(id uuid.UUID) string {
+	return fmt.Sprintf("product:%s", id)
+}
+
+// Tạo key Redis dạng: "product:sku:{sku}"
+func skuKey(sku string) string {
+	return fmt.Sprintf("product:sku:%s", sku)
+}
+
+// getProduct đọc và giải mã sản phẩm, dùng được cả trong WATCH
+func getProduct(ctx context.Context, c redis.Cmdable, key string) (model.Product, error) {
+	value, err := c.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return model.Product{}, ErrNotExist
+	} else if err != nil {
+		return model.Product{}, fmt.Errorf("get product: %w", err)
+	}
+
+	var product model.Product
+	if err := json.Unmarshal([]byte(value), &product); err != nil {
+		return model.Product{}, fmt.Errorf("failed to decode product json: %w", err)
+	}
+	return product, nil
+}
+
+// Insert lưu sản phẩm mới, trả về ErrSKUExists nếu SKU đã có sản phẩm khác dùng
+func (r *RedisRepo) Insert(ctx context.Context, product model.Product) error {
+	// 1. Mã hóa sản phẩm thành JSON
+	data, err := json.Marshal(product)
+	if err != nil {
+		return fmt.Errorf("failed to encode product: %w", err)
+	}
+
+	key := productIDKey(product.ProductID)
+	sku := skuKey(product.SKU)
+
+	// 2. WATCH key SKU để hai request cùng SKU không cùng ghi được
+	err = r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		n, err := tx.Exists(ctx, sku).Result()
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			return ErrSKUExists
+		}
+
+		// 3. Ghi sản phẩm, key SKU và index trong cùng MULTI/EXEC
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, string(data), 0)
+			pipe.Set(ctx, sku, product.ProductID.String(), 0)
+			pipe.ZAdd(ctx, productIndexKey, redis.Z{Member: product.ProductID.String()})
+			return nil
+		})
+		return err
+	}, sku)
+	if errors.Is(err, ErrSKUExists) {
+		return err
+	} else if errors.Is(err, redis.TxFailedErr) {
+		return ErrConflict
+	} else if err != nil {
+		return fmt.Errorf("failed to exec: %w", err)
+	}
+	return nil
+}
+
+// FindByID lấy sản phẩm theo ID
+func (r *RedisRepo) FindByID(ctx context.Context, id uuid.UUID) (model.Product, error) {
+	return getProduct(ctx, r.Client, productIDKey(id))
+}
+
+// FindBySKU lấy sản phẩm theo SKU
+func (r *RedisRepo) FindBySKU(ctx context.Context, sku string) (model.Product, error) {
+	value, err := r.Client.Get(ctx, skuKey(sku)).Result()
+	if errors.Is(err, redis.Nil) {
+		return model.Product{}, ErrNotExist
+	} else if err != nil {
+		return model.Product{}, fmt.Errorf("get sku: %w", err)
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return model.Product{}, fmt.Errorf("invalid product id for sku %s: %w", sku, err)
+	}
+	return r.FindByID(ctx, id)
+}
+
+/*
+Update ghi đè sản phẩm đã tồn tại. product.Version phải lớn hơn version đang lưu
+đúng 1 đơn vị, nếu không trả về ErrConflict. Khi đổi SKU thì key SKU cũ được xóa
+và SKU mới phải chưa thuộc về sản phẩm khác.
+*/
+func (r *RedisRepo) Update(ctx context.Context, product model.Product) error {
+	data, err := json.Marshal(product)
+	if err != nil {
+		return fmt.Errorf("failed to encode product: %w", err)
+	}
+
+	key := productIDKey(product.ProductID)
+	sku := skuKey(product.SKU)
+
+	err = r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// 1. Sản phẩm phải tồn tại và chưa bị sửa sau lần đọc
+		old, err := getProduct(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if old.Version+1 != product.Version {
+			return ErrConflict
+		}
+
+		// 2. SKU mới không được thuộc về sản phẩm khác
+		if old.SKU != product.SKU {
+			n, err := tx.Exists(ctx, sku).Result()
+			if err != nil {
+				return err
+			}
+			if n > 0 {
+				return ErrSKUExists
+			}
+		}
+
+		// 3. Ghi sản phẩm và chuyển key SKU trong cùng MULTI/EXEC
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetXX(ctx, key, string(data), 0)
+			if old.SKU != product.SKU {
+				pipe.Del(ctx, skuKey(old.SKU))
+				pipe.Set(ctx, sku, product.ProductID.String(), 0)
+			}
+			return nil
+		})
+		return err
+	}, key, sku)
+	if errors.Is(err, ErrNotExist) || errors.Is(err, ErrSKUExists) || errors.Is(err, ErrConflict) {
+		return err
+	} else if errors.Is(err, redis.TxFailedErr) {
+		return ErrConflict
+	} else if err != nil {
+		return fmt.Errorf("failed to exec: %w", err)
+	}
+	return nil
+}
+
+// DeleteByID xóa sản phẩm cùng key SKU của nó
+func (r *RedisRepo) DeleteByID(ctx context.Context, id uuid.UUID) error {
+	key := productIDKey(id)
+
+	err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		old, err := getProduct(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key)
+			pipe.Del(ctx, skuKey(old.SKU))
+			pipe.ZRem(ctx, productIndexKey, id.String())
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, ErrNotExist) {
+		return err
+	} else if errors.Is(err, redis.TxFailedErr) {
+		return ErrConflict
+	} else if err != nil {
+		return fmt.Errorf("failed to exec: %w", err)
+	}
+	return nil
+}
+
+// Định nghĩa thông tin phân trang: lấy bao nhiêu phần tử (Size),
+// bắt đầu ngay sau sản phẩm nào (After, rỗng là trang đầu)
+type FindAllPage struct {
+	Size  uint64
+	After uuid.UUID
+}
+
+// Kết quả trả về khi truy vấn: danh sách sản phẩm + ID để lấy trang sau,
+// Next là uuid.Nil nếu đã hết
+type FindResult struct {
+	Products []model.Product
+	Next     uuid.UUID
+}
+
+// FindAll lấy danh sách sản phẩm theo thứ tự product ID (phân trang bằng keyset)
+func (r *RedisRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
+	// 1. Lấy Size+1 ID sau page.After để biết còn trang sau hay không
+	start := "-"
+	if page.After != uuid.Nil {
+		start = "(" + page.After.String()
+	}
+	ids, err := r.Client.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key:   productIndexKey,
+		Start: start,
+		Stop:  "+",
+		ByLex: true,
+		Count: int64(page.Size) + 1,
+	}).Result()
+	if err != nil {
+		return FindResult{}, fmt.Errorf("failed to get product ids: %w", err)
+	}
+	var res FindResult
+	if uint64(len(ids)) > page.Size {
+		ids = ids[:page.Size]
+		if res.Next, err = uuid.Parse(ids[len(ids)-1]); err != nil {
+			return FindResult{}, fmt.Errorf("invalid product id in index: %w", err)
+		}
+	}
+	res.Products = make([]model.Product, 0, len(ids))
+	if len(ids) == 0 {
+		return res, nil
+	}
+
+	// 2. Lấy dữ liệu của các key cùng lúc bằng MGET
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = "product:" + id
+	}
+	xs, err := r.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return FindResult{}, fmt.Errorf("failed to get products: %w", err)
+	}
+
+	// 3. Giải mã từng sản phẩm, bỏ qua key vừa bị xóa
+	for _, x := range xs {
+		value, ok := x.(string)
+		if !ok {
+			continue
+		}
+		var product model.Product
+		if err := json.Unmarshal([]byte(value), &product); err != nil {
+			return FindResult{}, fmt.Errorf("failed to decode product json: %w", err)
+		}
+		res.Products = append(res.Products, product)
+	}
+	return res, nil
+}
+
+// MigrateIndex chuyển set "products" (key sản phẩm) của phiên bản trước sang
+// sorted set productIndexKey rồi xóa set cũ, không có set cũ thì không làm gì.
+// Gọi lúc khởi động, chạy lại nhiều lần vẫn an toàn.
+func (r *RedisRepo) MigrateIndex(ctx context.Context) error {
+	keys, err := r.Client.SMembers(ctx, legacyProductsKey).Result()
+	if err != nil {
+		return fmt.Errorf("failed to read legacy product set: %w", err)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	members := make([]redis.Z, 0, len(keys))
+	for _, key := range keys {
+		members = append(members, redis.Z{Member: strings.TrimPrefix(key, "product:")})
+	}
+	_, err = r.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, productIndexKey, members...)
+		pipe.Del(ctx, legacyProductsKey)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to migrate product index: %w", err)
+	}
+	fmt.Printf("migrated %d products to %s\n", len(members), productIndexKey)
+	return nil
+}
@@ -2,32 +2,385 @@ package application
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	_ "embed"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
+	"os"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/catalog"
+	"github.com/RibunLoc/microservices-learn/customer"
+	"github.com/RibunLoc/microservices-learn/handler"
+	"github.com/RibunLoc/microservices-learn/idempotency"
+	"github.com/RibunLoc/microservices-learn/idgen"
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/payment"
+	"github.com/RibunLoc/microservices-learn/pricing"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/RibunLoc/microservices-learn/retention"
+	"github.com/RibunLoc/microservices-learn/saga"
+	"github.com/RibunLoc/microservices-learn/shared/openapi"
 	"github.com/redis/go-redis/v9"
+	"google.golang.org/grpc"
+	_ "modernc.org/sqlite" // driver "sqlite" cho backend sql
 )
 
 type App struct {
-	router http.Handler
-	rdb    *redis.Client
-	config Config
+	router   http.Handler
+	grpc     *grpc.Server   // OrderService cho các service nội bộ
+	orders   *handler.Order // handler đơn hàng dùng chung cho REST và gRPC
+	rdb      *redis.Client  // chỉ khác nil khi dùng backend redis
+	db       *sql.DB        // chỉ khác nil khi dùng backend sql
+	repo     order.OrderRepository
+	idgen    idgen.Generator
+	idem     idempotency.Store
+	users    *customer.UserServiceClient // nil nếu không cấu hình USER_SERVICE_ADDR
+	items    *catalog.HTTPClient         // nil nếu không cấu hình CATALOG_SERVICE_ADDR
+	stock    *inventory.RedisStore       // nil nếu tắt INVENTORY_ENABLED
+	sweeper  *inventory.Sweeper          // hủy đơn có reservation hết hạn, nil nếu tắt
+	sagas    *saga.Orchestrator          // nil nếu tắt SAGA_ENABLED
+	payments payment.Provider            // nil nếu không cấu hình PAYMENT_PROVIDER
+	archive  *retention.Archive          // nil nếu tắt RETENTION_ENABLED
+	retainer *retention.Sweeper          // chuyển đơn hoàn tất lâu sang archive, nil nếu tắt
+	auth     *auth.Verifier
+	spec     *openapi.Spec // tài liệu OpenAPI, dùng để kiểm tra request
+	cursors  []byte        // khóa ký cursor phân trang
+	prices   pricing.Calculator
+	currency string // tiền tệ mặc định của đơn hàng
+	config   Config
 }
 
-func New(config Config) *App {
+// Tài liệu OpenAPI 3 của REST API, sửa route hay body của handler thì cập nhật file này
+//
+//go:embed openapi.json
+var openapiDocument []byte
+
+func New(config Config) (*App, error) {
 	app := &App{
-		rdb: redis.NewClient(&redis.Options{
+		config: config,
+	}
+
+	// Mọi route /orders đều yêu cầu JWT do user-service cấp
+	var publicKey []byte
+	if config.JwtPublicKeyFile != "" {
+		data, err := os.ReadFile(config.JwtPublicKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read jwt public key: %w", err)
+		}
+		publicKey = data
+	}
+	verifier, err := auth.NewVerifier(config.JwtSecret, publicKey)
+	if err != nil {
+		return nil, fmt.Errorf("missing JWT_SECRET_KEY or JWT_PUBLIC_KEY_FILE: %w", err)
+	}
+	app.auth = verifier
+
+	// Khóa ký cursor: CURSOR_SECRET, không có thì dẫn xuất từ JWT secret
+	// (không dùng thẳng khóa ký token), cuối cùng là khóa ngẫu nhiên
+	// (cursor hết hiệu lực khi khởi động lại)
+	switch {
+	case config.CursorSecret != "":
+		app.cursors = []byte(config.CursorSecret)
+	case config.JwtSecret != "":
+		app.cursors = deriveKey(config.JwtSecret, "order-cursor")
+	default:
+		app.cursors = make([]byte, 32)
+		if _, err := rand.Read(app.cursors); err != nil {
+			return nil, fmt.Errorf("failed to generate cursor key: %w", err)
+		}
+		fmt.Println("CURSOR_SECRET is not set, list cursors are only valid on this instance until restart")
+	}
+
+	// Chọn backend lưu trữ đơn hàng theo cấu hình
+	switch config.StorageBackend {
+	case StorageRedis:
+		app.rdb = redis.NewClient(&redis.Options{
 			Addr:     config.RedisAddress,
 			Username: config.Username,
 			Password: config.Password,
-		}),
-		config: config,
+		})
+		app.repo = &order.RedisRepo{
+			Client: app.rdb,
+			Stream: config.EventsStream,
+			MaxLen: config.EventsMaxLen,
+		}
+		app.idem = &idempotency.RedisStore{
+			Client: app.rdb,
+			TTL:    config.IdempotencyTTL,
+		}
+	case StorageMemory:
+		app.repo = order.NewMemoryRepo()
+		app.idem = idempotency.NewMemoryStore(config.IdempotencyTTL)
+	case StorageSQL:
+		db, err := sql.Open(config.SQLDriver, config.SQLDSN)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		app.db = db
+		app.repo = &order.SQLRepo{
+			DB: db,
+		}
+		// Idempotency-Key chỉ cần giữ trong thời gian ngắn nên lưu trong bộ nhớ
+		app.idem = idempotency.NewMemoryStore(config.IdempotencyTTL)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %q", config.StorageBackend)
+	}
+
+	// Chọn bộ sinh order ID: mặc định dùng INCR của Redis nếu có Redis,
+	// các backend khác dùng snowflake
+	generator := config.IDGenerator
+	if generator == "" {
+		generator = IDGeneratorSnowflake
+		if app.rdb != nil {
+			generator = IDGeneratorRedis
+		}
+	}
+
+	switch generator {
+	case IDGeneratorRedis:
+		if app.rdb == nil {
+			return nil, fmt.Errorf("id generator %q requires the redis storage backend", generator)
+		}
+		app.idgen = &idgen.RedisSequence{
+			Client: app.rdb,
+		}
+	case IDGeneratorSnowflake:
+		nodeID, err := parseNodeID(config.NodeID)
+		if err != nil {
+			return nil, err
+		}
+		sf, err := idgen.NewSnowflake(nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create snowflake generator: %w", err)
+		}
+		app.idgen = sf
+	default:
+		return nil, fmt.Errorf("unknown id generator: %q", generator)
+	}
+
+	// Kiểm tra khách hàng qua user-service nếu có cấu hình địa chỉ
+	if config.UserServiceAddr != "" {
+		if config.UserServicePolicy != FailureClosed && config.UserServicePolicy != FailureOpen {
+			return nil, fmt.Errorf("unknown user-service failure policy: %q", config.UserServicePolicy)
+		}
+		users, err := customer.NewUserServiceClient(config.UserServiceAddr)
+		if err != nil {
+			return nil, err
+		}
+		users.Timeout = config.UserServiceTimeout
+		users.FailOpen = config.UserServicePolicy == FailureOpen
+		app.users = users
+	}
+
+	// Lấy giá và tên mặt hàng từ catalog-service nếu có cấu hình địa chỉ
+	if config.CatalogServiceAddr != "" {
+		app.items = &catalog.HTTPClient{
+			BaseURL: config.CatalogServiceAddr,
+			Client:  &http.Client{Timeout: config.CatalogServiceTimeout},
+		}
+	}
+
+	// Giữ chỗ hàng trong kho bằng Lua script nên cần Redis
+	if config.InventoryEnabled {
+		if app.rdb == nil {
+			return nil, fmt.Errorf("inventory reservation requires the redis storage backend")
+		}
+		app.stock = &inventory.RedisStore{
+			Client: app.rdb,
+			TTL:    config.InventoryReservationTTL,
+		}
+		app.sweeper = &inventory.Sweeper{
+			Store:    app.stock,
+			Orders:   app.repo,
+			Interval: config.InventorySweepInterval,
+		}
+	}
+
+	// Chọn cổng thanh toán, hiện chỉ có cổng thanh toán giả để phát triển và kiểm thử
+	switch config.PaymentProvider {
+	case "":
+	case "fake":
+		fake, err := payment.NewFake(payment.Behavior(config.PaymentFakeBehavior))
+		if err != nil {
+			return nil, err
+		}
+		fake.Delay = config.PaymentFakeDelay
+		app.payments = fake
+	default:
+		return nil, fmt.Errorf("unknown payment provider: %q", config.PaymentProvider)
+	}
+
+	// Saga đặt đơn lưu trạng thái trong Redis để chạy tiếp sau khi khởi động lại
+	if config.SagaEnabled {
+		if app.rdb == nil {
+			return nil, fmt.Errorf("order saga requires the redis storage backend")
+		}
+		app.sagas = &saga.Orchestrator{
+			Store:          &saga.RedisStore{Client: app.rdb},
+			Orders:         app.repo,
+			Payments:       app.payments,
+			ResumeInterval: config.SagaResumeInterval,
+		}
+		// Gán riêng để interface không nhận con trỏ nil khi tắt
+		if app.users != nil {
+			app.sagas.Customers = app.users
+		}
+		if app.stock != nil {
+			app.sagas.Inventory = app.stock
+		}
+	}
+
+	// Đơn hoàn tất lâu được chuyển từ Redis sang file lưu trữ trên đĩa
+	if config.RetentionEnabled {
+		if app.rdb == nil {
+			return nil, fmt.Errorf("order retention requires the redis storage backend")
+		}
+		app.archive = &retention.Archive{
+			Dir:    config.RetentionDir,
+			Client: app.rdb,
+		}
+		app.retainer = &retention.Sweeper{
+			Orders:   app.repo.(*order.RedisRepo),
+			Archive:  app.archive,
+			After:    config.RetentionAfter,
+			Interval: config.RetentionInterval,
+		}
+	}
+
+	// Tiền tệ mặc định, phí vận chuyển không ghi tiền tệ cũng tính theo tiền tệ này
+	if app.currency, err = model.ParseCurrency(config.DefaultCurrency); err != nil {
+		return nil, fmt.Errorf("invalid default currency %q: %w", config.DefaultCurrency, err)
+	}
+
+	// Bộ tính thuế: mặt hàng miễn thuế được xét trước thuế suất theo khu vực
+	exempt, err := pricing.ParseExemptItems(config.TaxExemptItems)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := pricing.ParseFlatRates(config.TaxRates)
+	if err != nil {
+		return nil, err
+	}
+	shippingFees, err := pricing.ParseAmounts(config.ShippingFee, app.currency)
+	if err != nil {
+		return nil, err
+	}
+	freeShipping, err := pricing.ParseAmounts(config.FreeShippingFrom, app.currency)
+	if err != nil {
+		return nil, err
+	}
+	app.prices = pricing.Calculator{
+		Tax:              pricing.TaxEngine{Rules: append([]pricing.Rule{exempt}, rates...)},
+		ShippingFees:     shippingFees,
+		FreeShippingFrom: freeShipping,
+	}
+
+	// Bảng tỷ giá để báo cáo tổng tiền theo tiền tệ gốc
+	if config.ExchangeRatesFile != "" {
+		if app.prices.Rates, err = pricing.LoadExchangeRates(config.ExchangeRatesFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Tài liệu OpenAPI nhúng trong binary, request được kiểm tra theo tài liệu trước khi tới handler
+	if app.spec, err = openapi.Load(openapiDocument); err != nil {
+		return nil, err
 	}
 
 	app.loadRoutes()
+	app.loadGRPC()
+
+	return app, nil
+}
+
+/*
+parseNodeID đọc NODE_ID cho bộ sinh snowflake:
+  - Giá trị không phải số nguyên không dấu thì báo lỗi thay vì lặng lẽ dùng node 0
+  - Để trống thì dùng node 0 và cảnh báo, vì nhiều instance cùng node ID có thể sinh trùng order ID
+*/
+func parseNodeID(value string) (uint16, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		fmt.Println("NODE_ID is not set, using snowflake node 0: instances sharing a node id can generate duplicate order ids")
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 16)
+	if err != nil {
+		return 0, fmt.Errorf("invalid NODE_ID %q: %w", value, err)
+	}
+	return uint16(id), nil
+}
+
+// deriveKey tạo khóa con HMAC-SHA256(secret, label) để một secret dùng được cho nhiều mục đích
+// mà chữ ký của mục đích này không dùng được cho mục đích khác
+func deriveKey(secret, label string) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(label))
+	return mac.Sum(nil)
+}
+
+// connect kiểm tra kết nối tới backend đã chọn trước khi nhận request
+func (a *App) connect(ctx context.Context) error {
+	if a.rdb != nil {
+		if err := a.rdb.Ping(ctx).Err(); err != nil {
+			return fmt.Errorf("failed to connect to redis: %w", err)
+		}
+
+		// Dữ liệu tạo trước khi có index phụ thì dựng lại index một lần
+		repo := a.repo.(*order.RedisRepo)
+		needed, err := repo.NeedsReindex(ctx)
+		if err != nil {
+			return err
+		}
+		if needed {
+			fmt.Println("Rebuilding order indexes")
+			if err := repo.Reindex(ctx); err != nil {
+				return err
+			}
+		}
+	}
+
+	if a.db != nil {
+		if err := a.db.PingContext(ctx); err != nil {
+			return fmt.Errorf("failed to connect to database: %w", err)
+		}
+		if err := a.repo.(*order.SQLRepo).Migrate(ctx); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// close giải phóng kết nối tới backend khi server dừng
+func (a *App) close() {
+	if a.rdb != nil {
+		if err := a.rdb.Close(); err != nil {
+			fmt.Println("failed to close redis", err)
+		}
+	}
 
-	return app
+	if a.db != nil {
+		if err := a.db.Close(); err != nil {
+			fmt.Println("failed to close database", err)
+		}
+	}
+
+	if a.users != nil {
+		if err := a.users.Close(); err != nil {
+			fmt.Println("failed to close user-service connection", err)
+		}
+	}
 }
 
 func (a *App) Start(ctx context.Context) error {
@@ -37,47 +390,83 @@ func (a *App) Start(ctx context.Context) error {
 		Handler: a.router,
 	}
 
-	err := a.rdb.Ping(ctx).Err()
+	err := a.connect(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to connect to redis: %w", err)
+		return err
 	}
 
 	// defer: trì hoãn thực thi cho dến khi hàm kết thúc
-	// Đảm bảo đóng két nối Redis khi hàm Start() kết thúc
+	// Đảm bảo đóng két nối Redis / database khi hàm Start() kết thúc
 	// giúp giải phóng tài nguyên và tránh rò rỉ kết nối.
-	defer func() {
-		if err := a.rdb.Close(); err != nil {
-			fmt.Println("failed to close redis", err)
-		}
-	}()
+	defer a.close()
+
+	// gRPC server cho các service nội bộ, chạy song song với REST API
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", a.config.GRPCPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen grpc: %w", err)
+	}
+
+	// Quét reservation hết hạn cho đến khi server dừng
+	if a.sweeper != nil {
+		go a.sweeper.Run(ctx)
+	}
+
+	// Chạy tiếp các saga bị bỏ dở khi service dừng lần trước
+	if a.sagas != nil {
+		go a.sagas.Run(ctx)
+	}
+
+	// Lưu trữ đơn hoàn tất lâu cho đến khi server dừng
+	if a.retainer != nil {
+		go a.retainer.Run(ctx)
+	}
 
 	fmt.Println("Starting server")
 
-	// Tạo channel để nhận lỗi nếu server khởi động thất bại
-	ch := make(chan error, 1)
+	// Tạo channel để nhận lỗi nếu một trong hai server khởi động thất bại
+	ch := make(chan error, 2)
 
 	// chạy server trong goroutine, tránh chặn luồng chính
 	// (giống chạy bất đồng bộ, không block)
 	go func() {
-		err = server.ListenAndServe()
-		if err != nil {
+		err := server.ListenAndServe()
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			ch <- fmt.Errorf("failded to start server: %w", err)
 		}
-		close(ch)
+	}()
+
+	go func() {
+		err := a.grpc.Serve(lis)
+		if err != nil {
+			ch <- fmt.Errorf("failed to start grpc server: %w", err)
+		}
 	}()
 
 	// Dùng select để:
 	// - Bắt lỗi từ goroutine nếu server chưa crash
-	// - Hoặc bắt tín hiệu hủy từ context để tắt server an toàn
+	// - Hoặc bắt tín hiệu hủy từ context để tắt cả hai server an toàn
 	select {
 	case err = <-ch:
+		a.grpc.Stop()
+		server.Close()
 		return err
 	case <-ctx.Done():
 		timeout, cancel := context.WithTimeout(context.Background(), time.Second*10)
 		defer cancel()
 
-		return server.Shutdown(timeout)
+		// GracefulStop chờ các RPC đang chạy (kể cả stream ListOrders) xong,
+		// quá thời gian chờ thì ngắt hẳn giống server HTTP
+		stopped := make(chan struct{})
+		go func() {
+			a.grpc.GracefulStop()
+			close(stopped)
+		}()
+		err := server.Shutdown(timeout)
+		select {
+		case <-stopped:
+		case <-timeout.Done():
+			a.grpc.Stop()
+		}
+		return err
 	}
-
-	return nil
 }
@@ -3,25 +3,123 @@ package application
 import (
 	"os"
 	"strconv"
+	"time"
 
+	"github.com/RibunLoc/microservices-learn/catalog"
+	"github.com/RibunLoc/microservices-learn/customer"
+	"github.com/RibunLoc/microservices-learn/events"
+	"github.com/RibunLoc/microservices-learn/idempotency"
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/RibunLoc/microservices-learn/payment"
+	"github.com/RibunLoc/microservices-learn/retention"
+	"github.com/RibunLoc/microservices-learn/saga"
 	"github.com/joho/godotenv"
 )
 
+// Các backend lưu trữ đơn hàng có thể chọn qua biến môi trường STORAGE_BACKEND
+const (
+	StorageRedis  = "redis"  // mặc định, lưu vào Redis
+	StorageMemory = "memory" // lưu trong bộ nhớ, dùng khi test / chạy thử
+	StorageSQL    = "sql"    // lưu vào database SQL (mặc định là SQLite)
+)
+
+// Các bộ sinh order ID có thể chọn qua biến môi trường ID_GENERATOR
+const (
+	IDGeneratorRedis     = "redis"     // bộ đếm INCR trong Redis, chỉ dùng với backend redis
+	IDGeneratorSnowflake = "snowflake" // ID theo thời gian, dùng khi chạy nhiều instance
+)
+
+// Cách xử lý khi không gọi được user-service, chọn qua USER_SERVICE_FAILURE_POLICY
+const (
+	FailureClosed = "closed" // mặc định, từ chối tạo đơn (503)
+	FailureOpen   = "open"   // bỏ qua bước kiểm tra, vẫn cho tạo đơn
+)
+
 type Config struct {
-	RedisAddress string // địa chỉ redis server
-	Username     string // tên user login redis
-	Password     string // mật khẩu login
-	ServerPort   uint16 // cổng lắng nghe của backend
+	RedisAddress   string        // địa chỉ redis server
+	Username       string        // tên user login redis
+	Password       string        // mật khẩu login
+	EventsStream   string        // Redis Stream nhận event đơn hàng (chỉ với backend redis)
+	EventsMaxLen   int64         // số event tối đa giữ lại trong stream, cắt gần đúng
+	ServerPort     uint16        // cổng lắng nghe của backend
+	GRPCPort       uint16        // cổng lắng nghe gRPC cho các service nội bộ
+	StorageBackend string        // backend lưu đơn hàng: redis, memory hoặc sql
+	SQLDriver      string        // tên driver database/sql khi dùng backend sql
+	SQLDSN         string        // chuỗi kết nối database khi dùng backend sql
+	IDGenerator    string        // bộ sinh order ID, để trống sẽ chọn theo backend
+	NodeID         string        // node ID của instance khi dùng snowflake (0-1023), để trống là 0
+	IdempotencyTTL time.Duration // thời gian giữ response của Idempotency-Key
+
+	UserServiceAddr    string        // địa chỉ gRPC của user-service, để trống thì không kiểm tra khách hàng
+	UserServiceTimeout time.Duration // thời gian chờ mỗi lần gọi user-service
+	UserServicePolicy  string        // xử lý khi user-service lỗi: closed hoặc open
+
+	CatalogServiceAddr    string        // URL của catalog-service, để trống thì chỉ admin được nhập giá
+	CatalogServiceTimeout time.Duration // thời gian chờ mỗi lần gọi catalog-service
+
+	InventoryEnabled        bool          // giữ chỗ hàng trong kho khi tạo đơn, chỉ dùng với backend redis
+	InventoryReservationTTL time.Duration // thời gian giữ chỗ của đơn chưa xác nhận
+	InventorySweepInterval  time.Duration // chu kỳ quét reservation hết hạn
+
+	SagaEnabled        bool          // đặt đơn bằng saga lưu trong Redis, chỉ dùng với backend redis
+	SagaResumeInterval time.Duration // chu kỳ chạy tiếp các saga bị bỏ dở
+
+	PaymentProvider     string        // cổng thanh toán: "fake" hoặc để trống để tắt thanh toán
+	PaymentFakeBehavior string        // cách cổng thanh toán giả phản hồi: approve, decline hoặc timeout
+	PaymentFakeDelay    time.Duration // thời gian cổng thanh toán giả treo khi giả lập timeout
+
+	RetentionEnabled  bool          // chuyển đơn hoàn tất lâu từ Redis sang file lưu trữ, chỉ dùng với backend redis
+	RetentionAfter    time.Duration // thời gian giữ đơn trong Redis sau khi hoàn tất
+	RetentionDir      string        // thư mục chứa file lưu trữ (.ndjson.gz theo tháng)
+	RetentionInterval time.Duration // chu kỳ quét đơn cần lưu trữ
+
+	JwtSecret        string // secret chung với user-service để kiểm tra JWT (HS256)
+	JwtPublicKeyFile string // file PEM public key nếu user-service ký JWT bằng khóa bất đối xứng
+
+	CursorSecret string // khóa ký cursor phân trang, để trống thì dẫn xuất từ JwtSecret
+
+	DefaultCurrency   string // tiền tệ của đơn khi client không gửi currency (ISO 4217)
+	ExchangeRatesFile string // file JSON tỷ giá để báo cáo tổng tiền theo tiền tệ gốc, để trống là tắt
+	TaxRates          string // thuế suất theo khu vực, ví dụ "VN=10,US-CA=7.25,*=0"
+	TaxExemptItems    string // danh sách item_id miễn thuế, cách nhau bởi dấu phẩy
+	ShippingFee       string // phí vận chuyển mỗi đơn (minor units), ví dụ "30000" hoặc "VND=30000,USD=500"
+	FreeShippingFrom  string // tiền hàng từ mức này được miễn phí vận chuyển, cùng định dạng với ShippingFee, 0 là không miễn
 }
 
 func LoadConfig() Config {
 	_ = godotenv.Load()
 	// tạo mơi config với cấu hình mặc định
 	cfg := Config{
-		RedisAddress: "",
-		Username:     "",
-		Password:     "",
-		ServerPort:   3000,
+		RedisAddress:   "",
+		Username:       "",
+		Password:       "",
+		ServerPort:     3000,
+		GRPCPort:       50052, // user-service dùng 50051
+		StorageBackend: StorageRedis,
+		SQLDriver:      "sqlite",
+		SQLDSN:         "file:orders.db?_pragma=busy_timeout(5000)", // chờ khóa thay vì lỗi ngay khi ghi đồng thời
+		EventsStream:   events.DefaultStream,
+		EventsMaxLen:   events.DefaultMaxLen,
+		IdempotencyTTL: idempotency.DefaultTTL,
+
+		UserServiceTimeout: customer.DefaultTimeout,
+		UserServicePolicy:  FailureClosed,
+
+		CatalogServiceTimeout: catalog.DefaultTimeout,
+
+		InventoryReservationTTL: inventory.DefaultTTL,
+		InventorySweepInterval:  inventory.DefaultSweepInterval,
+
+		SagaResumeInterval: saga.DefaultResumeInterval,
+
+		PaymentFakeBehavior: string(payment.BehaviorApprove),
+		PaymentFakeDelay:    payment.DefaultFakeDelay,
+
+		RetentionAfter:    retention.DefaultAfter,
+		RetentionDir:      retention.DefaultDir,
+		RetentionInterval: retention.DefaultInterval,
+
+		DefaultCurrency: "VND",
 	}
 
 	// Kiểm tra biến môi trường với REDIS_ADDR có tồn tại hay không
@@ -39,6 +137,18 @@ func LoadConfig() Config {
 		cfg.Password = redisPass
 	}
 
+	// Kiểm tra biến môi trường EVENTS_STREAM
+	if stream, exist := os.LookupEnv("EVENTS_STREAM"); exist {
+		cfg.EventsStream = stream
+	}
+
+	// Kiểm tra biến môi trường EVENTS_MAX_LEN (số event giữ lại trong stream)
+	if maxLen, exist := os.LookupEnv("EVENTS_MAX_LEN"); exist {
+		if n, err := strconv.ParseInt(maxLen, 10, 64); err == nil && n > 0 {
+			cfg.EventsMaxLen = n
+		}
+	}
+
 	// Kiểm tra biến môi trường SERVER_PORT
 	if serverPort, exist := os.LookupEnv("SERVER_PORT"); exist {
 		// Chuyển kiểu dạng số về chuỗi (string)
@@ -47,5 +157,177 @@ func LoadConfig() Config {
 		}
 	}
 
+	// Kiểm tra biến môi trường GRPC_PORT
+	if grpcPort, exist := os.LookupEnv("GRPC_PORT"); exist {
+		if port, err := strconv.ParseUint(grpcPort, 10, 16); err == nil {
+			cfg.GRPCPort = uint16(port)
+		}
+	}
+
+	// Kiểm tra biến môi trường STORAGE_BACKEND để chọn nơi lưu đơn hàng
+	if backend, exist := os.LookupEnv("STORAGE_BACKEND"); exist {
+		cfg.StorageBackend = backend
+	}
+
+	if sqlDriver, exist := os.LookupEnv("SQL_DRIVER"); exist {
+		cfg.SQLDriver = sqlDriver
+	}
+
+	if sqlDSN, exist := os.LookupEnv("SQL_DSN"); exist {
+		cfg.SQLDSN = sqlDSN
+	}
+
+	// Kiểm tra biến môi trường ID_GENERATOR để chọn bộ sinh order ID
+	if generator, exist := os.LookupEnv("ID_GENERATOR"); exist {
+		cfg.IDGenerator = generator
+	}
+
+	// NODE_ID được kiểm tra khi tạo bộ sinh snowflake, giá trị sai sẽ không được bỏ qua
+	if nodeID, exist := os.LookupEnv("NODE_ID"); exist {
+		cfg.NodeID = nodeID
+	}
+
+	// Kiểm tra biến môi trường IDEMPOTENCY_TTL (ví dụ: "24h", "30m")
+	if ttl, exist := os.LookupEnv("IDEMPOTENCY_TTL"); exist {
+		if d, err := time.ParseDuration(ttl); err == nil {
+			cfg.IdempotencyTTL = d
+		}
+	}
+
+	// Kiểm tra các biến môi trường cấu hình gọi user-service
+	if userAddr, exist := os.LookupEnv("USER_SERVICE_ADDR"); exist {
+		cfg.UserServiceAddr = userAddr
+	}
+
+	if timeout, exist := os.LookupEnv("USER_SERVICE_TIMEOUT"); exist {
+		if d, err := time.ParseDuration(timeout); err == nil {
+			cfg.UserServiceTimeout = d
+		}
+	}
+
+	if policy, exist := os.LookupEnv("USER_SERVICE_FAILURE_POLICY"); exist {
+		cfg.UserServicePolicy = policy
+	}
+
+	// Kiểm tra các biến môi trường cấu hình gọi catalog-service
+	if catalogAddr, exist := os.LookupEnv("CATALOG_SERVICE_ADDR"); exist {
+		cfg.CatalogServiceAddr = catalogAddr
+	}
+
+	if timeout, exist := os.LookupEnv("CATALOG_SERVICE_TIMEOUT"); exist {
+		if d, err := time.ParseDuration(timeout); err == nil {
+			cfg.CatalogServiceTimeout = d
+		}
+	}
+
+	// Kiểm tra các biến môi trường cấu hình giữ chỗ hàng trong kho
+	if enabled, exist := os.LookupEnv("INVENTORY_ENABLED"); exist {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			cfg.InventoryEnabled = b
+		}
+	}
+
+	if ttl, exist := os.LookupEnv("INVENTORY_RESERVATION_TTL"); exist {
+		if d, err := time.ParseDuration(ttl); err == nil {
+			cfg.InventoryReservationTTL = d
+		}
+	}
+
+	if interval, exist := os.LookupEnv("INVENTORY_SWEEP_INTERVAL"); exist {
+		if d, err := time.ParseDuration(interval); err == nil {
+			cfg.InventorySweepInterval = d
+		}
+	}
+
+	// Kiểm tra các biến môi trường cấu hình saga đặt đơn
+	if enabled, exist := os.LookupEnv("SAGA_ENABLED"); exist {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			cfg.SagaEnabled = b
+		}
+	}
+
+	if interval, exist := os.LookupEnv("SAGA_RESUME_INTERVAL"); exist {
+		if d, err := time.ParseDuration(interval); err == nil {
+			cfg.SagaResumeInterval = d
+		}
+	}
+
+	// Kiểm tra các biến môi trường cấu hình cổng thanh toán
+	if provider, exist := os.LookupEnv("PAYMENT_PROVIDER"); exist {
+		cfg.PaymentProvider = provider
+	}
+
+	if behavior, exist := os.LookupEnv("PAYMENT_FAKE_BEHAVIOR"); exist {
+		cfg.PaymentFakeBehavior = behavior
+	}
+
+	if delay, exist := os.LookupEnv("PAYMENT_FAKE_DELAY"); exist {
+		if d, err := time.ParseDuration(delay); err == nil {
+			cfg.PaymentFakeDelay = d
+		}
+	}
+
+	// Kiểm tra các biến môi trường cấu hình lưu trữ đơn đã hoàn tất
+	if enabled, exist := os.LookupEnv("RETENTION_ENABLED"); exist {
+		if b, err := strconv.ParseBool(enabled); err == nil {
+			cfg.RetentionEnabled = b
+		}
+	}
+
+	if after, exist := os.LookupEnv("RETENTION_AFTER"); exist {
+		if d, err := time.ParseDuration(after); err == nil {
+			cfg.RetentionAfter = d
+		}
+	}
+
+	if dir, exist := os.LookupEnv("RETENTION_DIR"); exist {
+		cfg.RetentionDir = dir
+	}
+
+	if interval, exist := os.LookupEnv("RETENTION_INTERVAL"); exist {
+		if d, err := time.ParseDuration(interval); err == nil {
+			cfg.RetentionInterval = d
+		}
+	}
+
+	// Kiểm tra biến môi trường dùng để xác thực JWT do user-service cấp
+	if jwtSecret, exist := os.LookupEnv("JWT_SECRET_KEY"); exist {
+		cfg.JwtSecret = jwtSecret
+	}
+
+	if keyFile, exist := os.LookupEnv("JWT_PUBLIC_KEY_FILE"); exist {
+		cfg.JwtPublicKeyFile = keyFile
+	}
+
+	// Khóa ký cursor phân trang, các instance phải dùng chung khóa để cursor dùng được ở mọi instance
+	if cursorSecret, exist := os.LookupEnv("CURSOR_SECRET"); exist {
+		cfg.CursorSecret = cursorSecret
+	}
+
+	// Kiểm tra các biến môi trường cấu hình tiền tệ, thuế và phí vận chuyển
+	if currency, exist := os.LookupEnv("DEFAULT_CURRENCY"); exist {
+		cfg.DefaultCurrency = currency
+	}
+
+	if ratesFile, exist := os.LookupEnv("EXCHANGE_RATES_FILE"); exist {
+		cfg.ExchangeRatesFile = ratesFile
+	}
+
+	if taxRates, exist := os.LookupEnv("TAX_RATES"); exist {
+		cfg.TaxRates = taxRates
+	}
+
+	if exempt, exist := os.LookupEnv("TAX_EXEMPT_ITEMS"); exist {
+		cfg.TaxExemptItems = exempt
+	}
+
+	if fee, exist := os.LookupEnv("SHIPPING_FEE"); exist {
+		cfg.ShippingFee = fee
+	}
+
+	if threshold, exist := os.LookupEnv("FREE_SHIPPING_FROM"); exist {
+		cfg.FreeShippingFrom = threshold
+	}
+
 	return cfg // trả về cấu hình config đã thiết lập
 }
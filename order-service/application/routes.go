@@ -4,10 +4,11 @@ import (
 	"net/http"
 
 	"github.com/RibunLoc/microservices-learn/handler"
-	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/RibunLoc/microservices-learn/proto/orderpb"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"google.golang.org/grpc"
 )
 
 // Dùng để khởi tạo và cấu hình các routes chính cho ứng dụng
@@ -18,34 +19,112 @@ func (a *App) loadRoutes() {
 	// Ghi log cho tất cả request - ghi lại method, URL, thời gian xử lý
 	router.Use(middleware.Logger)
 
+	// Kiểm tra tham số và body của request theo tài liệu OpenAPI, sai thì trả về 400 kèm danh sách lỗi
+	router.Use(a.spec.Middleware)
+
 	// Định nghĩa endpoint "/" kiểm tra app đang chạy
 	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	// Tài liệu OpenAPI của REST API, không cần JWT
+	router.Method(http.MethodGet, "/openapi.json", a.spec)
+
+	// Handler đơn hàng dùng chung cho REST API và gRPC
+	a.orders = a.newOrderHandler()
+
 	// Gắn nhóm route con /orders vào router, bằng cách gọi hàm a.loadOrderRoutes
 	router.Route("/orders", a.loadOrderRoutes)
 
+	// Quản lý tồn kho, chỉ có khi bật giữ chỗ hàng
+	if a.stock != nil {
+		router.Route("/inventory", a.loadInventoryRoutes)
+	}
+
 	// Gắn router đã cấu hình vào App, khi khởi động server sẽ dùng đến nó
 	a.router = router
 }
 
+/*
+Tạo một handler xử lý đơn hàng, gắn với một repo truy xuất dữ liệu
+  - handler xử lý http và gRPC
+  - repo truy xuất dữ liệu (redis, memory hoặc sql tùy cấu hình)
+*/
+func (a *App) newOrderHandler() *handler.Order {
+	orderHandler := &handler.Order{
+		Repo:        a.repo,
+		IDGen:       a.idgen,
+		Idempotency: a.idem,
+		Pricing:     a.prices,
+		Currency:    a.currency,
+		CursorKey:   a.cursors,
+	}
+	// Gán riêng để interface không nhận con trỏ nil khi tắt kiểm tra khách hàng.
+	// Khi bật saga, khách hàng được kiểm tra trong saga.
+	if a.users != nil && a.sagas == nil {
+		orderHandler.Customers = a.users
+	}
+	if a.items != nil {
+		orderHandler.Catalog = a.items
+	}
+	if a.stock != nil {
+		orderHandler.Inventory = a.stock
+	}
+	orderHandler.Saga = a.sagas
+	orderHandler.Payments = a.payments
+	orderHandler.Retention = a.archive
+	return orderHandler
+}
+
 // định nghĩa các route con bên trong /orders
 func (a *App) loadOrderRoutes(router chi.Router) {
-	/*
-		Tạo một handler xử lý đơn hàng, gắn với một repo truy xuất dữ liệu
-		 - handler xử lý http
-		 - repo truy xuất redis
-	*/
-	orderHandler := &handler.Order{
-		Repo: &order.RedisRepo{
-			Client: a.rdb,
-		},
+	// Xác thực JWT cho tất cả route đơn hàng, người gọi được gắn vào context
+	router.Use(a.auth.Middleware)
+
+	orderHandler := a.orders
+
+	router.Post("/", orderHandler.Create)              // Tạo mới một đơn hàng
+	router.Get("/", orderHandler.List)                 // Trả về danh sách tất cả các đơn hàng
+	router.Get("/export", orderHandler.Export)         // Xuất đơn hàng dạng NDJSON hoặc CSV
+	router.Post("/import", orderHandler.Import)        // Nhập đơn hàng hàng loạt (admin)
+	router.Get("/{id}", orderHandler.GetByID)          // Trả về đơn hàng theo id
+	router.Put("/{id}", orderHandler.UpdateByID)       // Cập nhật đơn hàng theo id
+	router.Delete("/{id}", orderHandler.DeleteByID)    // Xóa đơn hàng theo id
+	router.Get("/{id}/history", orderHandler.History)  // Lịch sử thay đổi trạng thái của đơn hàng
+	router.Post("/{id}/restore", orderHandler.Restore) // Khôi phục đơn hàng đã xóa (admin)
+	router.Post("/{id}/cancel", orderHandler.Cancel)   // Hủy đơn trước khi giao, hoàn tiền và trả hàng
+
+	// Sửa line item của đơn hàng trước khi giao
+	router.Post("/{id}/items", orderHandler.AddItem)                // Thêm một mặt hàng
+	router.Patch("/{id}/items/{item_id}", orderHandler.UpdateItem)  // Sửa số lượng mặt hàng
+	router.Delete("/{id}/items/{item_id}", orderHandler.RemoveItem) // Xóa một mặt hàng
+
+	// Thanh toán qua cổng thanh toán, chỉ có khi cấu hình PAYMENT_PROVIDER
+	if a.payments != nil {
+		router.Post("/{id}/pay", orderHandler.Pay)       // Giữ tiền (nếu chưa) và thu tiền
+		router.Post("/{id}/refund", orderHandler.Refund) // Hoàn tiền (admin)
+	}
+}
+
+// định nghĩa các route con bên trong /inventory
+func (a *App) loadInventoryRoutes(router chi.Router) {
+	router.Use(a.auth.Middleware)
+
+	inventoryHandler := &handler.Inventory{
+		Store: a.stock,
 	}
 
-	router.Post("/", orderHandler.Create)           // Tạo mới một đơn hàng
-	router.Get("/", orderHandler.List)              // Trả về danh sách tất cả các đơn hàng
-	router.Get("/{id}", orderHandler.GetByID)       // Trả về đơn hàng theo id
-	router.Put("/{id}", orderHandler.UpdateByID)    // Cập nhật đơn hàng theo id
-	router.Delete("/{id}", orderHandler.DeleteByID) // Xóa đơn hàng theo id
+	router.Get("/{item_id}", inventoryHandler.GetStock) // Tồn kho của một mặt hàng
+	router.Put("/{item_id}", inventoryHandler.SetStock) // Đặt số lượng trong kho (admin)
+}
+
+// loadGRPC tạo gRPC server của OrderService, xác thực JWT trong metadata giống REST API
+func (a *App) loadGRPC() {
+	a.grpc = grpc.NewServer(
+		grpc.UnaryInterceptor(a.auth.UnaryInterceptor),
+		grpc.StreamInterceptor(a.auth.StreamInterceptor),
+	)
+	orderpb.RegisterOrderServiceServer(a.grpc, &handler.OrderGRPCHandler{
+		Orders: a.orders,
+	})
 }
@@ -0,0 +1,41 @@
+package auth
+
+import (
+	"context"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+// Các role của user do user-service cấp trong JWT
+const (
+	RoleUser  = "user"  // khách hàng, chỉ thao tác trên đơn của chính mình
+	RoleAdmin = "admin" // quản trị, thao tác được trên mọi đơn
+)
+
+// Identity là người gọi API đã được xác thực bằng JWT
+type Identity struct {
+	UserID model.CustomerID // ID user bên user-service
+	Role   string
+}
+
+func (i Identity) IsAdmin() bool {
+	return i.Role == RoleAdmin
+}
+
+// CanAccess trả về true nếu người gọi là chủ đơn hàng hoặc là admin
+func (i Identity) CanAccess(o model.Order) bool {
+	return i.IsAdmin() || o.CustomerID == i.UserID
+}
+
+type contextKey struct{}
+
+// WithIdentity gắn người gọi vào context của request
+func WithIdentity(ctx context.Context, id Identity) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// FromContext lấy người gọi đã được middleware gắn vào context
+func FromContext(ctx context.Context) (Identity, bool) {
+	id, ok := ctx.Value(contextKey{}).(Identity)
+	return id, ok
+}
@@ -0,0 +1,61 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryInterceptor xác thực JWT của mỗi RPC giống Middleware của REST API,
+// token nằm trong metadata "authorization: Bearer <token>"
+func (v *Verifier) UnaryInterceptor(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	id, err := v.verifyMetadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(WithIdentity(ctx, id), req)
+}
+
+// StreamInterceptor giống UnaryInterceptor cho các RPC dạng stream
+func (v *Verifier) StreamInterceptor(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	id, err := v.verifyMetadata(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, &identityStream{ServerStream: ss, ctx: WithIdentity(ss.Context(), id)})
+}
+
+// verifyMetadata đọc và kiểm tra token trong metadata, lỗi trả về Unauthenticated
+func (v *Verifier) verifyMetadata(ctx context.Context) (Identity, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 {
+		return Identity{}, status.Error(codes.Unauthenticated, "missing authorization metadata")
+	}
+	scheme, tokenStr, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || tokenStr == "" {
+		return Identity{}, status.Error(codes.Unauthenticated, "authorization must be a bearer token")
+	}
+
+	id, err := v.Verify(strings.TrimSpace(tokenStr))
+	if err != nil {
+		fmt.Println("failed to verify token: ", err)
+		return Identity{}, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	return id, nil
+}
+
+// identityStream thay context của stream bằng context đã gắn người gọi
+type identityStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *identityStream) Context() context.Context {
+	return s.ctx
+}
@@ -0,0 +1,119 @@
+package auth
+
+import (
+	"crypto"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Verifier kiểm tra JWT do user-service cấp (util.GenerateJWT).
+// Dùng Secret khi user-service ký HS256 bằng secret chung,
+// hoặc PublicKey khi user-service ký bằng khóa bất đối xứng (RS*, ES*, EdDSA).
+type Verifier struct {
+	Secret    []byte
+	PublicKey crypto.PublicKey
+}
+
+var errInvalidToken = errors.New("invalid token")
+
+// NewVerifier tạo Verifier từ secret chung hoặc file PEM chứa public key,
+// phải có ít nhất một trong hai
+func NewVerifier(secret string, publicKeyPEM []byte) (*Verifier, error) {
+	v := &Verifier{}
+	if secret != "" {
+		v.Secret = []byte(secret)
+	}
+	if len(publicKeyPEM) > 0 {
+		key, err := parsePublicKey(publicKeyPEM)
+		if err != nil {
+			return nil, err
+		}
+		v.PublicKey = key
+	}
+	if v.Secret == nil && v.PublicKey == nil {
+		return nil, errors.New("jwt secret or public key is required")
+	}
+	return v, nil
+}
+
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	if key, err := jwt.ParseRSAPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseECPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	if key, err := jwt.ParseEdPublicKeyFromPEM(data); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("unsupported jwt public key")
+}
+
+// keyFunc chọn khóa kiểm tra chữ ký theo thuật toán ghi trong token,
+// token ký bằng thuật toán không được cấu hình sẽ bị từ chối
+func (v *Verifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if v.Secret != nil {
+			return v.Secret, nil
+		}
+	case *jwt.SigningMethodRSA, *jwt.SigningMethodRSAPSS, *jwt.SigningMethodECDSA, *jwt.SigningMethodEd25519:
+		if v.PublicKey != nil {
+			return v.PublicKey, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+}
+
+// Verify kiểm tra chữ ký, thời hạn của token và trả về người gọi
+func (v *Verifier) Verify(tokenStr string) (Identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenStr, claims, v.keyFunc, jwt.WithExpirationRequired())
+	if err != nil {
+		return Identity{}, err
+	}
+
+	// 1. user_id phải là ID user hợp lệ của user-service
+	userID, _ := claims["user_id"].(string)
+	id, err := model.ParseCustomerID(userID)
+	if err != nil {
+		return Identity{}, errInvalidToken
+	}
+
+	// 2. Token cấp trước khi có claim role được coi là user thường
+	role, _ := claims["role"].(string)
+	if role == "" {
+		role = RoleUser
+	}
+
+	return Identity{UserID: id, Role: role}, nil
+}
+
+// Middleware yêu cầu header "Authorization: Bearer <token>" hợp lệ,
+// gắn người gọi vào context, ngược lại trả về 401
+func (v *Verifier) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader := r.Header.Get("Authorization")
+		scheme, tokenStr, ok := strings.Cut(authHeader, " ")
+		if !ok || !strings.EqualFold(scheme, "Bearer") || tokenStr == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		id, err := v.Verify(strings.TrimSpace(tokenStr))
+		if err != nil {
+			fmt.Println("failed to verify token: ", err)
+			w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(WithIdentity(r.Context(), id)))
+	})
+}
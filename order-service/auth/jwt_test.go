@@ -0,0 +1,122 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testSecret = "test-secret"
+	testUserID = "64b7f3a2c9e1d2a3b4c5d6e1"
+)
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{"user_id": testUserID, "role": RoleAdmin, "exp": time.Now().Add(time.Hour).Unix()}
+}
+
+func signHS256(t *testing.T, claims jwt.MapClaims, secret string) string {
+	t.Helper()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(secret))
+	if err != nil {
+		t.Fatalf("sign: %v", err)
+	}
+	return token
+}
+
+func TestVerify(t *testing.T) {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+	signEdDSA := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims).SignedString(private)
+		if err != nil {
+			t.Fatalf("sign: %v", err)
+		}
+		return token
+	}
+	noneToken, _ := jwt.NewWithClaims(jwt.SigningMethodNone, validClaims()).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	withSecret := &Verifier{Secret: []byte(testSecret)}
+	withKey := &Verifier{PublicKey: public}
+
+	tests := []struct {
+		name     string
+		verifier *Verifier
+		token    string
+		want     Identity
+		wantErr  bool
+	}{
+		{"hs256", withSecret, signHS256(t, validClaims(), testSecret), Identity{UserID: testUserID, Role: RoleAdmin}, false},
+		{"eddsa", withKey, signEdDSA(validClaims()), Identity{UserID: testUserID, Role: RoleAdmin}, false},
+		{"token without role is a user", withSecret, signHS256(t, jwt.MapClaims{"user_id": testUserID, "exp": time.Now().Add(time.Hour).Unix()}, testSecret),
+			Identity{UserID: testUserID, Role: RoleUser}, false},
+		{"wrong secret", withSecret, signHS256(t, validClaims(), "other-secret"), Identity{}, true},
+		{"alg none", withSecret, noneToken, Identity{}, true},
+		{"hs256 when only a public key is configured", withKey, signHS256(t, validClaims(), testSecret), Identity{}, true},
+		{"eddsa when only a secret is configured", withSecret, signEdDSA(validClaims()), Identity{}, true},
+		{"expired", withSecret, signHS256(t, jwt.MapClaims{"user_id": testUserID, "exp": time.Now().Add(-time.Minute).Unix()}, testSecret), Identity{}, true},
+		{"missing exp", withSecret, signHS256(t, jwt.MapClaims{"user_id": testUserID}, testSecret), Identity{}, true},
+		{"missing user_id", withSecret, signHS256(t, jwt.MapClaims{"exp": time.Now().Add(time.Hour).Unix()}, testSecret), Identity{}, true},
+		{"invalid user_id", withSecret, signHS256(t, jwt.MapClaims{"user_id": "42", "exp": time.Now().Add(time.Hour).Unix()}, testSecret), Identity{}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.verifier.Verify(tt.token)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Verify error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("Verify = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	v := &Verifier{Secret: []byte(testSecret)}
+	token := signHS256(t, validClaims(), testSecret)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"bearer token", "Bearer " + token, http.StatusOK},
+		{"scheme is case insensitive", "bearer " + token, http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"other scheme", "Basic " + token, http.StatusUnauthorized},
+		{"empty token", "Bearer ", http.StatusUnauthorized},
+		{"invalid token", "Bearer " + token + "x", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got Identity
+			handler := v.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				got, _ = FromContext(r.Context())
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+			if tt.status == http.StatusOK && got.UserID != testUserID {
+				t.Fatalf("identity = %+v, want user %s", got, testUserID)
+			}
+			if tt.status == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Fatalf("missing WWW-Authenticate header")
+			}
+		})
+	}
+}
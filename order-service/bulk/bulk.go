@@ -0,0 +1,105 @@
+package bulk
+
+import (
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+/*
+Format là định dạng khi xuất/nhập đơn hàng hàng loạt (GET /orders/export, POST /orders/import),
+giá trị cũng là phần mở rộng của tên file:
+  - NDJSON: mỗi dòng là JSON của một order, giữ đầy đủ dữ liệu (kể cả thanh toán, mốc thời gian)
+  - CSV: mỗi dòng là một line item kèm các cột của order, đơn không có hàng chiếm một dòng
+    với các cột line item để trống. Các dòng liên tiếp cùng order_id được gộp thành một order.
+*/
+type Format string
+
+const (
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+)
+
+// ContentType trả về header Content-Type của định dạng
+func (f Format) ContentType() string {
+	if f == FormatCSV {
+		return "text/csv; charset=utf-8"
+	}
+	return "application/x-ndjson"
+}
+
+// Negotiate chọn định dạng theo header Accept, không có header thì dùng NDJSON.
+// Trả về false nếu client không nhận định dạng nào được hỗ trợ.
+func Negotiate(accept string) (Format, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return FormatNDJSON, true
+	}
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		switch mediaType {
+		case "text/csv":
+			return FormatCSV, true
+		case "application/x-ndjson", "application/ndjson", "application/*", "*/*":
+			return FormatNDJSON, true
+		}
+	}
+	return "", false
+}
+
+// FormatOf chọn định dạng theo header Content-Type của dữ liệu gửi lên, không có header thì là NDJSON
+func FormatOf(contentType string) (Format, bool) {
+	if strings.TrimSpace(contentType) == "" {
+		return FormatNDJSON, true
+	}
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	switch mediaType {
+	case "text/csv":
+		return FormatCSV, true
+	case "application/x-ndjson", "application/ndjson":
+		return FormatNDJSON, true
+	}
+	return "", false
+}
+
+// Encoder ghi lần lượt từng order, Flush đẩy dữ liệu còn trong bộ đệm ra writer
+type Encoder interface {
+	Encode(o model.Order) error
+	Flush() error
+}
+
+func NewEncoder(f Format, w io.Writer) Encoder {
+	if f == FormatCSV {
+		return newCSVEncoder(w)
+	}
+	return newNDJSONEncoder(w)
+}
+
+// Record là một order đọc được cùng số dòng bắt đầu của nó.
+// Err khác nil nếu dòng không đọc được, khi đó Order chỉ có các trường đã đọc được.
+type Record struct {
+	Line  int
+	Order model.Order
+	Err   error
+}
+
+// Decoder đọc lần lượt từng order, hết dữ liệu thì trả về io.EOF.
+// Error khác io.EOF nghĩa là không đọc tiếp được nữa (ví dụ mất kết nối).
+type Decoder interface {
+	Next() (Record, error)
+}
+
+// NewDecoder tạo Decoder theo định dạng, với CSV thì đọc luôn dòng tiêu đề
+func NewDecoder(f Format, r io.Reader) (Decoder, error) {
+	if f == FormatCSV {
+		return newCSVDecoder(r)
+	}
+	return newNDJSONDecoder(r), nil
+}
@@ -0,0 +1,291 @@
+package bulk
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/google/uuid"
+)
+
+// Dùng để báo lỗi khi dòng tiêu đề của file CSV thiếu cột
+var ErrInvalidHeader = errors.New("invalid csv header")
+
+// CSVHeader là các cột của file CSV. Số tiền theo đơn vị nhỏ nhất (minor units)
+// của cột currency, created_at theo RFC3339. region, sku và name bắt đầu bằng
+// = + - @ tab, CR hoặc ' được thêm dấu ' ở đầu (xem csvText).
+var CSVHeader = []string{
+	"order_id", "customer_id", "order_status", "version", "created_at", "region",
+	"currency", "subtotal", "tax", "shipping", "grand_total",
+	"item_id", "sku", "name", "quantity", "price",
+}
+
+// Số cột đầu tiên của CSVHeader là thông tin của order, được lặp lại ở mỗi dòng
+const orderColumns = 11
+
+type csvEncoder struct {
+	w      *csv.Writer
+	header bool // đã ghi dòng tiêu đề
+}
+
+func newCSVEncoder(w io.Writer) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w)}
+}
+
+func (e *csvEncoder) writeHeader() error {
+	if e.header {
+		return nil
+	}
+	e.header = true
+	return e.w.Write(CSVHeader)
+}
+
+// Encode ghi mỗi line item của order thành một dòng
+func (e *csvEncoder) Encode(o model.Order) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	createdAt := ""
+	if o.CreateAt != nil {
+		createdAt = o.CreateAt.UTC().Format(time.RFC3339Nano)
+	}
+	order := []string{
+		strconv.FormatUint(o.OrderID, 10),
+		o.CustomerID.String(),
+		string(o.OrderStatus),
+		strconv.FormatUint(o.Version, 10),
+		createdAt,
+		csvText(o.Region),
+		o.Currency,
+		strconv.FormatUint(o.Subtotal.Amount, 10),
+		strconv.FormatUint(o.Tax.Amount, 10),
+		strconv.FormatUint(o.Shipping.Amount, 10),
+		strconv.FormatUint(o.GrandTotal.Amount, 10),
+	}
+
+	// Đơn không có hàng vẫn có một dòng để không bị mất khi nhập lại
+	if len(o.LineItems) == 0 {
+		return e.w.Write(append(order, "", "", "", "", ""))
+	}
+	for _, item := range o.LineItems {
+		row := append(append([]string{}, order...),
+			item.ItemID.String(),
+			csvText(item.SKU),
+			csvText(item.Name),
+			strconv.FormatUint(uint64(item.Quantity), 10),
+			strconv.FormatUint(item.Price.Amount, 10),
+		)
+		if err := e.w.Write(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Ký tự đầu ô làm bảng tính (Excel, Google Sheets) hiểu nội dung là công thức
+const formulaPrefixes = "=+-@\t\r"
+
+// csvText chống chèn công thức (CSV injection) cho cột văn bản do client gửi lên:
+// ô bắt đầu bằng = + - @ tab hoặc CR được thêm dấu ' ở đầu để bảng tính hiển thị như chữ.
+// Ô vốn bắt đầu bằng ' cũng được thêm để csvDecoder bỏ đúng một dấu ' khi nhập lại.
+func csvText(s string) string {
+	if s != "" && strings.ContainsRune(formulaPrefixes+"'", rune(s[0])) {
+		return "'" + s
+	}
+	return s
+}
+
+// parseCSVText là chiều ngược lại của csvText
+func parseCSVText(s string) string {
+	if len(s) > 1 && s[0] == '\'' && strings.ContainsRune(formulaPrefixes+"'", rune(s[1])) {
+		return s[1:]
+	}
+	return s
+}
+
+// Flush ghi cả dòng tiêu đề nếu chưa có order nào
+func (e *csvEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// csvRow là một dòng CSV đã đọc: thông tin order, line item (nếu có)
+// và các cột của order để so sánh các dòng cùng order_id
+type csvRow struct {
+	line  int
+	order model.Order
+	item  *model.LineItem
+	key   string
+	err   error
+}
+
+type csvDecoder struct {
+	r       *csv.Reader
+	columns map[string]int // vị trí của từng cột trong CSVHeader theo dòng tiêu đề
+	pending *csvRow        // dòng đã đọc nhưng thuộc order kế tiếp
+}
+
+// newCSVDecoder đọc dòng tiêu đề, các cột có thể theo thứ tự bất kỳ nhưng phải đủ
+func newCSVDecoder(r io.Reader) (*csvDecoder, error) {
+	d := &csvDecoder{r: csv.NewReader(r), columns: map[string]int{}}
+
+	header, err := d.r.Read()
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidHeader)
+	} else if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	for i, name := range header {
+		d.columns[strings.TrimSpace(name)] = i
+	}
+	for _, name := range CSVHeader {
+		if _, ok := d.columns[name]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidHeader, name)
+		}
+	}
+	return d, nil
+}
+
+// Next gộp các dòng liên tiếp cùng order_id thành một order.
+// Dòng lỗi làm cả order bị lỗi, dòng không đọc được order_id là một record riêng.
+func (d *csvDecoder) Next() (Record, error) {
+	first := d.pending
+	d.pending = nil
+	if first == nil {
+		var err error
+		if first, err = d.readRow(); err != nil {
+			return Record{}, err
+		}
+	}
+
+	rec := Record{Line: first.line, Order: first.order, Err: first.err}
+	if first.item != nil {
+		rec.Order.LineItems = append(rec.Order.LineItems, *first.item)
+	}
+	if first.order.OrderID == 0 {
+		return rec, nil
+	}
+
+	for {
+		row, err := d.readRow()
+		if errors.Is(err, io.EOF) {
+			return rec, nil
+		} else if err != nil {
+			return Record{}, err
+		}
+		if row.order.OrderID != first.order.OrderID {
+			d.pending = row
+			return rec, nil
+		}
+
+		if rec.Err == nil && row.err != nil {
+			rec.Err = fmt.Errorf("line %d: %w", row.line, row.err)
+		} else if rec.Err == nil && row.key != first.key {
+			rec.Err = fmt.Errorf("line %d: order columns differ from line %d", row.line, first.line)
+		}
+		if row.item != nil {
+			rec.Order.LineItems = append(rec.Order.LineItems, *row.item)
+		}
+	}
+}
+
+// readRow đọc một dòng, lỗi định dạng của dòng được ghi vào csvRow.err
+func (d *csvDecoder) readRow() (*csvRow, error) {
+	record, err := d.r.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return &csvRow{line: parseErr.StartLine, err: parseErr.Err}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	line, _ := d.r.FieldPos(0)
+	row := &csvRow{line: line}
+	row.order, row.item, row.err = d.parseRow(record)
+
+	keys := make([]string, orderColumns)
+	for i, name := range CSVHeader[:orderColumns] {
+		keys[i] = record[d.columns[name]]
+	}
+	row.key = strings.Join(keys, ",")
+	return row, nil
+}
+
+// parseRow đọc order và line item của một dòng, trả về phần đã đọc được kèm lỗi đầu tiên
+func (d *csvDecoder) parseRow(record []string) (model.Order, *model.LineItem, error) {
+	get := func(name string) string {
+		return strings.TrimSpace(record[d.columns[name]])
+	}
+
+	var o model.Order
+	var err error
+	if o.OrderID, err = strconv.ParseUint(get("order_id"), 10, 64); err != nil {
+		return model.Order{}, nil, fmt.Errorf("invalid order_id: %q", get("order_id"))
+	}
+	o.CustomerID = model.CustomerID(get("customer_id"))
+	o.OrderStatus = model.Status(get("order_status"))
+	o.Region = parseCSVText(get("region"))
+	o.Currency = get("currency")
+
+	if v := get("version"); v != "" {
+		if o.Version, err = strconv.ParseUint(v, 10, 64); err != nil {
+			return o, nil, fmt.Errorf("invalid version: %q", v)
+		}
+	}
+	if v := get("created_at"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return o, nil, fmt.Errorf("invalid created_at: %q", v)
+		}
+		o.CreateAt = &t
+	}
+
+	amounts := []struct {
+		name  string
+		money *model.Money
+	}{
+		{"subtotal", &o.Subtotal},
+		{"tax", &o.Tax},
+		{"shipping", &o.Shipping},
+		{"grand_total", &o.GrandTotal},
+	}
+	for _, a := range amounts {
+		if v := get(a.name); v != "" {
+			if a.money.Amount, err = strconv.ParseUint(v, 10, 64); err != nil {
+				return o, nil, fmt.Errorf("invalid %s: %q", a.name, v)
+			}
+		}
+		a.money.Currency = o.Currency
+	}
+
+	// Các cột line item để trống là đơn không có hàng
+	if get("item_id") == "" && get("quantity") == "" && get("price") == "" {
+		return o, nil, nil
+	}
+	item := model.LineItem{
+		SKU:   parseCSVText(get("sku")),
+		Name:  parseCSVText(get("name")),
+		Price: model.Money{Currency: o.Currency},
+	}
+	if item.ItemID, err = uuid.Parse(get("item_id")); err != nil {
+		return o, nil, fmt.Errorf("invalid item_id: %q", get("item_id"))
+	}
+	quantity, err := strconv.ParseUint(get("quantity"), 10, 32)
+	if err != nil {
+		return o, nil, fmt.Errorf("invalid quantity: %q", get("quantity"))
+	}
+	item.Quantity = uint(quantity)
+	if item.Price.Amount, err = strconv.ParseUint(get("price"), 10, 64); err != nil {
+		return o, nil, fmt.Errorf("invalid price: %q", get("price"))
+	}
+	return o, &item, nil
+}
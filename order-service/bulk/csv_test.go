@@ -0,0 +1,166 @@
+package bulk
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/google/uuid"
+)
+
+const testCustomer = model.CustomerID("64b7f3a2c9e1d2a3b4c5d6e1")
+
+var (
+	testItemA = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	testItemB = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+)
+
+func vnd(amount uint64) model.Money { return model.Money{Amount: amount, Currency: "VND"} }
+
+// decodeAll đọc hết các record của dec
+func decodeAll(t *testing.T, dec Decoder) []Record {
+	t.Helper()
+	var records []Record
+	for {
+		rec, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Next: %v", err)
+		}
+		records = append(records, rec)
+	}
+}
+
+// Xuất rồi nhập lại bằng CSV cho ra đúng các order ban đầu, kể cả văn bản giống công thức
+func TestCSVRoundTrip(t *testing.T) {
+	createdAt := time.Date(2025, 1, 31, 14, 5, 0, 123456789, time.UTC)
+	orders := []model.Order{
+		{
+			OrderID: 1, CustomerID: testCustomer, OrderStatus: model.StatusPaid, Version: 3, CreateAt: &createdAt,
+			Region: "VN-HN", Currency: "VND", Subtotal: vnd(5000), Tax: vnd(500), Shipping: vnd(100), GrandTotal: vnd(5600),
+			LineItems: []model.LineItem{
+				{ItemID: testItemA, SKU: "=HYPERLINK(\"x\")", Name: "+1, \"quoted\"\nname", Quantity: 2, Price: vnd(1500)},
+				{ItemID: testItemB, SKU: "'=already quoted", Name: "'plain", Quantity: 1, Price: vnd(2000)},
+			},
+		},
+		{OrderID: 2, CustomerID: testCustomer, OrderStatus: model.StatusPending, Version: 1, CreateAt: &createdAt, Region: "@x", Currency: "VND",
+			Subtotal: vnd(0), Tax: vnd(0), Shipping: vnd(0), GrandTotal: vnd(0)},
+	}
+
+	var buf bytes.Buffer
+	enc := NewEncoder(FormatCSV, &buf)
+	for _, o := range orders {
+		if err := enc.Encode(o); err != nil {
+			t.Fatalf("Encode: %v", err)
+		}
+	}
+	if err := enc.Flush(); err != nil {
+		t.Fatalf("Flush: %v", err)
+	}
+	if !strings.Contains(buf.String(), `'=HYPERLINK`) || strings.Contains(buf.String(), `,=HYPERLINK`) {
+		t.Fatalf("formula not escaped:\n%s", buf.String())
+	}
+
+	dec, err := NewDecoder(FormatCSV, &buf)
+	if err != nil {
+		t.Fatalf("NewDecoder: %v", err)
+	}
+	records := decodeAll(t, dec)
+	if len(records) != len(orders) {
+		t.Fatalf("decoded %d records, want %d", len(records), len(orders))
+	}
+	for i, rec := range records {
+		if rec.Err != nil {
+			t.Fatalf("record %d: %v", i, rec.Err)
+		}
+		if !reflect.DeepEqual(rec.Order, orders[i]) {
+			t.Fatalf("record %d = %+v\nwant %+v", i, rec.Order, orders[i])
+		}
+	}
+}
+
+func TestCSVText(t *testing.T) {
+	tests := []struct {
+		in, out string
+	}{
+		{"", ""},
+		{"plain", "plain"},
+		{"=1+1", "'=1+1"},
+		{"+1", "'+1"},
+		{"-1", "'-1"},
+		{"@SUM(A1)", "'@SUM(A1)"},
+		{"\tx", "'\tx"},
+		{"\rx", "'\rx"},
+		{"'", "''"},
+		{"'=1+1", "''=1+1"},
+		{"a=1", "a=1"},
+	}
+	for _, tt := range tests {
+		if got := csvText(tt.in); got != tt.out {
+			t.Fatalf("csvText(%q) = %q, want %q", tt.in, got, tt.out)
+		}
+		if back := parseCSVText(tt.out); back != tt.in {
+			t.Fatalf("parseCSVText(%q) = %q, want %q", tt.out, back, tt.in)
+		}
+	}
+}
+
+func TestCSVDecoderErrors(t *testing.T) {
+	header := strings.Join(CSVHeader, ",") + "\n"
+	row := func(orderID, status, quantity string) string {
+		return orderID + "," + testCustomer.String() + "," + status + ",1,2025-01-31T14:05:00Z,,VND,0,0,0,0," +
+			testItemA.String() + ",sku,name," + quantity + ",100\n"
+	}
+
+	tests := []struct {
+		name    string
+		csv     string
+		errs    []string // lỗi của từng record, "" là record hợp lệ
+		lines   []int
+		wantErr error // lỗi của NewDecoder
+	}{
+		{"empty file", "", nil, nil, ErrInvalidHeader},
+		{"missing column", "order_id,customer_id\n", nil, nil, ErrInvalidHeader},
+		{"columns in any order", strings.Join(append(CSVHeader[1:], CSVHeader[0]), ",") + "\n", nil, nil, nil},
+		{"rows of one order are merged", header + row("1", "pending", "1") + row("1", "pending", "2") + row("2", "pending", "1"),
+			[]string{"", ""}, []int{2, 4}, nil},
+		{"order columns differ", header + row("1", "pending", "1") + row("1", "paid", "1"),
+			[]string{"line 3: order columns differ from line 2"}, []int{2}, nil},
+		{"invalid line item fails the order", header + row("1", "pending", "1") + row("1", "pending", "x"),
+			[]string{`line 3: invalid quantity: "x"`}, []int{2}, nil},
+		{"unreadable order_id is its own record", header + row("x", "pending", "1") + row("2", "pending", "1"),
+			[]string{`invalid order_id: "x"`, ""}, []int{2, 3}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dec, err := NewDecoder(FormatCSV, strings.NewReader(tt.csv))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("NewDecoder error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+
+			records := decodeAll(t, dec)
+			if len(records) != len(tt.errs) {
+				t.Fatalf("decoded %d records, want %d", len(records), len(tt.errs))
+			}
+			for i, rec := range records {
+				got := ""
+				if rec.Err != nil {
+					got = rec.Err.Error()
+				}
+				if got != tt.errs[i] || rec.Line != tt.lines[i] {
+					t.Fatalf("record %d: line %d error %q, want line %d error %q", i, rec.Line, got, tt.lines[i], tt.errs[i])
+				}
+			}
+		})
+	}
+}
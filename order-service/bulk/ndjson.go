@@ -0,0 +1,62 @@
+package bulk
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+type ndjsonEncoder struct {
+	w   *bufio.Writer
+	enc *json.Encoder
+}
+
+func newNDJSONEncoder(w io.Writer) *ndjsonEncoder {
+	bw := bufio.NewWriter(w)
+	return &ndjsonEncoder{w: bw, enc: json.NewEncoder(bw)}
+}
+
+// Encode ghi JSON của order, json.Encoder tự thêm ký tự xuống dòng
+func (e *ndjsonEncoder) Encode(o model.Order) error {
+	return e.enc.Encode(o)
+}
+
+func (e *ndjsonEncoder) Flush() error {
+	return e.w.Flush()
+}
+
+// ndjsonDecoder đọc từng dòng bằng ReadBytes (không giới hạn độ dài dòng như bufio.Scanner),
+// bỏ qua dòng trống
+type ndjsonDecoder struct {
+	r    *bufio.Reader
+	line int
+}
+
+func newNDJSONDecoder(r io.Reader) *ndjsonDecoder {
+	return &ndjsonDecoder{r: bufio.NewReader(r)}
+}
+
+func (d *ndjsonDecoder) Next() (Record, error) {
+	for {
+		b, err := d.r.ReadBytes('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return Record{}, err
+		}
+		if len(b) == 0 {
+			return Record{}, io.EOF
+		}
+		d.line++
+
+		b = bytes.TrimSpace(b)
+		if len(b) == 0 {
+			continue
+		}
+		rec := Record{Line: d.line}
+		rec.Err = json.Unmarshal(b, &rec.Order)
+		return rec, nil
+	}
+}
@@ -0,0 +1,36 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/google/uuid"
+)
+
+var (
+	// Dùng để báo lỗi khi catalog-service không có sản phẩm với item_id này
+	ErrUnknownItem = errors.New("item does not exist in catalog")
+
+	// Dùng để báo lỗi khi sản phẩm đã ngừng bán
+	ErrDiscontinued = errors.New("item is discontinued")
+
+	// Dùng để báo lỗi khi sản phẩm không có giá theo tiền tệ của đơn
+	ErrNoPrice = errors.New("item has no price in the order currency")
+
+	// Dùng để báo lỗi khi không gọi được catalog-service (timeout, mất kết nối, v.v...)
+	ErrUnavailable = errors.New("catalog-service unavailable")
+)
+
+// Item là thông tin hiện tại của một mặt hàng trong catalog
+type Item struct {
+	ItemID uuid.UUID
+	SKU    string
+	Name   string
+	Price  model.Money // giá theo tiền tệ được yêu cầu
+}
+
+// Resolver tra cứu giá và tên hiện tại của mặt hàng theo tiền tệ của đơn
+type Resolver interface {
+	Resolve(ctx context.Context, itemID uuid.UUID, currency string) (Item, error)
+}
@@ -0,0 +1,78 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/google/uuid"
+)
+
+// Thời gian chờ mặc định cho mỗi lần gọi catalog-service
+const DefaultTimeout = 2 * time.Second
+
+// HTTPClient tra cứu sản phẩm qua REST API GET /products/{id} của catalog-service
+type HTTPClient struct {
+	BaseURL string       // ví dụ "http://catalog-service:3000"
+	Client  *http.Client // nil thì dùng client với DefaultTimeout
+}
+
+// Sản phẩm trả về từ catalog-service, chỉ lấy các trường cần dùng
+type product struct {
+	ProductID uuid.UUID     `json:"product_id"`
+	SKU       string        `json:"sku"`
+	Name      string        `json:"name"`
+	Status    string        `json:"status"`
+	Prices    []model.Money `json:"prices"`
+}
+
+func (c *HTTPClient) client() *http.Client {
+	if c.Client == nil {
+		return &http.Client{Timeout: DefaultTimeout}
+	}
+	return c.Client
+}
+
+// Resolve trả về ErrUnknownItem, ErrDiscontinued hoặc ErrNoPrice nếu không đặt được mặt hàng,
+// các lỗi khi gọi catalog-service được bọc trong ErrUnavailable
+func (c *HTTPClient) Resolve(ctx context.Context, itemID uuid.UUID, currency string) (Item, error) {
+	// 1. Gọi GET /products/{id}
+	endpoint := strings.TrimRight(c.BaseURL, "/") + "/products/" + url.PathEscape(itemID.String())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return Item{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	res, err := c.client().Do(req)
+	if err != nil {
+		return Item{}, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer res.Body.Close()
+
+	// 2. Sản phẩm không tồn tại
+	if res.StatusCode == http.StatusNotFound {
+		return Item{}, fmt.Errorf("%w: %s", ErrUnknownItem, itemID)
+	} else if res.StatusCode != http.StatusOK {
+		return Item{}, fmt.Errorf("%w: unexpected status %d", ErrUnavailable, res.StatusCode)
+	}
+
+	var p product
+	if err := json.NewDecoder(res.Body).Decode(&p); err != nil {
+		return Item{}, fmt.Errorf("%w: failed to decode product: %v", ErrUnavailable, err)
+	}
+
+	// 3. Chỉ bán sản phẩm đang active và có giá theo tiền tệ của đơn
+	if p.Status != "active" {
+		return Item{}, fmt.Errorf("%w: %s", ErrDiscontinued, itemID)
+	}
+	for _, price := range p.Prices {
+		if price.Currency == currency {
+			return Item{ItemID: itemID, SKU: p.SKU, Name: p.Name, Price: price}, nil
+		}
+	}
+	return Item{}, fmt.Errorf("%w: %s has no %s price", ErrNoPrice, itemID, currency)
+}
@@ -0,0 +1,258 @@
+/*
+migrate-customer-ids chuyển customer_id của các order cũ (dạng uuid) sang ID
+user của user-service (Mongo ObjectID hex). Chạy một lần sau khi nâng cấp,
+dùng cùng biến môi trường với order-service (STORAGE_BACKEND, REDIS_ADDR, SQL_DSN, ...).
+
+Vì uuid cũ không suy ra được ID user mới, cần truyền file CSV ánh xạ, mỗi dòng:
+
+	legacy_customer_id,user_id
+
+Ví dụ:
+
+	go run ./cmd/migrate-customer-ids -mapping customers.csv -dry-run
+	go run ./cmd/migrate-customer-ids -mapping customers.csv
+
+Mỗi order được ghi lại bằng Repo.Update (tăng version) nên JSON đã lưu và các
+index theo khách hàng trong Redis được cập nhật trong cùng một transaction.
+Order đã xóa mềm cũng được chuyển để Restore không đưa customer_id cũ trở lại.
+Với Redis, index bị thiếu (dữ liệu cũ) được dựng lại trước như khi order-service
+khởi động, kể cả khi chạy -dry-run, để không bỏ sót order chưa có trong index.
+Order không có trong file ánh xạ được giữ nguyên và in ra để xử lý tay,
+chạy lại lệnh nhiều lần cũng không sao vì order đã chuyển sẽ được bỏ qua.
+*/
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/RibunLoc/microservices-learn/application"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/redis/go-redis/v9"
+	_ "modernc.org/sqlite" // driver "sqlite" cho backend sql
+)
+
+func main() {
+	os.Exit(run())
+}
+
+// run trả về exit code: 0 nếu mọi order đã được chuyển, 1 nếu còn order lỗi hoặc chưa ánh xạ
+func run() int {
+	mappingPath := flag.String("mapping", "", "file CSV ánh xạ legacy_customer_id,user_id")
+	dryRun := flag.Bool("dry-run", false, "chỉ in ra các order sẽ được chuyển, không ghi")
+	batchSize := flag.Uint64("batch", 100, "số order đọc mỗi lần")
+	flag.Parse()
+
+	if *mappingPath == "" || *batchSize == 0 {
+		flag.Usage()
+		return 2
+	}
+
+	mapping, err := loadMapping(*mappingPath)
+	if err != nil {
+		fmt.Println("failed to load mapping: ", err)
+		return 1
+	}
+
+	ctx := context.Background()
+	repo, closeRepo, err := openRepo(ctx, application.LoadConfig())
+	if err != nil {
+		fmt.Println("failed to open repository: ", err)
+		return 1
+	}
+	defer closeRepo()
+
+	stats, err := migrate(ctx, repo, mapping, *batchSize, *dryRun)
+	fmt.Printf("migrated: %d, already valid: %d, unmapped: %d, failed: %d\n",
+		stats.migrated, stats.valid, stats.unmapped, stats.failed)
+	if err != nil {
+		fmt.Println("failed to migrate: ", err)
+		return 1
+	}
+	if stats.unmapped > 0 || stats.failed > 0 {
+		return 1
+	}
+	return 0
+}
+
+// loadMapping đọc file CSV ánh xạ customer_id cũ sang ID user mới
+func loadMapping(path string) (map[string]model.CustomerID, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = 2
+	r.Comment = '#'
+
+	mapping := make(map[string]model.CustomerID)
+	for {
+		record, err := r.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		legacy := strings.ToLower(strings.TrimSpace(record[0]))
+		userID, err := model.ParseCustomerID(strings.TrimSpace(record[1]))
+		if err != nil {
+			line, _ := r.FieldPos(1)
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		mapping[legacy] = userID
+	}
+	return mapping, nil
+}
+
+// openRepo kết nối tới backend lưu trữ giống như order-service
+func openRepo(ctx context.Context, cfg application.Config) (order.OrderRepository, func(), error) {
+	switch cfg.StorageBackend {
+	case application.StorageRedis:
+		rdb := redis.NewClient(&redis.Options{
+			Addr:     cfg.RedisAddress,
+			Username: cfg.Username,
+			Password: cfg.Password,
+		})
+		if err := rdb.Ping(ctx).Err(); err != nil {
+			rdb.Close()
+			return nil, nil, fmt.Errorf("failed to connect to redis: %w", err)
+		}
+		// Order cũ chưa có trong index created_at thì FindAll không thấy, dựng lại index trước
+		repo := &order.RedisRepo{Client: rdb}
+		needed, err := repo.NeedsReindex(ctx)
+		if err == nil && needed {
+			fmt.Println("Rebuilding order indexes")
+			err = repo.Reindex(ctx)
+		}
+		if err != nil {
+			rdb.Close()
+			return nil, nil, err
+		}
+		return repo, func() { rdb.Close() }, nil
+	case application.StorageSQL:
+		db, err := sql.Open(cfg.SQLDriver, cfg.SQLDSN)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to open database: %w", err)
+		}
+		repo := &order.SQLRepo{DB: db}
+		if err := repo.Migrate(ctx); err != nil {
+			db.Close()
+			return nil, nil, err
+		}
+		return repo, func() { db.Close() }, nil
+	default:
+		return nil, nil, fmt.Errorf("storage backend %q has no persistent orders to migrate", cfg.StorageBackend)
+	}
+}
+
+type migrateStats struct {
+	migrated int // số order đã chuyển (hoặc sẽ chuyển nếu dry-run)
+	valid    int // số order đã có customer_id đúng định dạng
+	unmapped int // số order không có trong file ánh xạ
+	failed   int // số order ghi lỗi (ví dụ bị request khác cập nhật cùng lúc)
+}
+
+// migrate duyệt toàn bộ order theo thứ tự tạo và ghi lại customer_id mới,
+// sau đó chuyển các order đã xóa mềm nếu backend hỗ trợ
+func migrate(ctx context.Context, repo order.OrderRepository, mapping map[string]model.CustomerID, batchSize uint64, dryRun bool) (migrateStats, error) {
+	var stats migrateStats
+	var after *order.Position
+
+	for {
+		// 1. Đọc một trang order, thứ tự theo created_at nên không bị
+		// xáo trộn khi cập nhật customer_id
+		res, err := repo.FindAll(ctx, order.FindAllPage{
+			Size:  batchSize,
+			After: after,
+			Sort:  order.SortCreatedAtAsc,
+		})
+		if err != nil {
+			return stats, err
+		}
+
+		for _, o := range res.Orders {
+			// 2. Tìm customer_id mới
+			newID, ok := stats.resolve(o, mapping)
+			if !ok {
+				continue
+			}
+
+			if dryRun {
+				fmt.Printf("order %d: %s -> %s\n", o.OrderID, o.CustomerID, newID)
+				stats.migrated++
+				continue
+			}
+
+			// 3. Ghi lại order, Update chỉ thành công nếu order chưa bị đổi kể từ lúc đọc
+			o.CustomerID = newID
+			o.Version++
+			if err := repo.Update(ctx, o); err != nil {
+				fmt.Printf("order %d: failed to update: %v\n", o.OrderID, err)
+				stats.failed++
+				continue
+			}
+			stats.migrated++
+		}
+
+		// 4. Hết dữ liệu thì dừng
+		if res.Next == nil {
+			break
+		}
+		after = res.Next
+	}
+
+	// 5. Chuyển các order đã xóa mềm
+	archive, ok := repo.(order.ArchiveRewriter)
+	if !ok {
+		return stats, nil
+	}
+	rewritten, err := archive.RewriteDeletedCustomerIDs(ctx, func(o model.Order) (model.CustomerID, bool) {
+		newID, ok := stats.resolve(o, mapping)
+		if !ok {
+			return "", false
+		}
+		if dryRun {
+			fmt.Printf("deleted order %d: %s -> %s\n", o.OrderID, o.CustomerID, newID)
+			stats.migrated++
+			return "", false
+		}
+		return newID, true
+	})
+	stats.migrated += rewritten
+	return stats, err
+}
+
+// resolve trả về customer_id mới của order và ghi nhận order đã đúng định dạng
+// hoặc không có trong file ánh xạ, false nếu không cần hoặc không thể chuyển
+func (stats *migrateStats) resolve(o model.Order, mapping map[string]model.CustomerID) (model.CustomerID, bool) {
+	// Bỏ qua order đã dùng ID của user-service
+	if o.CustomerID.Valid() {
+		stats.valid++
+		return "", false
+	}
+
+	// ID đúng định dạng nhưng viết hoa thì chỉ cần chuẩn hóa,
+	// còn lại tra trong file ánh xạ
+	newID, err := model.ParseCustomerID(string(o.CustomerID))
+	if err != nil {
+		var ok bool
+		newID, ok = mapping[strings.ToLower(string(o.CustomerID))]
+		if !ok {
+			fmt.Printf("order %d: no mapping for customer %q\n", o.OrderID, o.CustomerID)
+			stats.unmapped++
+			return "", false
+		}
+	}
+	return newID, true
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/application"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	legacyA = "6f1c2a9e-0d4b-4c7a-9b0e-2f8a1d3c5e7b"
+	legacyB = "0a9b8c7d-6e5f-4a3b-2c1d-0e9f8a7b6c5d"
+	userA   = model.CustomerID("64b7f3a2c9e1d2a3b4c5d6e1")
+)
+
+var testMapping = map[string]model.CustomerID{legacyA: userA}
+
+func newLegacyOrder(id uint64, customer string) model.Order {
+	createdAt := time.Date(2024, 6, 1, 8, 0, 0, int(id), time.UTC)
+	return model.Order{OrderID: id, CustomerID: model.CustomerID(customer), OrderStatus: model.StatusPending, Version: 1, CreateAt: &createdAt}
+}
+
+func TestMigrate(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	backends := map[string]struct {
+		cfg application.Config
+		// seed ghi dữ liệu chỉ có ở backend này (ví dụ order cũ chưa có index)
+		seed func(t *testing.T, repo order.OrderRepository)
+	}{
+		"redis": {application.Config{StorageBackend: application.StorageRedis, RedisAddress: mr.Addr()}, func(t *testing.T, repo order.OrderRepository) {
+			// Order tạo trước khi có index created_at chỉ nằm trong set "orders"
+			data, _ := json.Marshal(newLegacyOrder(5, legacyA))
+			client.Set(ctx, "order:5", string(data), 0)
+			client.SAdd(ctx, "orders", "order:5")
+		}},
+		"sql": {application.Config{StorageBackend: application.StorageSQL, SQLDriver: "sqlite", SQLDSN: "file:" + filepath.Join(t.TempDir(), "orders.db")}, nil},
+	}
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			// 1. Dữ liệu cũ: đơn đã ánh xạ, đơn không có trong file, đơn đã chuyển và đơn đã xóa mềm
+			seedRepo, closeSeed, err := openRepo(ctx, backend.cfg)
+			if err != nil {
+				t.Fatalf("openRepo: %v", err)
+			}
+			for _, o := range []model.Order{
+				newLegacyOrder(1, legacyA),
+				newLegacyOrder(2, legacyB),
+				newLegacyOrder(3, userA.String()),
+				newLegacyOrder(4, legacyA),
+			} {
+				if err := seedRepo.Insert(ctx, o); err != nil {
+					t.Fatalf("Insert: %v", err)
+				}
+			}
+			if err := seedRepo.DeleteByID(ctx, 4, "admin"); err != nil {
+				t.Fatalf("DeleteByID: %v", err)
+			}
+			want := migrateStats{migrated: 2, valid: 1, unmapped: 1}
+			if backend.seed != nil {
+				backend.seed(t, seedRepo)
+				want.migrated++
+			}
+			closeSeed()
+
+			// 2. Dry-run không ghi gì
+			repo, closeRepo, err := openRepo(ctx, backend.cfg)
+			if err != nil {
+				t.Fatalf("openRepo: %v", err)
+			}
+			defer closeRepo()
+			stats, err := migrate(ctx, repo, testMapping, 2, true)
+			if err != nil || stats != want {
+				t.Fatalf("dry-run stats = %+v, %v, want %+v", stats, err, want)
+			}
+			if o, _ := repo.FindByID(ctx, 1); o.CustomerID != legacyA {
+				t.Fatalf("dry-run changed customer to %s", o.CustomerID)
+			}
+
+			// 3. Chạy thật, order đã xóa mềm cũng được chuyển
+			stats, err = migrate(ctx, repo, testMapping, 2, false)
+			if err != nil || stats != want {
+				t.Fatalf("stats = %+v, %v, want %+v", stats, err, want)
+			}
+			res, err := repo.FindAll(ctx, order.FindAllPage{Size: 10, Filter: order.OrderFilter{CustomerID: userA}})
+			if err != nil || len(res.Orders) != want.migrated {
+				t.Fatalf("orders of %s = %+v, %v", userA, res.Orders, err)
+			}
+			restored, err := repo.Restore(ctx, 4)
+			if err != nil || restored.CustomerID != userA {
+				t.Fatalf("restored order = %+v, %v, want customer %s", restored, err, userA)
+			}
+			if o, _ := repo.FindByID(ctx, 2); o.CustomerID != legacyB {
+				t.Fatalf("unmapped order customer = %s", o.CustomerID)
+			}
+
+			// 4. Chạy lại không còn gì để chuyển
+			stats, err = migrate(ctx, repo, testMapping, 2, false)
+			if err != nil || stats.migrated != 0 || stats.unmapped != 1 {
+				t.Fatalf("second run stats = %+v, %v", stats, err)
+			}
+		})
+	}
+}
+
+func TestOpenRepoRejectsMemoryBackend(t *testing.T) {
+	_, _, err := openRepo(context.Background(), application.Config{StorageBackend: application.StorageMemory})
+	if err == nil {
+		t.Fatalf("openRepo(memory) error = %v", err)
+	}
+}
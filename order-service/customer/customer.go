@@ -0,0 +1,24 @@
+package customer
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+var (
+	// Dùng để báo lỗi khi user-service không có user với ID này
+	ErrUnknown = errors.New("customer does not exist")
+
+	// Dùng để báo lỗi khi user tồn tại nhưng tài khoản đã bị khóa
+	ErrInactive = errors.New("customer is not active")
+
+	// Dùng để báo lỗi khi không gọi được user-service (timeout, mất kết nối, v.v...)
+	ErrUnavailable = errors.New("user-service unavailable")
+)
+
+// Validator kiểm tra khách hàng của đơn hàng có tồn tại và đang hoạt động hay không
+type Validator interface {
+	Validate(ctx context.Context, customerID model.CustomerID) error
+}
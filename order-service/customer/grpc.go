@@ -0,0 +1,79 @@
+package customer
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/proto/userpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// Thời gian chờ mặc định cho mỗi lần gọi user-service
+const DefaultTimeout = 2 * time.Second
+
+// UserServiceClient kiểm tra khách hàng bằng UserService.GetUserByID của user-service
+type UserServiceClient struct {
+	conn   *grpc.ClientConn
+	client userpb.UserServiceClient
+
+	Timeout time.Duration // thời gian chờ mỗi lần gọi, 0 thì dùng DefaultTimeout
+
+	// FailOpen quyết định cách xử lý khi không gọi được user-service:
+	// true thì vẫn cho tạo đơn (fail-open), false thì trả về ErrUnavailable (fail-closed)
+	FailOpen bool
+}
+
+// NewUserServiceClient tạo kết nối gRPC tới user-service tại addr.
+// Kết nối được tạo lười, lỗi mạng chỉ xuất hiện ở lần gọi đầu tiên.
+func NewUserServiceClient(addr string) (*UserServiceClient, error) {
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user-service client: %w", err)
+	}
+	return &UserServiceClient{
+		conn:   conn,
+		client: userpb.NewUserServiceClient(conn),
+	}, nil
+}
+
+func (c *UserServiceClient) Close() error {
+	return c.conn.Close()
+}
+
+// Validate trả về ErrUnknown nếu user không tồn tại, ErrInactive nếu user bị khóa.
+// Lỗi khác khi gọi user-service được xử lý theo FailOpen.
+func (c *UserServiceClient) Validate(ctx context.Context, customerID model.CustomerID) error {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// 1. Gọi GetUserByID của user-service
+	user, err := c.client.GetUserByID(ctx, &userpb.GetUserByIDRequest{UserId: customerID.String()})
+
+	// 2. User không tồn tại hoặc ID sai định dạng đều coi là khách hàng không tồn tại
+	switch status.Code(err) {
+	case codes.OK:
+	case codes.NotFound, codes.InvalidArgument:
+		return ErrUnknown
+	default:
+		if c.FailOpen {
+			fmt.Println("failed to validate customer, allowing order (fail-open): ", err)
+			return nil
+		}
+		return fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	// 3. User tồn tại nhưng tài khoản không còn hoạt động
+	if !user.IsActive {
+		return ErrInactive
+	}
+	return nil
+}
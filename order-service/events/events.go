@@ -0,0 +1,54 @@
+package events
+
+import (
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+// Type là loại sự kiện đơn hàng được ghi vào stream
+type Type string
+
+const (
+	TypeOrderCreated       Type = "order.created"        // đơn hàng mới được tạo
+	TypeOrderStatusChanged Type = "order.status_changed" // đơn hàng chuyển trạng thái
+	TypeOrderUpdated       Type = "order.updated"        // đơn hàng thay đổi nhưng không đổi trạng thái
+	TypeOrderDeleted       Type = "order.deleted"        // đơn hàng bị xóa (chuyển vào lưu trữ)
+	TypeOrderRestored      Type = "order.restored"       // đơn hàng được khôi phục từ lưu trữ
+	TypeOrderArchived      Type = "order.archived"       // đơn hàng đã hoàn tất được chuyển sang file lưu trữ lạnh
+)
+
+// SchemaVersion là phiên bản cấu trúc payload của Event.
+// Tăng lên khi thay đổi không tương thích ngược để consumer biết cách đọc.
+const SchemaVersion = 1
+
+// Stream mặc định chứa sự kiện đơn hàng
+const DefaultStream = "orders:events"
+
+// Số event tối đa giữ lại trong stream mặc định. Redis cắt gần đúng (MAXLEN ~)
+// nên stream có thể dài hơn một chút, event cũ nhất bị bỏ trước kể cả khi
+// consumer group chưa đọc tới, nên giới hạn phải đủ lớn so với độ trễ của consumer.
+const DefaultMaxLen = 100000
+
+// Event là một sự kiện thay đổi đơn hàng, Order luôn là toàn bộ đơn hàng
+// sau khi thay đổi (với order.deleted là đơn hàng trước khi bị xóa)
+type Event struct {
+	ID            string              `json:"-"` // ID entry trong stream, chỉ có khi đọc từ stream
+	Type          Type                `json:"type"`
+	SchemaVersion int                 `json:"schema_version"`
+	OrderID       uint64              `json:"order_id"`
+	Order         model.Order         `json:"order"`
+	Change        *model.StatusChange `json:"change,omitempty"` // chỉ có với order.status_changed
+	OccurredAt    time.Time           `json:"occurred_at"`
+}
+
+// New tạo event với schema version hiện tại
+func New(eventType Type, order model.Order, now time.Time) Event {
+	return Event{
+		Type:          eventType,
+		SchemaVersion: SchemaVersion,
+		OrderID:       order.OrderID,
+		Order:         order,
+		OccurredAt:    now.UTC(),
+	}
+}
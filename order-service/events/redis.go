@@ -0,0 +1,63 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Các field của một entry trong stream. payload là JSON của Event,
+// type và schema_version được tách riêng để consumer lọc mà không cần giải mã
+const (
+	fieldType          = "type"
+	fieldSchemaVersion = "schema_version"
+	fieldOrderID       = "order_id"
+	fieldPayload       = "payload"
+)
+
+/*
+Append thêm lệnh XADD event vào pipeline (outbox).
+Gọi bên trong TxPipelined cùng với các lệnh ghi order để event chỉ xuất hiện
+khi order được ghi thành công, và order không bao giờ được ghi mà thiếu event.
+Stream được cắt gần đúng còn khoảng maxLen event (0 thì dùng DefaultMaxLen).
+*/
+func Append(ctx context.Context, pipe redis.Pipeliner, stream string, maxLen int64, e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	if maxLen <= 0 {
+		maxLen = DefaultMaxLen
+	}
+	pipe.XAdd(ctx, &redis.XAddArgs{
+		Stream: stream,
+		MaxLen: maxLen,
+		Approx: true,
+		Values: []interface{}{
+			fieldType, string(e.Type),
+			fieldSchemaVersion, strconv.Itoa(e.SchemaVersion),
+			fieldOrderID, strconv.FormatUint(e.OrderID, 10),
+			fieldPayload, string(payload),
+		},
+	})
+	return nil
+}
+
+// decode giải mã một entry đọc từ stream thành Event
+func decode(msg redis.XMessage) (Event, error) {
+	payload, ok := msg.Values[fieldPayload].(string)
+	if !ok {
+		return Event{}, fmt.Errorf("event %s has no payload", msg.ID)
+	}
+
+	var e Event
+	if err := json.Unmarshal([]byte(payload), &e); err != nil {
+		return Event{}, fmt.Errorf("failed to decode event %s: %w", msg.ID, err)
+	}
+	e.ID = msg.ID
+	return e, nil
+}
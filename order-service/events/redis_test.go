@@ -0,0 +1,46 @@
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Stream được cắt còn khoảng maxLen event, event mới nhất luôn còn
+func TestAppendTrimsStream(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	const maxLen = 50
+	for id := uint64(1); id <= 500; id++ {
+		_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			return Append(ctx, pipe, DefaultStream, maxLen, New(TypeOrderCreated, model.Order{OrderID: id}, time.Now()))
+		})
+		if err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	n, err := client.XLen(ctx, DefaultStream).Result()
+	if err != nil {
+		t.Fatalf("XLen: %v", err)
+	}
+	if n < maxLen || n >= 500 {
+		t.Fatalf("stream length = %d, want about %d", n, maxLen)
+	}
+
+	last, err := client.XRevRangeN(ctx, DefaultStream, "+", "-", 1).Result()
+	if err != nil || len(last) != 1 {
+		t.Fatalf("XRevRange = %v, %v", last, err)
+	}
+	e, err := decode(last[0])
+	if err != nil || e.OrderID != 500 {
+		t.Fatalf("last event = %+v, %v, want order 500", e, err)
+	}
+}
@@ -0,0 +1,236 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Handler xử lý một event. Trả về lỗi thì event không được ack
+// và sẽ được giao lại sau ClaimIdle (retry).
+type Handler func(ctx context.Context, e Event) error
+
+// Giá trị mặc định của Subscriber
+const (
+	DefaultBatch         = 10
+	DefaultBlock         = 5 * time.Second
+	DefaultClaimIdle     = 30 * time.Second
+	DefaultMaxDeliveries = 5
+)
+
+/*
+Subscriber đọc event đơn hàng bằng consumer group của Redis Streams:
+  - Mỗi service dùng một Group riêng, mỗi instance của service một Consumer riêng,
+    event được chia cho các instance trong cùng group.
+  - Handler chạy thành công thì event được XACK.
+  - Handler lỗi hoặc instance bị tắt giữa chừng thì event nằm lại trong pending,
+    sau ClaimIdle sẽ được instance khác (hoặc chính nó) XCLAIM để xử lý lại.
+  - Event đã giao quá MaxDeliveries lần, hoặc không giải mã được, được chuyển sang
+    DeadLetterStream rồi ack để không chặn các event khác.
+
+Group mới tạo đọc từ đầu stream nên không bỏ sót event ghi trước khi service chạy.
+*/
+type Subscriber struct {
+	Client   *redis.Client
+	Stream   string // stream cần đọc, mặc định DefaultStream
+	Group    string // tên consumer group, thường là tên service
+	Consumer string // tên consumer trong group, thường là hostname của instance
+
+	Batch            int64         // số event đọc mỗi lần, mặc định DefaultBatch
+	Block            time.Duration // thời gian chờ event mới, mặc định DefaultBlock
+	ClaimIdle        time.Duration // event pending lâu hơn thời gian này sẽ được giao lại
+	MaxDeliveries    int64         // số lần giao tối đa trước khi chuyển sang dead-letter
+	DeadLetterStream string        // mặc định là Stream + ":dead"
+}
+
+func (s *Subscriber) stream() string {
+	if s.Stream == "" {
+		return DefaultStream
+	}
+	return s.Stream
+}
+
+func (s *Subscriber) deadLetterStream() string {
+	if s.DeadLetterStream == "" {
+		return s.stream() + ":dead"
+	}
+	return s.DeadLetterStream
+}
+
+func (s *Subscriber) batch() int64 {
+	if s.Batch <= 0 {
+		return DefaultBatch
+	}
+	return s.Batch
+}
+
+func (s *Subscriber) block() time.Duration {
+	if s.Block <= 0 {
+		return DefaultBlock
+	}
+	return s.Block
+}
+
+func (s *Subscriber) claimIdle() time.Duration {
+	if s.ClaimIdle <= 0 {
+		return DefaultClaimIdle
+	}
+	return s.ClaimIdle
+}
+
+func (s *Subscriber) maxDeliveries() int64 {
+	if s.MaxDeliveries <= 0 {
+		return DefaultMaxDeliveries
+	}
+	return s.MaxDeliveries
+}
+
+// Run đọc và xử lý event cho đến khi ctx bị hủy.
+// Lỗi kết nối Redis chỉ được ghi log rồi thử lại, không làm dừng Run.
+func (s *Subscriber) Run(ctx context.Context, handler Handler) error {
+	if s.Group == "" || s.Consumer == "" {
+		return errors.New("subscriber group and consumer are required")
+	}
+
+	// 1. Tạo consumer group (và stream nếu chưa có), group đã tồn tại thì bỏ qua
+	err := s.Client.XGroupCreateMkStream(ctx, s.stream(), s.Group, "0").Err()
+	if err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		return fmt.Errorf("failed to create consumer group: %w", err)
+	}
+
+	for ctx.Err() == nil {
+		// 2. Nhận lại các event bị treo quá lâu trong pending để retry
+		if err := s.reclaim(ctx, handler); err != nil && ctx.Err() == nil {
+			fmt.Println("failed to reclaim pending events: ", err)
+			s.sleep(ctx, time.Second)
+			continue
+		}
+
+		// 3. Đọc event mới chưa giao cho consumer nào
+		if err := s.readNew(ctx, handler); err != nil && ctx.Err() == nil {
+			fmt.Println("failed to read events: ", err)
+			s.sleep(ctx, time.Second)
+		}
+	}
+	return nil
+}
+
+// readNew đọc event mới bằng XREADGROUP và xử lý từng event
+func (s *Subscriber) readNew(ctx context.Context, handler Handler) error {
+	streams, err := s.Client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    s.Group,
+		Consumer: s.Consumer,
+		Streams:  []string{s.stream(), ">"},
+		Count:    s.batch(),
+		Block:    s.block(),
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil // hết thời gian chờ mà không có event mới
+	} else if err != nil {
+		return err
+	}
+
+	for _, stream := range streams {
+		for _, msg := range stream.Messages {
+			s.handle(ctx, handler, msg, 1)
+		}
+	}
+	return nil
+}
+
+// reclaim tìm các event pending quá ClaimIdle, chuyển sang dead-letter nếu đã
+// giao quá MaxDeliveries lần, còn lại XCLAIM về consumer này để xử lý lại
+func (s *Subscriber) reclaim(ctx context.Context, handler Handler) error {
+	pending, err := s.Client.XPendingExt(ctx, &redis.XPendingExtArgs{
+		Stream: s.stream(),
+		Group:  s.Group,
+		Idle:   s.claimIdle(),
+		Start:  "-",
+		End:    "+",
+		Count:  s.batch(),
+	}).Result()
+	if err != nil {
+		return err
+	}
+
+	for _, p := range pending {
+		// XCLAIM chỉ nhận event nếu vẫn còn idle đủ lâu, tránh hai consumer cùng nhận
+		msgs, err := s.Client.XClaim(ctx, &redis.XClaimArgs{
+			Stream:   s.stream(),
+			Group:    s.Group,
+			Consumer: s.Consumer,
+			MinIdle:  s.claimIdle(),
+			Messages: []string{p.ID},
+		}).Result()
+		if err != nil {
+			return err
+		}
+
+		for _, msg := range msgs {
+			// Lần giao này là lần thứ RetryCount + 1
+			deliveries := p.RetryCount + 1
+			if deliveries > s.maxDeliveries() {
+				s.deadLetter(ctx, msg, fmt.Sprintf("delivered %d times", p.RetryCount))
+				continue
+			}
+			s.handle(ctx, handler, msg, deliveries)
+		}
+	}
+	return nil
+}
+
+// handle giải mã và gọi handler, ack nếu thành công
+func (s *Subscriber) handle(ctx context.Context, handler Handler, msg redis.XMessage, deliveries int64) {
+	e, err := decode(msg)
+	if err != nil {
+		// Event hỏng thì retry cũng không giải mã được, chuyển thẳng sang dead-letter
+		s.deadLetter(ctx, msg, err.Error())
+		return
+	}
+
+	if err := handler(ctx, e); err != nil {
+		fmt.Printf("failed to handle event %s (delivery %d): %v\n", msg.ID, deliveries, err)
+		return
+	}
+
+	if err := s.Client.XAck(ctx, s.stream(), s.Group, msg.ID).Err(); err != nil {
+		fmt.Println("failed to ack event: ", err)
+	}
+}
+
+// deadLetter chép event sang dead-letter stream kèm lý do rồi ack trong cùng MULTI/EXEC
+func (s *Subscriber) deadLetter(ctx context.Context, msg redis.XMessage, reason string) {
+	values := make([]interface{}, 0, len(msg.Values)*2+4)
+	for k, v := range msg.Values {
+		values = append(values, k, v)
+	}
+	values = append(values, "original_id", msg.ID, "error", reason)
+
+	_, err := s.Client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.XAdd(ctx, &redis.XAddArgs{
+			Stream: s.deadLetterStream(),
+			MaxLen: DefaultMaxLen,
+			Approx: true,
+			Values: values,
+		})
+		pipe.XAck(ctx, s.stream(), s.Group, msg.ID)
+		return nil
+	})
+	if err != nil {
+		fmt.Println("failed to move event to dead-letter stream: ", err)
+		return
+	}
+	fmt.Printf("moved event %s to %s: %s\n", msg.ID, s.deadLetterStream(), reason)
+}
+
+// sleep chờ d hoặc đến khi ctx bị hủy
+func (s *Subscriber) sleep(ctx context.Context, d time.Duration) {
+	select {
+	case <-ctx.Done():
+	case <-time.After(d):
+	}
+}
@@ -0,0 +1,104 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Mỗi bước là một vòng của Run: reclaim rồi readNew, sau đó đồng hồ tiến thêm ClaimIdle
+func TestSubscriberDelivery(t *testing.T) {
+	errHandler := errors.New("handler failed")
+
+	tests := []struct {
+		name     string
+		values   map[string]interface{} // event ghi thẳng vào stream, nil thì dùng event hợp lệ
+		failures int                    // số lần đầu handler trả về lỗi
+		rounds   int
+		calls    int   // số lần handler được gọi
+		pending  int64 // event còn chưa ack sau các vòng
+		dead     int64 // event trong dead-letter stream
+	}{
+		{"handled event is acked", nil, 0, 1, 1, 0, 0},
+		{"failed event stays pending", nil, 1, 1, 1, 1, 0},
+		{"failed event is retried after ClaimIdle", nil, 1, 2, 2, 0, 0},
+		{"event delivered too many times goes to dead letter", nil, 10, 4, 3, 0, 1},
+		{"undecodable event goes to dead letter", map[string]interface{}{"type": "order.created", "data": "{"}, 0, 1, 0, 0, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			mr := miniredis.RunT(t)
+			client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+			t.Cleanup(func() { client.Close() })
+
+			now := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+			mr.SetTime(now)
+
+			s := &Subscriber{Client: client, Group: "test", Consumer: "c1", Block: time.Millisecond, ClaimIdle: time.Minute, MaxDeliveries: 3}
+			if err := client.XGroupCreateMkStream(ctx, s.stream(), s.Group, "0").Err(); err != nil {
+				t.Fatalf("XGroupCreateMkStream: %v", err)
+			}
+			if tt.values != nil {
+				client.XAdd(ctx, &redis.XAddArgs{Stream: s.stream(), Values: tt.values})
+			} else {
+				_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+					return Append(ctx, pipe, s.stream(), 0, New(TypeOrderCreated, model.Order{OrderID: 1}, now))
+				})
+				if err != nil {
+					t.Fatalf("Append: %v", err)
+				}
+			}
+
+			calls := 0
+			handler := func(ctx context.Context, e Event) error {
+				calls++
+				if e.OrderID != 1 {
+					t.Fatalf("event order = %d, want 1", e.OrderID)
+				}
+				if calls <= tt.failures {
+					return errHandler
+				}
+				return nil
+			}
+
+			for round := 0; round < tt.rounds; round++ {
+				if err := s.reclaim(ctx, handler); err != nil {
+					t.Fatalf("reclaim: %v", err)
+				}
+				if err := s.readNew(ctx, handler); err != nil {
+					t.Fatalf("readNew: %v", err)
+				}
+				// Chưa đủ ClaimIdle thì event lỗi chưa được giao lại
+				if err := s.reclaim(ctx, handler); err != nil {
+					t.Fatalf("reclaim: %v", err)
+				}
+				now = now.Add(s.ClaimIdle)
+				mr.SetTime(now)
+			}
+
+			if calls != tt.calls {
+				t.Fatalf("handler called %d times, want %d", calls, tt.calls)
+			}
+			pending, err := client.XPending(ctx, s.stream(), s.Group).Result()
+			if err != nil || pending.Count != tt.pending {
+				t.Fatalf("pending = %+v, %v, want %d", pending, err, tt.pending)
+			}
+			if dead, _ := client.XLen(ctx, s.deadLetterStream()).Result(); dead != tt.dead {
+				t.Fatalf("dead-letter length = %d, want %d", dead, tt.dead)
+			}
+		})
+	}
+}
+
+func TestSubscriberRunRequiresGroupAndConsumer(t *testing.T) {
+	s := &Subscriber{Consumer: "c1"}
+	if err := s.Run(context.Background(), nil); err == nil {
+		t.Fatalf("Run without group succeeded")
+	}
+}
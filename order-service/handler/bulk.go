@@ -0,0 +1,386 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/RibunLoc/microservices-learn/bulk"
+	"github.com/RibunLoc/microservices-learn/idgen"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/pricing"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+)
+
+const (
+	exportPageSize  = 100  // số order đọc từ repository mỗi lần khi xuất
+	importBatchSize = 500  // số order ghi vào repository mỗi lần khi nhập
+	maxImportErrors = 1000 // số lỗi tối đa trả về trong báo cáo nhập, các lỗi sau chỉ được đếm
+)
+
+/*
+Export là HTTP handler xuất đơn hàng (GET /orders/export), nhận cùng bộ lọc với List
+(customer_id, status, created_from/created_to, sort), trừ limit và cursor vì xuất toàn bộ.
+Định dạng chọn theo header Accept: text/csv hoặc application/x-ndjson (mặc định).
+
+Dữ liệu được đọc từng trang và ghi ngay ra response nên không giữ toàn bộ đơn trong bộ nhớ.
+Lỗi giữa chừng thì response bị ngắt, client nhận được file thiếu.
+*/
+func (h *Order) Export(w http.ResponseWriter, r *http.Request) {
+	format, ok := bulk.Negotiate(r.Header.Get("Accept"))
+	if !ok {
+		w.WriteHeader(http.StatusNotAcceptable)
+		return
+	}
+
+	page, err := parseListQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page.Size = exportPageSize
+
+	// User thường chỉ được xuất đơn của chính mình
+	caller, ok := identityFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if !caller.IsAdmin() {
+		if page.Filter.CustomerID != "" && page.Filter.CustomerID != caller.UserID {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		page.Filter.CustomerID = caller.UserID
+	}
+
+	enc := bulk.NewEncoder(format, w)
+	flusher, _ := w.(http.Flusher)
+	started := false
+	for {
+		// 1. Đọc một trang, lỗi ở trang đầu tiên vẫn trả được status 500
+		res, err := h.Repo.FindAll(r.Context(), page)
+		if err != nil {
+			fmt.Println("failed to find all: ", err)
+			if !started {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			return
+		}
+		if !started {
+			w.Header().Set("Content-Type", format.ContentType())
+			w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="orders.%s"`, format))
+			w.WriteHeader(http.StatusOK)
+			started = true
+		}
+
+		// 2. Ghi trang ra response
+		for _, o := range res.Orders {
+			if err := enc.Encode(o); err != nil {
+				fmt.Println("failed to export: ", err)
+				return
+			}
+		}
+		if err := enc.Flush(); err != nil {
+			fmt.Println("failed to export: ", err)
+			return
+		}
+		if flusher != nil {
+			flusher.Flush()
+		}
+
+		// 3. Hết dữ liệu thì dừng
+		if res.Next == nil {
+			return
+		}
+		page.After = res.Next
+	}
+}
+
+// importError là lỗi của một order khi nhập, Line là dòng bắt đầu của order trong file
+type importError struct {
+	Line    int    `json:"line"`
+	OrderID uint64 `json:"order_id,omitempty"`
+	Error   string `json:"error"`
+}
+
+// importReport là kết quả của POST /orders/import
+type importReport struct {
+	DryRun          bool          `json:"dry_run"`
+	Total           int           `json:"total"`    // số order đọc được
+	Valid           int           `json:"valid"`    // số order hợp lệ
+	Imported        int           `json:"imported"` // số order đã ghi, luôn là 0 khi dry_run
+	Failed          int           `json:"failed"`   // số order bị lỗi
+	Errors          []importError `json:"errors"`
+	ErrorsTruncated bool          `json:"errors_truncated,omitempty"` // có hơn maxImportErrors lỗi
+	Error           string        `json:"error,omitempty"`            // lỗi làm quá trình nhập dừng giữa chừng
+}
+
+func (rep *importReport) fail(line int, orderID uint64, err error) {
+	rep.Failed++
+	if len(rep.Errors) >= maxImportErrors {
+		rep.ErrorsTruncated = true
+		return
+	}
+	rep.Errors = append(rep.Errors, importError{Line: line, OrderID: orderID, Error: err.Error()})
+}
+
+/*
+Import là HTTP handler nhập đơn hàng hàng loạt (POST /orders/import), chỉ admin được gọi.
+Body là NDJSON (mặc định) hoặc CSV theo header Content-Type, cùng định dạng với Export.
+  - Mỗi order được kiểm tra như khi tạo đơn, order lỗi được báo theo dòng và bị bỏ qua
+  - Order hợp lệ được ghi theo lô importBatchSize bằng Repo.InsertMany
+  - Order ID đã tồn tại (kể cả đã xóa hoặc đã lưu trữ lạnh) không bị ghi đè mà được báo lỗi
+  - ?dry_run=true chỉ kiểm tra (cả ID đã tồn tại), không ghi gì
+
+Đơn được nhập giữ nguyên ID, trạng thái và số tiền, không giữ chỗ hàng hay gọi cổng thanh toán.
+*/
+func (h *Order) Import(w http.ResponseWriter, r *http.Request) {
+	caller, ok := identityFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if !caller.IsAdmin() {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	dryRun := false
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		var err error
+		if dryRun, err = strconv.ParseBool(v); err != nil {
+			http.Error(w, "invalid dry_run", http.StatusBadRequest)
+			return
+		}
+	}
+
+	format, ok := bulk.FormatOf(r.Header.Get("Content-Type"))
+	if !ok {
+		w.WriteHeader(http.StatusUnsupportedMediaType)
+		return
+	}
+	dec, err := bulk.NewDecoder(format, r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	report := importReport{DryRun: dryRun, Errors: []importError{}}
+	seen := map[uint64]int{} // order ID -> dòng đầu tiên có ID đó trong file
+	var batch []bulk.Record
+	var maxID uint64
+	status := http.StatusOK
+
+	// Ghi một lô order hợp lệ, order trùng ID với đơn đã có (kể cả đã xóa hoặc đã lưu trữ lạnh)
+	// được báo lỗi. Dry run cũng kiểm tra ID để báo cáo khớp với lần nhập thật.
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		defer func() { batch = batch[:0] }()
+
+		used, err := h.usedOrderIDs(r.Context(), batch)
+		if err != nil {
+			return err
+		}
+		var orders []model.Order
+		var records []bulk.Record
+		for i, rec := range batch {
+			if used[i] {
+				report.fail(rec.Line, rec.Order.OrderID, order.ErrAlreadyExists)
+				continue
+			}
+			orders = append(orders, rec.Order)
+			records = append(records, rec)
+		}
+		if dryRun || len(orders) == 0 {
+			return nil
+		}
+
+		errs, err := h.Repo.InsertMany(r.Context(), orders)
+		if err != nil {
+			return err
+		}
+		for i, rec := range records {
+			if errs[i] != nil {
+				report.fail(rec.Line, rec.Order.OrderID, errs[i])
+				continue
+			}
+			report.Imported++
+			maxID = max(maxID, rec.Order.OrderID)
+		}
+		return nil
+	}
+
+	for {
+		// 1. Đọc order tiếp theo, lỗi đọc body thì dừng và báo những gì đã nhập
+		rec, err := dec.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			report.Error = err.Error()
+			status = http.StatusBadRequest
+			break
+		}
+		report.Total++
+		if rec.Err != nil {
+			report.fail(rec.Line, rec.Order.OrderID, rec.Err)
+			continue
+		}
+
+		// 2. Kiểm tra order và ID không bị lặp lại trong file
+		if err := h.validateImported(&rec.Order); err != nil {
+			report.fail(rec.Line, rec.Order.OrderID, err)
+			continue
+		}
+		if line, dup := seen[rec.Order.OrderID]; dup {
+			report.fail(rec.Line, rec.Order.OrderID, fmt.Errorf("duplicate order_id, first seen on line %d", line))
+			continue
+		}
+		seen[rec.Order.OrderID] = rec.Line
+		report.Valid++
+
+		// 3. Đủ một lô thì ghi
+		batch = append(batch, rec)
+		if len(batch) >= importBatchSize {
+			if err := flush(); err != nil {
+				fmt.Println("failed to import: ", err)
+				report.Error = err.Error()
+				status = http.StatusInternalServerError
+				break
+			}
+		}
+	}
+	if report.Error == "" {
+		if err := flush(); err != nil {
+			fmt.Println("failed to import: ", err)
+			report.Error = err.Error()
+			status = http.StatusInternalServerError
+		}
+	}
+
+	// 4. Bộ sinh ID không được sinh lại ID của các đơn vừa nhập
+	if maxID > 0 {
+		if adv, ok := h.IDGen.(idgen.Advancer); ok {
+			if err := adv.Advance(context.WithoutCancel(r.Context()), maxID); err != nil {
+				fmt.Println("failed to advance order id: ", err)
+			}
+		}
+	}
+
+	writeJSON(w, status, report)
+}
+
+// usedOrderIDs cho biết order ID của từng record đã được dùng chưa:
+// đơn đang hoạt động, đã xóa mềm hoặc đã nằm trong kho lưu trữ lạnh
+func (h *Order) usedOrderIDs(ctx context.Context, records []bulk.Record) ([]bool, error) {
+	ids := make([]uint64, len(records))
+	for i, rec := range records {
+		ids[i] = rec.Order.OrderID
+	}
+	used, err := h.Repo.Exists(ctx, ids)
+	if err != nil || h.Retention == nil {
+		return used, err
+	}
+	archived, err := h.Retention.Contains(ctx, ids)
+	if err != nil {
+		return nil, err
+	}
+	for i := range used {
+		used[i] = used[i] || archived[i]
+	}
+	return used, nil
+}
+
+// validateImported kiểm tra và chuẩn hóa một order được nhập giống như khi tạo đơn.
+// Số tiền để trống (bằng 0) thì được tính lại bằng Pricing, ngược lại phải khớp với line item.
+func (h *Order) validateImported(o *model.Order) error {
+	if o.OrderID == 0 {
+		return errors.New("order_id is required")
+	}
+	id, err := model.ParseCustomerID(string(o.CustomerID))
+	if err != nil {
+		return err
+	}
+	o.CustomerID = id
+	if !o.OrderStatus.Valid() {
+		return fmt.Errorf("invalid order_status: %q", o.OrderStatus)
+	}
+	if o.CreateAt == nil {
+		return errors.New("created_at is required")
+	}
+	if o.DeletedAt != nil {
+		return errors.New("deleted orders cannot be imported")
+	}
+	if o.Version == 0 {
+		o.Version = 1
+	}
+	if err := validateLineItems(o.LineItems); err != nil {
+		return err
+	}
+	if o.Region, err = model.ParseRegion(o.Region); err != nil {
+		return err
+	}
+
+	// Tiền tệ giống Create: theo order, theo line item đầu tiên, cuối cùng là tiền tệ mặc định
+	currency := o.Currency
+	if currency == "" && len(o.LineItems) > 0 {
+		currency = o.LineItems[0].Price.Currency
+	}
+	if currency == "" {
+		currency = h.Currency
+	}
+	if currency, err = model.ParseCurrency(currency); err != nil {
+		return err
+	}
+	o.SetCurrency(currency)
+	if err := o.CheckCurrency(); err != nil {
+		return err
+	}
+
+	if o.Subtotal.Amount == 0 && o.GrandTotal.Amount == 0 {
+		return h.Pricing.Apply(o)
+	}
+	return checkTotals(o)
+}
+
+// checkTotals kiểm tra số tiền của order nhập vào: Subtotal bằng tổng tiền line item
+// và GrandTotal = Subtotal + Tax + Shipping, tiền tệ để trống thì lấy theo order
+func checkTotals(o *model.Order) error {
+	for _, m := range []*model.Money{&o.Subtotal, &o.Tax, &o.Shipping, &o.GrandTotal} {
+		if m.Currency == "" {
+			m.Currency = o.Currency
+		}
+		if m.Currency != o.Currency {
+			return model.ErrCurrencyMismatch
+		}
+	}
+
+	var subtotal uint64
+	for _, item := range o.LineItems {
+		amount, err := pricing.Mul(item.Price.Amount, uint64(item.Quantity))
+		if err != nil {
+			return err
+		}
+		if subtotal, err = pricing.Add(subtotal, amount); err != nil {
+			return err
+		}
+	}
+	if subtotal != o.Subtotal.Amount {
+		return fmt.Errorf("subtotal %d does not match line items (%d)", o.Subtotal.Amount, subtotal)
+	}
+
+	total, err := pricing.Add(o.Subtotal.Amount, o.Tax.Amount)
+	if err != nil {
+		return err
+	}
+	if total, err = pricing.Add(total, o.Shipping.Amount); err != nil {
+		return err
+	}
+	if total != o.GrandTotal.Amount {
+		return errors.New("grand_total must equal subtotal + tax + shipping")
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+)
+
+// importedOrder trả về một dòng NDJSON của order hợp lệ để nhập
+func importedOrder(t *testing.T, id uint64, change func(o *model.Order)) string {
+	t.Helper()
+	createdAt := time.Date(2025, 1, 31, 14, 5, 0, 0, time.UTC)
+	price := model.Money{Amount: 1000, Currency: "VND"}
+	o := model.Order{
+		OrderID: id, CustomerID: testCustomerID, OrderStatus: model.StatusPaid, CreateAt: &createdAt, Currency: "VND",
+		LineItems: []model.LineItem{{ItemID: testItemID, Quantity: 2, Price: price}},
+		Subtotal:  model.Money{Amount: 2000, Currency: "VND"}, GrandTotal: model.Money{Amount: 2000, Currency: "VND"},
+	}
+	if change != nil {
+		change(&o)
+	}
+	data, err := json.Marshal(o)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	return string(data) + "\n"
+}
+
+// serveImport gọi POST /orders/import với người gọi caller
+func serveImport(h *Order, caller auth.Identity, query, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/orders/import"+query, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	req = req.WithContext(auth.WithIdentity(req.Context(), caller))
+	rec := httptest.NewRecorder()
+	h.Import(rec, req)
+	return rec
+}
+
+func TestImport(t *testing.T) {
+	ctx := context.Background()
+	admin := auth.Identity{UserID: testAdminID, Role: auth.RoleAdmin}
+
+	// Dòng 1-2 hợp lệ, các dòng sau lỗi vì: trạng thái sai, tổng tiền sai, trùng ID trong file,
+	// trùng đơn đang có, trùng đơn đã xóa, JSON hỏng
+	body := importedOrder(t, 10, nil) +
+		importedOrder(t, 11, func(o *model.Order) {
+			o.LineItems = nil
+			o.Subtotal.Amount = 0
+			o.GrandTotal.Amount = 500
+			o.Shipping.Amount = 500
+		}) +
+		importedOrder(t, 12, func(o *model.Order) { o.OrderStatus = "lost" }) +
+		importedOrder(t, 13, func(o *model.Order) { o.GrandTotal.Amount = 1 }) +
+		importedOrder(t, 10, nil) +
+		importedOrder(t, 1, nil) +
+		importedOrder(t, 2, nil) +
+		"{\n"
+	// Dòng của các order lỗi, order trùng đơn đã có được báo khi ghi lô ở cuối file
+	wantErrors := []int{3, 4, 5, 8, 6, 7}
+
+	for _, dryRun := range []bool{true, false} {
+		t.Run(fmt.Sprintf("dry_run=%v", dryRun), func(t *testing.T) {
+			h := &Order{Repo: order.NewMemoryRepo(), Currency: "VND"}
+			insertTestOrder(t, h, model.Order{OrderID: 1, OrderStatus: model.StatusPending})
+			insertTestOrder(t, h, model.Order{OrderID: 2, OrderStatus: model.StatusPending})
+			if err := h.Repo.DeleteByID(ctx, 2, "admin"); err != nil {
+				t.Fatalf("DeleteByID: %v", err)
+			}
+
+			rec := serveImport(h, admin, fmt.Sprintf("?dry_run=%v", dryRun), "application/x-ndjson", body)
+			if rec.Code != http.StatusOK {
+				t.Fatalf("status = %d, body %s", rec.Code, rec.Body.String())
+			}
+			var report importReport
+			if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+				t.Fatalf("decode report: %v", err)
+			}
+
+			// Order trùng đơn đã có chỉ được phát hiện khi ghi lô nên vẫn được tính là hợp lệ
+			wantImported := 2
+			if dryRun {
+				wantImported = 0
+			}
+			if report.DryRun != dryRun || report.Total != 8 || report.Valid != 4 || report.Imported != wantImported || report.Failed != 6 {
+				t.Fatalf("report = %+v", report)
+			}
+			var lines []int
+			for _, e := range report.Errors {
+				lines = append(lines, e.Line)
+			}
+			if fmt.Sprint(lines) != fmt.Sprint(wantErrors) {
+				t.Fatalf("error lines = %v, want %v (%+v)", lines, wantErrors, report.Errors)
+			}
+
+			_, err := h.Repo.FindByID(ctx, 10)
+			if imported := err == nil; imported == dryRun {
+				t.Fatalf("order 10 imported = %v with dry_run=%v", imported, dryRun)
+			}
+		})
+	}
+}
+
+func TestImportRejects(t *testing.T) {
+	admin := auth.Identity{UserID: testAdminID, Role: auth.RoleAdmin}
+	customer := auth.Identity{UserID: testCustomerID, Role: auth.RoleUser}
+
+	tests := []struct {
+		name        string
+		caller      auth.Identity
+		query       string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"only admins", customer, "", "application/x-ndjson", "", http.StatusForbidden},
+		{"invalid dry_run", admin, "?dry_run=maybe", "application/x-ndjson", "", http.StatusBadRequest},
+		{"unsupported format", admin, "", "application/xml", "", http.StatusUnsupportedMediaType},
+		{"csv without header", admin, "", "text/csv", "order_id\n", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Order{Repo: order.NewMemoryRepo(), Currency: "VND"}
+			if rec := serveImport(h, tt.caller, tt.query, tt.contentType, tt.body); rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.status)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/payment"
+)
+
+// Dùng để báo lỗi khi order có thanh toán cần bù trừ nhưng service không cấu hình cổng thanh toán
+var errPaymentsDisabled = errors.New("order has payments but no payment provider is configured")
+
+// Dùng để báo lỗi khi lần thanh toán của order đổi trong lúc đang hủy đơn
+var errPaymentsChanged = errors.New("order payments changed while cancelling, retry the request")
+
+/*
+Cancel là HTTP handler hủy đơn hàng (POST /orders/{id}/cancel, body {"reason": "..."}),
+chỉ chủ đơn hoặc admin, và chỉ trước khi giao hàng:
+ 1. Đơn phải chuyển được sang cancelled (pending, confirmed hoặc paid)
+ 2. Chuyển đơn sang cancelled, ghi lịch sử kèm lý do
+ 3. Hủy giữ tiền của lần thanh toán chưa thu, hoàn tiền đã thu, ghi lại từng lần
+ 4. Trả hàng đang giữ chỗ về kho
+
+Gọi lại với đơn đã hủy trả về 200 và chỉ làm tiếp các bước bù trừ còn dở
+(ví dụ lần trước cổng thanh toán không phản hồi).
+*/
+func (h *Order) Cancel(w http.ResponseWriter, r *http.Request) {
+	orderID, err := parseOrderID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Reason string `json:"reason"` // lý do hủy, ghi vào lịch sử
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	caller, ok := identityFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	theOrder, err := h.cancel(r.Context(), caller, orderID, body.Reason)
+	if err != nil {
+		writeOrderError(w, "cancel", err)
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(theOrder.Version))
+	writeJSON(w, http.StatusOK, theOrder)
+}
+
+// cancel hủy order của caller theo các bước của Cancel, dùng chung cho REST và gRPC CancelOrder
+func (h *Order) cancel(ctx context.Context, caller auth.Identity, orderID uint64, reason string) (model.Order, error) {
+	reason = strings.TrimSpace(reason)
+	if reason == "" {
+		return model.Order{}, rejectRequest(http.StatusBadRequest, errors.New("reason is required"))
+	}
+
+	// 1. Tìm order, đơn đã hủy thì không cần chuyển trạng thái nữa
+	theOrder, err := h.findOwned(ctx, caller, orderID)
+	if err != nil {
+		return model.Order{}, err
+	}
+	pending := activePayments(theOrder)
+	if len(pending) > 0 && h.Payments == nil {
+		return model.Order{}, errPaymentsDisabled
+	}
+
+	// 2. Chuyển đơn sang cancelled trước khi gọi cổng thanh toán để không request nào
+	// giao hay thu tiền đơn này nữa. IllegalTransitionError là đơn đã được giao,
+	// lỗi khi lần thanh toán bị đổi đồng thời thì client gọi lại
+	actor := caller.UserID.String()
+	if lifecycle.Current(theOrder) != model.StatusCancelled {
+		theOrder, err = h.savePayments(ctx, orderID, pending, model.StatusCancelled, actor, reason)
+		if err != nil {
+			return model.Order{}, err
+		}
+	}
+
+	// 3. Bù trừ từng lần thanh toán, lỗi thì lần gọi sau làm tiếp phần còn dở
+	for _, p := range pending {
+		if p, err = h.compensatePayment(ctx, p); err != nil {
+			return model.Order{}, err
+		}
+		if theOrder, err = h.savePayments(ctx, orderID, []model.PaymentIntent{p}, "", actor, ""); err != nil {
+			// Tiền đã bù trừ ở cổng thanh toán nhưng chưa ghi được vào đơn
+			fmt.Println("failed to save payment: ", err)
+			return model.Order{}, err
+		}
+	}
+
+	// 4. Trả hàng về kho
+	h.releaseReservation(ctx, orderID)
+	return theOrder, nil
+}
+
+// activePayments trả về các lần thanh toán còn giữ hoặc đã thu tiền, cần bù trừ khi hủy đơn
+func activePayments(o model.Order) []model.PaymentIntent {
+	var active []model.PaymentIntent
+	for _, p := range o.Payments {
+		switch p.Status {
+		case model.PaymentAuthorized, model.PaymentCaptured, model.PaymentPartiallyRefunded:
+			active = append(active, p)
+		}
+	}
+	return active
+}
+
+// compensatePayment hủy giữ tiền hoặc hoàn số tiền còn lại của một lần thanh toán,
+// trả về lần thanh toán với trạng thái mới
+func (h *Order) compensatePayment(ctx context.Context, p model.PaymentIntent) (model.PaymentIntent, error) {
+	switch p.Status {
+	case model.PaymentAuthorized:
+		// Cổng thanh toán không còn giao dịch (mất dữ liệu hoặc đơn được nhập) thì coi như đã hủy
+		if err := h.Payments.Void(ctx, p.IntentID); err != nil && !errors.Is(err, payment.ErrUnknownIntent) {
+			return p, err
+		}
+		p.Status = model.PaymentVoided
+	default:
+		// Không còn tiền để hoàn thì không gọi cổng thanh toán
+		if amount := p.Refundable(); amount > 0 {
+			if err := h.Payments.Refund(ctx, p.IntentID, model.Money{Amount: amount, Currency: p.Amount.Currency}); err != nil {
+				return p, err
+			}
+		}
+		p.Refunded = p.Captured
+		p.Status = model.PaymentRefunded
+	}
+	p.UpdatedAt = time.Now().UTC()
+	return p, nil
+}
@@ -0,0 +1,179 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/payment"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+)
+
+var testTotal = model.Money{Amount: 3000, Currency: "VND"}
+
+func newPaymentHandler(t *testing.T) (*Order, *payment.Fake) {
+	t.Helper()
+	fake, err := payment.NewFake(payment.BehaviorApprove)
+	if err != nil {
+		t.Fatalf("NewFake: %v", err)
+	}
+	return &Order{Repo: order.NewMemoryRepo(), Payments: fake}, fake
+}
+
+// newIntent giữ tiền (và thu tiền nếu capture) ở cổng thanh toán giả, trả về lần thanh toán tương ứng
+func newIntent(t *testing.T, fake *payment.Fake, orderID uint64, capture bool) model.PaymentIntent {
+	t.Helper()
+	ctx := context.Background()
+	id, err := fake.Authorize(ctx, orderID, testTotal)
+	if err != nil {
+		t.Fatalf("Authorize: %v", err)
+	}
+	p := model.PaymentIntent{IntentID: id, Provider: fake.Name(), Amount: testTotal, Status: model.PaymentAuthorized}
+	if capture {
+		if err := fake.Capture(ctx, id, testTotal); err != nil {
+			t.Fatalf("Capture: %v", err)
+		}
+		p.Captured = testTotal.Amount
+		p.Status = model.PaymentCaptured
+	}
+	return p
+}
+
+func insertTestOrder(t *testing.T, h *Order, o model.Order) {
+	t.Helper()
+	createdAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	o.CustomerID = testCustomerID
+	if o.GrandTotal.Currency == "" {
+		o.GrandTotal = testTotal
+	}
+	o.Version = 1
+	o.CreateAt = &createdAt
+	if err := h.Repo.Insert(context.Background(), o); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+}
+
+func TestCancel(t *testing.T) {
+	customer := auth.Identity{UserID: testCustomerID, Role: auth.RoleUser}
+
+	tests := []struct {
+		name     string
+		status   model.Status
+		payments func(t *testing.T, fake *payment.Fake) []model.PaymentIntent
+		want     []model.PaymentStatus // trạng thái các lần thanh toán sau khi hủy
+	}{
+		{"pending without payment", model.StatusPending, nil, nil},
+		{"authorized payment is voided", model.StatusConfirmed, func(t *testing.T, fake *payment.Fake) []model.PaymentIntent {
+			return []model.PaymentIntent{newIntent(t, fake, 1, false)}
+		}, []model.PaymentStatus{model.PaymentVoided}},
+		{"captured payment is refunded", model.StatusPaid, func(t *testing.T, fake *payment.Fake) []model.PaymentIntent {
+			return []model.PaymentIntent{newIntent(t, fake, 1, true)}
+		}, []model.PaymentStatus{model.PaymentRefunded}},
+		{"intent unknown to the provider counts as voided", model.StatusConfirmed, func(t *testing.T, fake *payment.Fake) []model.PaymentIntent {
+			return []model.PaymentIntent{{IntentID: "pi_imported", Amount: testTotal, Status: model.PaymentAuthorized}}
+		}, []model.PaymentStatus{model.PaymentVoided}},
+		{"nothing left to refund", model.StatusPaid, func(t *testing.T, fake *payment.Fake) []model.PaymentIntent {
+			return []model.PaymentIntent{{IntentID: "pi_imported", Amount: model.Money{Currency: "VND"}, Status: model.PaymentCaptured}}
+		}, []model.PaymentStatus{model.PaymentRefunded}},
+		{"already cancelled retries compensation", model.StatusCancelled, func(t *testing.T, fake *payment.Fake) []model.PaymentIntent {
+			return []model.PaymentIntent{newIntent(t, fake, 1, false)}
+		}, []model.PaymentStatus{model.PaymentVoided}},
+		{"declined payment is left alone", model.StatusConfirmed, func(t *testing.T, fake *payment.Fake) []model.PaymentIntent {
+			return []model.PaymentIntent{{Amount: testTotal, Status: model.PaymentDeclined}}
+		}, []model.PaymentStatus{model.PaymentDeclined}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			h, fake := newPaymentHandler(t)
+			o := model.Order{OrderID: 1, OrderStatus: tt.status}
+			if tt.payments != nil {
+				o.Payments = tt.payments(t, fake)
+			}
+			insertTestOrder(t, h, o)
+
+			cancelled, err := h.cancel(ctx, customer, 1, "changed my mind")
+			if err != nil {
+				t.Fatalf("cancel: %v", err)
+			}
+			saved, _ := h.Repo.FindByID(ctx, 1)
+			if cancelled.OrderStatus != model.StatusCancelled || saved.OrderStatus != model.StatusCancelled || saved.Version != cancelled.Version {
+				t.Fatalf("cancel = %+v, saved %+v", cancelled, saved)
+			}
+			if len(saved.Payments) != len(tt.want) {
+				t.Fatalf("payments = %+v, want %v", saved.Payments, tt.want)
+			}
+			for i, status := range tt.want {
+				if saved.Payments[i].Status != status {
+					t.Fatalf("payment %d status = %s, want %s", i, saved.Payments[i].Status, status)
+				}
+			}
+
+			// Tiền đã hoàn ở cổng thanh toán thì không hoàn thêm được
+			for _, p := range saved.Payments {
+				if p.Status == model.PaymentRefunded && p.Captured > 0 {
+					if err := fake.Refund(ctx, p.IntentID, model.Money{Amount: 1, Currency: "VND"}); !errors.Is(err, payment.ErrAmountExceeded) {
+						t.Fatalf("provider refund after cancel error = %v, want ErrAmountExceeded", err)
+					}
+				}
+			}
+
+			// Gọi lại thì không đổi gì
+			again, err := h.cancel(ctx, customer, 1, "changed my mind")
+			if err != nil || again.Version != saved.Version {
+				t.Fatalf("second cancel = %+v, %v", again, err)
+			}
+		})
+	}
+
+	t.Run("rejected", func(t *testing.T) {
+		ctx := context.Background()
+		h, _ := newPaymentHandler(t)
+		insertTestOrder(t, h, model.Order{OrderID: 1, OrderStatus: model.StatusShipped})
+		insertTestOrder(t, h, model.Order{OrderID: 2, OrderStatus: model.StatusPending})
+
+		var illegal *lifecycle.IllegalTransitionError
+		if _, err := h.cancel(ctx, customer, 1, "late"); !errors.As(err, &illegal) {
+			t.Fatalf("cancel shipped order error = %v, want IllegalTransitionError", err)
+		}
+		var reqErr *requestError
+		if _, err := h.cancel(ctx, customer, 2, "  "); !errors.As(err, &reqErr) || reqErr.status != http.StatusBadRequest {
+			t.Fatalf("cancel without reason error = %v, want 400", err)
+		}
+		stranger := auth.Identity{UserID: testAdminID, Role: auth.RoleUser}
+		if _, err := h.cancel(ctx, stranger, 2, "not mine"); !errors.Is(err, order.ErrNotExist) {
+			t.Fatalf("cancel other customer's order error = %v, want ErrNotExist", err)
+		}
+	})
+}
+
+// Lần thanh toán mới xuất hiện giữa lúc cancel đọc và ghi order thì không được ghi đè trạng thái cancelled
+func TestSavePaymentsRefusesUnseenPayments(t *testing.T) {
+	ctx := context.Background()
+	h, fake := newPaymentHandler(t)
+	seen := newIntent(t, fake, 1, false)
+	insertTestOrder(t, h, model.Order{OrderID: 1, OrderStatus: model.StatusConfirmed, Payments: []model.PaymentIntent{seen}})
+
+	// Pay thu tiền lần giữ tiền mà cancel đã thấy
+	captured := seen
+	captured.Captured = testTotal.Amount
+	captured.Status = model.PaymentCaptured
+	if _, err := h.savePayments(ctx, 1, []model.PaymentIntent{captured}, "", "test", ""); err != nil {
+		t.Fatalf("savePayments: %v", err)
+	}
+
+	var reqErr *requestError
+	_, err := h.savePayments(ctx, 1, []model.PaymentIntent{seen}, model.StatusCancelled, "test", "cancel")
+	if !errors.As(err, &reqErr) || reqErr.status != http.StatusConflict || !errors.Is(err, errPaymentsChanged) {
+		t.Fatalf("savePayments error = %v, want errPaymentsChanged", err)
+	}
+	saved, _ := h.Repo.FindByID(ctx, 1)
+	if saved.OrderStatus != model.StatusConfirmed || saved.Payments[0].Status != model.PaymentCaptured {
+		t.Fatalf("order changed after refused save: %+v", saved)
+	}
+}
@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/repository/order"
+)
+
+// Dùng để báo lỗi khi cursor bị sửa, không phải do service tạo ra hoặc dùng với bộ lọc khác
+var errInvalidCursor = errors.New("invalid cursor")
+
+// cursorToken là nội dung của cursor trả về cho client ở GET /orders
+type cursorToken struct {
+	CreatedAt int64  `json:"c"`  // thời điểm tạo của order cuối trang (unix nano)
+	OrderID   uint64 `json:"id"` // ID của order cuối trang
+	Query     string `json:"q"`  // dấu vân tay của bộ lọc và thứ tự sắp xếp
+}
+
+// queryFingerprint tóm tắt bộ lọc và thứ tự sắp xếp của trang,
+// cursor chỉ dùng được với đúng bộ lọc đã tạo ra nó
+func queryFingerprint(page order.FindAllPage) string {
+	formatBound := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.UTC().Format(time.RFC3339Nano)
+	}
+	q := strings.Join([]string{
+		page.Filter.CustomerID.String(),
+		string(page.Filter.Status),
+		formatBound(page.Filter.CreatedFrom),
+		formatBound(page.Filter.CreatedTo),
+		string(page.Sort),
+	}, "|")
+	sum := sha256.Sum256([]byte(q))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (h *Order) signCursor(payload string) string {
+	mac := hmac.New(sha256.New, h.CursorKey)
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+/*
+encodeCursor tạo cursor cho trang sau dạng "<payload>.<chữ ký>" (base64url).
+Client chỉ cần gửi lại nguyên văn, chữ ký HMAC-SHA256 bằng CursorKey
+đảm bảo cursor không bị sửa để đọc đơn ngoài bộ lọc.
+*/
+func (h *Order) encodeCursor(pos *order.Position, page order.FindAllPage) (string, error) {
+	data, err := json.Marshal(cursorToken{
+		CreatedAt: pos.CreatedAt.UnixNano(),
+		OrderID:   pos.OrderID,
+		Query:     queryFingerprint(page),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to encode cursor: %w", err)
+	}
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + h.signCursor(payload), nil
+}
+
+// decodeCursor kiểm tra chữ ký và bộ lọc của cursor rồi trả về vị trí bắt đầu của trang
+func (h *Order) decodeCursor(s string, page order.FindAllPage) (*order.Position, error) {
+	payload, sig, ok := strings.Cut(s, ".")
+	if !ok || !hmac.Equal([]byte(sig), []byte(h.signCursor(payload))) {
+		return nil, errInvalidCursor
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var token cursorToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, errInvalidCursor
+	}
+	if token.Query != queryFingerprint(page) {
+		return nil, fmt.Errorf("%w: cursor was created with different filters", errInvalidCursor)
+	}
+
+	return &order.Position{
+		CreatedAt: time.Unix(0, token.CreatedAt).UTC(),
+		OrderID:   token.OrderID,
+	}, nil
+}
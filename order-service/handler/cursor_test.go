@@ -0,0 +1,226 @@
+package handler
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+)
+
+const (
+	testAdminID    = model.CustomerID("64b7f3a2c9e1d2a3b4c5d6e1")
+	testCustomerID = model.CustomerID("64b7f3a2c9e1d2a3b4c5d6e2")
+)
+
+func TestCursorRoundTrip(t *testing.T) {
+	h := &Order{CursorKey: []byte("cursor-key")}
+	from := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	pos := &order.Position{CreatedAt: time.Date(2025, 1, 31, 14, 5, 0, 123456789, time.UTC), OrderID: 42}
+
+	tests := []struct {
+		name string
+		page order.FindAllPage
+	}{
+		{"no filter", order.FindAllPage{}},
+		{"descending", order.FindAllPage{Sort: order.SortCreatedAtDesc}},
+		{"customer and status", order.FindAllPage{Filter: order.OrderFilter{CustomerID: testCustomerID, Status: model.StatusPaid}}},
+		{"created range", order.FindAllPage{Filter: order.OrderFilter{CreatedFrom: &from, CreatedTo: &pos.CreatedAt}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, err := h.encodeCursor(pos, tt.page)
+			if err != nil {
+				t.Fatalf("encodeCursor: %v", err)
+			}
+			got, err := h.decodeCursor(cursor, tt.page)
+			if err != nil {
+				t.Fatalf("decodeCursor: %v", err)
+			}
+			if !got.CreatedAt.Equal(pos.CreatedAt) || got.OrderID != pos.OrderID {
+				t.Fatalf("decodeCursor = %+v, want %+v", got, pos)
+			}
+		})
+	}
+}
+
+func TestDecodeCursorRejects(t *testing.T) {
+	h := &Order{CursorKey: []byte("cursor-key")}
+	page := order.FindAllPage{Filter: order.OrderFilter{CustomerID: testCustomerID}}
+	pos := &order.Position{CreatedAt: time.Unix(1700000000, 0).UTC(), OrderID: 7}
+
+	cursor, err := h.encodeCursor(pos, page)
+	if err != nil {
+		t.Fatalf("encodeCursor: %v", err)
+	}
+	payload, sig, _ := strings.Cut(cursor, ".")
+
+	// Sửa order ID trong payload nhưng giữ nguyên chữ ký
+	data, _ := base64.RawURLEncoding.DecodeString(payload)
+	var token cursorToken
+	json.Unmarshal(data, &token)
+	token.OrderID = 1
+	data, _ = json.Marshal(token)
+	forged := base64.RawURLEncoding.EncodeToString(data) + "." + sig
+
+	otherKey := &Order{CursorKey: []byte("other-key")}
+	foreign, _ := otherKey.encodeCursor(pos, page)
+
+	tests := []struct {
+		name   string
+		cursor string
+		page   order.FindAllPage
+	}{
+		{"empty", "", page},
+		{"no signature", payload, page},
+		{"tampered payload", forged, page},
+		{"tampered signature", payload + "." + strings.Repeat("A", len(sig)), page},
+		{"signed with another key", foreign, page},
+		{"not base64", "!!!." + h.signCursor("!!!"), page},
+		{"different customer", cursor, order.FindAllPage{Filter: order.OrderFilter{CustomerID: testAdminID}}},
+		{"different status", cursor, order.FindAllPage{Filter: order.OrderFilter{CustomerID: testCustomerID, Status: model.StatusPending}}},
+		{"different sort", cursor, order.FindAllPage{Filter: page.Filter, Sort: order.SortCreatedAtDesc}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := h.decodeCursor(tt.cursor, tt.page); !errors.Is(err, errInvalidCursor) {
+				t.Fatalf("decodeCursor error = %v, want errInvalidCursor", err)
+			}
+		})
+	}
+}
+
+// listResponse là body của GET /orders
+type listResponse struct {
+	Items   []model.Order `json:"items"`
+	Next    string        `json:"next"`
+	HasMore bool          `json:"has_more"`
+}
+
+func list(t *testing.T, h *Order, caller auth.Identity, query url.Values) (int, listResponse) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/orders?"+query.Encode(), nil)
+	req = req.WithContext(auth.WithIdentity(req.Context(), caller))
+	rec := httptest.NewRecorder()
+	h.List(rec, req)
+
+	var res listResponse
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+	}
+	return rec.Code, res
+}
+
+// Đi hết các trang của GET /orders trên MemoryRepo phải ra đúng thứ tự của một trang lớn,
+// kể cả khi nhiều đơn có cùng thời điểm tạo
+func TestListPagesWithCursor(t *testing.T) {
+	repo := order.NewMemoryRepo()
+	base := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	for id := uint64(1); id <= 11; id++ {
+		createdAt := base.Add(time.Duration(id/4) * time.Millisecond) // 4 đơn mỗi thời điểm
+		customer := testCustomerID
+		if id%3 == 0 {
+			customer = testAdminID
+		}
+		err := repo.Insert(context.Background(), model.Order{
+			OrderID:     id,
+			CustomerID:  customer,
+			OrderStatus: model.StatusPending,
+			Version:     1,
+			CreateAt:    &createdAt,
+		})
+		if err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+	}
+	h := &Order{Repo: repo, CursorKey: []byte("cursor-key")}
+	admin := auth.Identity{UserID: testAdminID, Role: auth.RoleAdmin}
+	customer := auth.Identity{UserID: testCustomerID, Role: auth.RoleUser}
+
+	tests := []struct {
+		name   string
+		caller auth.Identity
+		query  url.Values
+	}{
+		{"all ascending", admin, url.Values{}},
+		{"all descending", admin, url.Values{"sort": {"-created_at"}}},
+		{"customer only sees own orders", customer, url.Values{}},
+		{"filter by customer", admin, url.Values{"customer_id": {testAdminID.String()}, "sort": {"-created_at"}}},
+	}
+	for _, tt := range tests {
+		for _, limit := range []string{"1", "3", "4", "100"} {
+			t.Run(tt.name+"/limit="+limit, func(t *testing.T) {
+				query := url.Values{"limit": {"100"}}
+				for k, v := range tt.query {
+					query[k] = v
+				}
+				_, all := list(t, h, tt.caller, query)
+				if all.HasMore || len(all.Items) == 0 {
+					t.Fatalf("full page: has_more=%v items=%d", all.HasMore, len(all.Items))
+				}
+
+				query.Set("limit", limit)
+				var got []uint64
+				for pages := 0; ; pages++ {
+					if pages > len(all.Items) {
+						t.Fatalf("too many pages")
+					}
+					status, res := list(t, h, tt.caller, query)
+					if status != http.StatusOK {
+						t.Fatalf("status = %d", status)
+					}
+					for _, o := range res.Items {
+						got = append(got, o.OrderID)
+					}
+					if res.HasMore != (res.Next != "") {
+						t.Fatalf("has_more=%v but next=%q", res.HasMore, res.Next)
+					}
+					if !res.HasMore {
+						break
+					}
+					query.Set("cursor", res.Next)
+				}
+
+				if len(got) != len(all.Items) {
+					t.Fatalf("got %d orders across pages, want %d", len(got), len(all.Items))
+				}
+				for i, o := range all.Items {
+					if got[i] != o.OrderID {
+						t.Fatalf("order %d = %d, want %d (got %v)", i, got[i], o.OrderID, got)
+					}
+				}
+			})
+		}
+	}
+}
+
+// Cursor của trang này không dùng được khi đổi bộ lọc
+func TestListRejectsCursorWithOtherFilter(t *testing.T) {
+	repo := order.NewMemoryRepo()
+	for id := uint64(1); id <= 3; id++ {
+		createdAt := time.Unix(int64(id), 0).UTC()
+		repo.Insert(context.Background(), model.Order{OrderID: id, CustomerID: testCustomerID, OrderStatus: model.StatusPending, Version: 1, CreateAt: &createdAt})
+	}
+	h := &Order{Repo: repo, CursorKey: []byte("cursor-key")}
+	admin := auth.Identity{UserID: testAdminID, Role: auth.RoleAdmin}
+
+	_, first := list(t, h, admin, url.Values{"limit": {"1"}})
+	if !first.HasMore {
+		t.Fatalf("expected more pages")
+	}
+	status, _ := list(t, h, admin, url.Values{"limit": {"1"}, "cursor": {first.Next}, "status": {"pending"}})
+	if status != http.StatusBadRequest {
+		t.Fatalf("status = %d, want 400", status)
+	}
+}
@@ -0,0 +1,123 @@
+package handler
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/idempotency"
+)
+
+// Header client gửi kèm để request POST có thể retry an toàn
+const idempotencyKeyHeader = "Idempotency-Key"
+
+// Độ dài tối đa của Idempotency-Key
+const maxIdempotencyKeyLength = 255
+
+// responseRecorder ghi lại status và body của response để lưu cho lần replay sau
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (rec *responseRecorder) WriteHeader(status int) {
+	if rec.status == 0 {
+		rec.status = status
+	}
+	rec.ResponseWriter.WriteHeader(status)
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+/*
+withIdempotency bọc một handler để hỗ trợ header Idempotency-Key:
+  - Lần đầu: giữ chỗ key, chạy handler và lưu response (trừ lỗi 5xx).
+  - Lặp lại với cùng body: trả lại đúng response đã lưu, không chạy handler.
+  - Lặp lại với body khác: 422 Unprocessable Entity.
+  - Request đầu tiên vẫn đang chạy: 409 Conflict.
+*/
+func (h *Order) withIdempotency(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyKeyHeader)
+		if key == "" || h.Idempotency == nil {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			http.Error(w, "Idempotency-Key too long", http.StatusBadRequest)
+			return
+		}
+
+		// Key chỉ có nghĩa trong phạm vi một user, tránh user khác dùng trùng key
+		// nhận lại response (đơn hàng) của người khác
+		if id, ok := auth.FromContext(r.Context()); ok {
+			key = id.UserID.String() + ":" + key
+		}
+
+		// 1. Đọc body để tính fingerprint, sau đó trả lại body cho handler
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := idempotency.Fingerprint(r.Method, r.URL.Path, body)
+
+		// 2. Giữ chỗ key, nếu key đã có thì so sánh với request trước đó
+		rec, started, err := h.Idempotency.Begin(r.Context(), key, fingerprint)
+		if err != nil {
+			fmt.Println("failed to begin idempotent request: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		if !started {
+			switch {
+			case rec.Fingerprint != fingerprint:
+				http.Error(w, "Idempotency-Key was used with a different request body", http.StatusUnprocessableEntity)
+			case !rec.Completed:
+				http.Error(w, "A request with this Idempotency-Key is still being processed", http.StatusConflict)
+			default:
+				// Trả lại đúng response của lần đầu
+				if rec.ContentType != "" {
+					w.Header().Set("Content-Type", rec.ContentType)
+				}
+				w.Header().Set("Idempotent-Replayed", "true")
+				w.WriteHeader(rec.StatusCode)
+				w.Write(rec.Body)
+			}
+			return
+		}
+
+		// 3. Chạy handler và ghi lại response
+		recorder := &responseRecorder{ResponseWriter: w}
+		next(recorder, r)
+
+		// 4. Lỗi phía server thì bỏ key để client retry được, ngược lại lưu response
+		if recorder.status == 0 || recorder.status >= http.StatusInternalServerError {
+			if err := h.Idempotency.Release(r.Context(), key); err != nil {
+				fmt.Println("failed to release idempotency key: ", err)
+			}
+			return
+		}
+
+		err = h.Idempotency.Complete(r.Context(), key, idempotency.Record{
+			Fingerprint: fingerprint,
+			StatusCode:  recorder.status,
+			ContentType: w.Header().Get("Content-Type"),
+			Body:        recorder.body.Bytes(),
+		})
+		if err != nil {
+			fmt.Println("failed to save idempotent response: ", err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/idempotency"
+)
+
+// idempotentCall là một request gửi qua withIdempotency và response mong đợi
+type idempotentCall struct {
+	caller   auth.Identity
+	key      string
+	body     string
+	status   int
+	replayed bool
+}
+
+func TestWithIdempotency(t *testing.T) {
+	customer := auth.Identity{UserID: testCustomerID, Role: auth.RoleUser}
+	other := auth.Identity{UserID: testAdminID, Role: auth.RoleUser}
+
+	tests := []struct {
+		name     string
+		inFlight string // key đã được request cùng body giữ chỗ nhưng chưa xong
+		calls    []idempotentCall
+		runs     int // số lần handler thật sự chạy
+	}{
+		{"replay returns the saved response", "", []idempotentCall{
+			{customer, "k1", `{"a":1}`, http.StatusCreated, false},
+			{customer, "k1", `{"a":1}`, http.StatusCreated, true},
+		}, 1},
+		{"different body with the same key", "", []idempotentCall{
+			{customer, "k1", `{"a":1}`, http.StatusCreated, false},
+			{customer, "k1", `{"a":2}`, http.StatusUnprocessableEntity, false},
+		}, 1},
+		{"first request still running", "k1", []idempotentCall{
+			{customer, "k1", `{"a":1}`, http.StatusConflict, false},
+		}, 0},
+		{"server error releases the key", "", []idempotentCall{
+			{customer, "k1", "fail", http.StatusInternalServerError, false},
+			{customer, "k1", "fail", http.StatusInternalServerError, false},
+		}, 2},
+		{"keys are scoped per user", "", []idempotentCall{
+			{customer, "k1", `{"a":1}`, http.StatusCreated, false},
+			{other, "k1", `{"a":1}`, http.StatusCreated, false},
+		}, 2},
+		{"no key runs every time", "", []idempotentCall{
+			{customer, "", `{"a":1}`, http.StatusCreated, false},
+			{customer, "", `{"a":1}`, http.StatusCreated, false},
+		}, 2},
+		{"key too long", "", []idempotentCall{
+			{customer, strings.Repeat("k", maxIdempotencyKeyLength+1), `{"a":1}`, http.StatusBadRequest, false},
+		}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			store := idempotency.NewMemoryStore(0)
+			h := &Order{Idempotency: store}
+			if tt.inFlight != "" {
+				fingerprint := idempotency.Fingerprint(http.MethodPost, "/orders", []byte(tt.calls[0].body))
+				store.Begin(context.Background(), customer.UserID.String()+":"+tt.inFlight, fingerprint)
+			}
+
+			runs := 0
+			handle := h.withIdempotency(func(w http.ResponseWriter, r *http.Request) {
+				runs++
+				body, _ := io.ReadAll(r.Body)
+				if string(body) == "fail" {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusCreated)
+				fmt.Fprintf(w, `{"run":%d}`, runs)
+			})
+
+			var first string
+			for i, call := range tt.calls {
+				req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(call.body))
+				if call.key != "" {
+					req.Header.Set(idempotencyKeyHeader, call.key)
+				}
+				req = req.WithContext(auth.WithIdentity(req.Context(), call.caller))
+				rec := httptest.NewRecorder()
+				handle(rec, req)
+
+				if rec.Code != call.status {
+					t.Fatalf("call %d: status = %d, want %d", i, rec.Code, call.status)
+				}
+				if replayed := rec.Header().Get("Idempotent-Replayed") == "true"; replayed != call.replayed {
+					t.Fatalf("call %d: replayed = %v, want %v", i, replayed, call.replayed)
+				}
+				if i == 0 {
+					first = rec.Body.String()
+				}
+				if call.replayed && (rec.Body.String() != first || rec.Header().Get("Content-Type") != "application/json") {
+					t.Fatalf("call %d: replayed %q (%s), want %q", i, rec.Body.String(), rec.Header().Get("Content-Type"), first)
+				}
+			}
+			if runs != tt.runs {
+				t.Fatalf("handler ran %d times, want %d", runs, tt.runs)
+			}
+		})
+	}
+}
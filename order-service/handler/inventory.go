@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// Inventory là HTTP handler xem và nhập số lượng hàng trong kho
+type Inventory struct {
+	Store *inventory.RedisStore
+}
+
+// GetStock là HTTP handler trả về tồn kho của một mặt hàng (GET /inventory/{item_id})
+func (h *Inventory) GetStock(w http.ResponseWriter, r *http.Request) {
+	itemID, err := uuid.Parse(chi.URLParam(r, "item_id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	stock, err := h.Store.GetStock(r.Context(), itemID)
+	if err != nil {
+		fmt.Println("failed to get stock: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stock)
+}
+
+// SetStock là HTTP handler đặt số lượng thực có trong kho của một mặt hàng
+// (PUT /inventory/{item_id}, body {"on_hand": 100}), chỉ admin được gọi
+func (h *Inventory) SetStock(w http.ResponseWriter, r *http.Request) {
+	itemID, err := uuid.Parse(chi.URLParam(r, "item_id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	caller, ok := identityFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if !caller.IsAdmin() {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var body struct {
+		OnHand *uint64 `json:"on_hand"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.OnHand == nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// Số lượng đang giữ chỗ không đổi, chỉ đặt lại số lượng thực có
+	if err := h.Store.SetStock(r.Context(), itemID, *body.OnHand); err != nil {
+		fmt.Println("failed to set stock: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	stock, err := h.Store.GetStock(r.Context(), itemID)
+	if err != nil {
+		fmt.Println("failed to get stock: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, http.StatusOK, stock)
+}
@@ -0,0 +1,297 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/catalog"
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+var (
+	errItemNotFound     = errors.New("line item not found")
+	errItemExists       = errors.New("line item already exists")
+	errInvalidItemID    = errors.New("item_id is required")
+	errInvalidQuantity  = errors.New("quantity must be greater than 0")
+	errDuplicateItemIDs = errors.New("line items must have distinct item_id")
+	errClientPrice      = errors.New("price cannot be set without the catalog service, only admins can enter prices")
+)
+
+// validateLineItem kiểm tra một line item client gửi lên
+func validateLineItem(item model.LineItem) error {
+	if item.ItemID == uuid.Nil {
+		return errInvalidItemID
+	}
+	if item.Quantity == 0 {
+		return errInvalidQuantity
+	}
+	return nil
+}
+
+// validateLineItems kiểm tra từng line item và item_id không bị trùng
+func validateLineItems(items []model.LineItem) error {
+	seen := make(map[uuid.UUID]bool, len(items))
+	for _, item := range items {
+		if err := validateLineItem(item); err != nil {
+			return err
+		}
+		if seen[item.ItemID] {
+			return errDuplicateItemIDs
+		}
+		seen[item.ItemID] = true
+	}
+	return nil
+}
+
+// resolveLineItem thay giá của line item bằng giá hiện tại trong catalog theo currency
+// và gán tên, SKU của mặt hàng. Không cấu hình catalog thì chỉ admin được nhập giá,
+// khách hàng gửi giá lên bị từ chối để không tự đặt giá được.
+func (h *Order) resolveLineItem(ctx context.Context, caller auth.Identity, item *model.LineItem, currency string) error {
+	if h.Catalog == nil {
+		if item.Price.Amount != 0 && !caller.IsAdmin() {
+			return rejectRequest(http.StatusBadRequest, errClientPrice)
+		}
+		return nil
+	}
+	resolved, err := h.Catalog.Resolve(ctx, item.ItemID, currency)
+	if err != nil {
+		return err
+	}
+	item.SKU = resolved.SKU
+	item.Name = resolved.Name
+	item.Price = resolved.Price
+	return nil
+}
+
+// isCatalogError cho biết err có phải lỗi khi tra cứu catalog hay không
+func isCatalogError(err error) bool {
+	return errors.Is(err, catalog.ErrUnknownItem) || errors.Is(err, catalog.ErrDiscontinued) ||
+		errors.Is(err, catalog.ErrNoPrice) || errors.Is(err, catalog.ErrUnavailable)
+}
+
+// writeCatalogError trả về 422 nếu mặt hàng không đặt được, 503 nếu không gọi được catalog-service
+func writeCatalogError(w http.ResponseWriter, err error) {
+	if errors.Is(err, catalog.ErrUnavailable) {
+		fmt.Println("failed to resolve line item: ", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+}
+
+// parseItemID lấy tham số "item_id" từ URL path
+func parseItemID(r *http.Request) (uuid.UUID, error) {
+	return uuid.Parse(chi.URLParam(r, "item_id"))
+}
+
+// HTTP handler thêm một line item vào đơn hàng (POST /orders/{id}/items)
+func (h *Order) AddItem(w http.ResponseWriter, r *http.Request) {
+	var item model.LineItem
+	if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if err := validateLineItem(item); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	caller, ok := identityFromRequest(w, r)
+	if !ok {
+		return
+	}
+
+	h.editLineItems(w, r, http.StatusCreated, func(o *model.Order) error {
+		if o.FindLineItem(item.ItemID) >= 0 {
+			return errItemExists
+		}
+		if err := h.resolveLineItem(r.Context(), caller, &item, o.Currency); err != nil {
+			return err
+		}
+		// Không ghi tiền tệ thì dùng tiền tệ của đơn, khác tiền tệ sẽ bị từ chối khi tính tiền
+		if item.Price.Currency == "" {
+			item.Price.Currency = o.Currency
+		}
+		o.LineItems = append(o.LineItems, item)
+		return nil
+	})
+}
+
+// HTTP handler sửa số lượng của một line item (PATCH /orders/{id}/items/{item_id})
+func (h *Order) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := parseItemID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		Quantity uint `json:"quantity"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	if body.Quantity == 0 {
+		http.Error(w, errInvalidQuantity.Error(), http.StatusBadRequest)
+		return
+	}
+
+	h.editLineItems(w, r, http.StatusOK, func(o *model.Order) error {
+		i := o.FindLineItem(itemID)
+		if i < 0 {
+			return errItemNotFound
+		}
+		o.LineItems[i].Quantity = body.Quantity
+		return nil
+	})
+}
+
+// HTTP handler xóa một line item khỏi đơn hàng (DELETE /orders/{id}/items/{item_id})
+func (h *Order) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	itemID, err := parseItemID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	h.editLineItems(w, r, http.StatusOK, func(o *model.Order) error {
+		i := o.FindLineItem(itemID)
+		if i < 0 {
+			return errItemNotFound
+		}
+		o.LineItems = append(o.LineItems[:i], o.LineItems[i+1:]...)
+		return nil
+	})
+}
+
+/*
+editLineItems là các bước chung khi sửa line item:
+ 1. Đọc order, chỉ chủ đơn hoặc admin mới được sửa
+ 2. Bắt buộc If-Match khớp version hiện tại (giống PUT /orders/{id})
+ 3. Chỉ sửa được khi đơn chưa thu tiền và chưa giao hàng
+ 4. Gọi edit để sửa LineItems rồi tính lại tổng tiền
+ 5. Giữ chỗ lại hàng trong kho theo line item mới
+ 6. Ghi bằng Repo.Update (compare-and-set theo version) và trả về order mới kèm ETag
+*/
+func (h *Order) editLineItems(w http.ResponseWriter, r *http.Request, status int, edit func(o *model.Order) error) {
+	orderID, err := parseOrderID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		http.Error(w, "If-Match header is required", http.StatusPreconditionRequired)
+		return
+	}
+
+	// 1. Tìm order
+	theOrder, ok := h.findOwnedOrder(w, r, orderID)
+	if !ok {
+		return
+	}
+
+	// 2. Order đã bị thay đổi sau lần client đọc
+	if !etagMatches(ifMatch, theOrder.Version) {
+		w.Header().Set("ETag", formatETag(theOrder.Version))
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	}
+
+	// 3. Đơn đã thu tiền, đã giao (hoặc đã đóng) thì không được sửa line item
+	if err := lifecycle.CanEditItems(theOrder); err != nil {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+
+	// 4. Sửa line item và tính lại tổng tiền.
+	// Đơn tạo trước khi có tiền tệ được coi là theo tiền tệ mặc định.
+	if theOrder.Currency == "" {
+		theOrder.SetCurrency(h.Currency)
+	}
+	err = edit(&theOrder)
+	if errors.Is(err, errItemNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	} else if errors.Is(err, errItemExists) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if isCatalogError(err) {
+		writeCatalogError(w, err)
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// Tính lại tiền hàng, thuế và tổng tiền sau khi đổi line item
+	if err := h.Pricing.Apply(&theOrder); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	// 5. Giữ chỗ theo số lượng mới, thiếu hàng thì giữ nguyên đơn cũ
+	if h.Inventory != nil {
+		err = h.Inventory.Replace(r.Context(), orderID, inventory.LinesOf(theOrder))
+		if inventory.IsInsufficientStock(err) {
+			http.Error(w, err.Error(), http.StatusConflict)
+			return
+		} else if err != nil {
+			fmt.Println("failed to reserve stock: ", err)
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	// 6. Ghi order với version mới
+	theOrder.Version++
+	err = h.Repo.Update(r.Context(), theOrder)
+	if err != nil {
+		// Đơn không được ghi thì giữ chỗ lại theo line item đang lưu,
+		// không dùng line item đã đọc vì request khác có thể vừa ghi đơn
+		h.restoreReservation(r.Context(), orderID)
+	}
+	if errors.Is(err, order.ErrNotExist) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	} else if errors.Is(err, order.ErrVersionMismatch) {
+		w.WriteHeader(http.StatusPreconditionFailed)
+		return
+	} else if err != nil {
+		fmt.Println("failed to update line items: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("ETag", formatETag(theOrder.Version))
+	writeJSON(w, status, theOrder)
+}
+
+// restoreReservation giữ chỗ lại theo line item của order đang lưu sau khi ghi order thất bại,
+// order không còn thì trả hàng về kho
+func (h *Order) restoreReservation(ctx context.Context, orderID uint64) {
+	if h.Inventory == nil {
+		return
+	}
+	current, err := h.Repo.FindByID(ctx, orderID)
+	if errors.Is(err, order.ErrNotExist) {
+		h.releaseReservation(ctx, orderID)
+		return
+	} else if err != nil {
+		fmt.Println("failed to restore reservation: ", err)
+		return
+	}
+	if err := h.Inventory.Replace(ctx, orderID, inventory.LinesOf(current)); err != nil {
+		fmt.Println("failed to restore reservation: ", err)
+	}
+}
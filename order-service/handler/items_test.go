@@ -0,0 +1,87 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var testItemID = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+
+// racingRepo chạy beforeUpdate trước lần Update đầu tiên để giả lập request khác ghi đơn cùng lúc
+type racingRepo struct {
+	*order.MemoryRepo
+	beforeUpdate func()
+}
+
+func (r *racingRepo) Update(ctx context.Context, o model.Order, changes ...model.StatusChange) error {
+	if r.beforeUpdate != nil {
+		before := r.beforeUpdate
+		r.beforeUpdate = nil
+		before()
+	}
+	return r.MemoryRepo.Update(ctx, o, changes...)
+}
+
+// Hai lần sửa cùng If-Match: lần thua không được trả reservation về line item cũ
+func TestEditLineItemsKeepsWinnerReservation(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	stock := &inventory.RedisStore{Client: client}
+	if err := stock.SetStock(ctx, testItemID, 10); err != nil {
+		t.Fatalf("SetStock: %v", err)
+	}
+
+	repo := &racingRepo{MemoryRepo: order.NewMemoryRepo()}
+	h := &Order{Repo: repo, Inventory: stock, Currency: "VND"}
+	price := model.Money{Amount: 1000, Currency: "VND"}
+	insertTestOrder(t, h, model.Order{OrderID: 1, OrderStatus: model.StatusPending, Currency: "VND",
+		LineItems: []model.LineItem{{ItemID: testItemID, Quantity: 2, Price: price}}})
+	if err := stock.Reserve(ctx, 1, []inventory.Line{{ItemID: testItemID, Quantity: 2}}); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+
+	// Request khác đổi số lượng thành 5 và ghi đơn trước
+	repo.beforeUpdate = func() {
+		winner, _ := repo.FindByID(ctx, 1)
+		winner.LineItems[0].Quantity = 5
+		winner.Version++
+		if err := stock.Replace(ctx, 1, inventory.LinesOf(winner)); err != nil {
+			t.Fatalf("Replace: %v", err)
+		}
+		if err := repo.MemoryRepo.Update(ctx, winner); err != nil {
+			t.Fatalf("Update: %v", err)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/", strings.NewReader(`{"quantity":3}`))
+	req.Header.Set("If-Match", formatETag(1))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", "1")
+	rctx.URLParams.Add("item_id", testItemID.String())
+	req = req.WithContext(auth.WithIdentity(context.WithValue(req.Context(), chi.RouteCtxKey, rctx),
+		auth.Identity{UserID: testCustomerID, Role: auth.RoleUser}))
+	rec := httptest.NewRecorder()
+	h.UpdateItem(rec, req)
+
+	if rec.Code != http.StatusPreconditionFailed {
+		t.Fatalf("status = %d, want 412", rec.Code)
+	}
+	got, err := stock.GetStock(ctx, testItemID)
+	if err != nil || got.Reserved != 5 {
+		t.Fatalf("reserved = %+v, %v, want 5 as saved by the winning edit", got, err)
+	}
+}
@@ -0,0 +1,274 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/catalog"
+	"github.com/RibunLoc/microservices-learn/customer"
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/payment"
+	"github.com/RibunLoc/microservices-learn/proto/orderpb"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// OrderGRPCHandler là gRPC server của OrderService cho các service nội bộ,
+// dùng chung repository và logic với HTTP handler Order nên hai API luôn xử lý giống nhau
+type OrderGRPCHandler struct {
+	orderpb.UnimplementedOrderServiceServer
+	Orders *Order
+}
+
+func (h *OrderGRPCHandler) CreateOrder(ctx context.Context, req *orderpb.CreateOrderRequest) (*orderpb.Order, error) {
+	caller, err := identityFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// 1. Chuyển line item từ proto sang model, item_id phải là UUID
+	items := make([]model.LineItem, len(req.LineItems))
+	for i, item := range req.LineItems {
+		id, err := uuid.Parse(item.ItemId)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid item_id %q", item.ItemId)
+		}
+		items[i] = model.LineItem{
+			ItemID:   id,
+			Quantity: uint(item.Quantity),
+			Price:    model.Money{Amount: item.GetPrice().GetAmount(), Currency: item.GetPrice().GetCurrency()},
+		}
+	}
+
+	// 2. Tạo đơn giống POST /orders
+	o, err := h.Orders.place(ctx, caller, placeRequest{
+		CustomerID: model.CustomerID(req.CustomerId),
+		LineItems:  items,
+		Region:     req.Region,
+		Currency:   req.Currency,
+	})
+	if err != nil {
+		return nil, grpcError("insert", err)
+	}
+	return orderToProto(o), nil
+}
+
+func (h *OrderGRPCHandler) GetOrder(ctx context.Context, req *orderpb.GetOrderRequest) (*orderpb.Order, error) {
+	caller, err := identityFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Giống GET /orders/{id}, đơn đã lưu trữ lạnh vẫn đọc được
+	o, _, err := h.Orders.findOwnedOrRetained(ctx, caller, req.OrderId)
+	if err != nil {
+		return nil, grpcError("find by id", err)
+	}
+	return orderToProto(o), nil
+}
+
+// ListOrders gửi lần lượt mọi đơn khớp bộ lọc, đọc từ repository từng trang exportPageSize đơn
+func (h *OrderGRPCHandler) ListOrders(req *orderpb.ListOrdersRequest, stream grpc.ServerStreamingServer[orderpb.Order]) error {
+	ctx := stream.Context()
+	caller, err := identityFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// 1. Đọc bộ lọc giống GET /orders
+	page := order.FindAllPage{
+		Size: exportPageSize,
+		Sort: order.SortCreatedAtAsc,
+	}
+	if req.CustomerId != "" {
+		id, err := model.ParseCustomerID(req.CustomerId)
+		if err != nil {
+			return status.Errorf(codes.InvalidArgument, "invalid customer_id: %v", err)
+		}
+		page.Filter.CustomerID = id
+	}
+	if req.Status != "" {
+		s := model.Status(req.Status)
+		if !s.Valid() {
+			return status.Errorf(codes.InvalidArgument, "invalid status: %q", req.Status)
+		}
+		page.Filter.Status = s
+	}
+	if req.CreatedFrom != nil {
+		t := req.CreatedFrom.AsTime()
+		page.Filter.CreatedFrom = &t
+	}
+	if req.CreatedTo != nil {
+		t := req.CreatedTo.AsTime()
+		page.Filter.CreatedTo = &t
+	}
+	switch sort := order.SortOrder(req.Sort); sort {
+	case "":
+	case order.SortCreatedAtAsc, order.SortCreatedAtDesc:
+		page.Sort = sort
+	default:
+		return status.Errorf(codes.InvalidArgument, "invalid sort: %q", req.Sort)
+	}
+
+	// 2. User thường chỉ được xem đơn của chính mình
+	if !caller.IsAdmin() {
+		if page.Filter.CustomerID != "" && page.Filter.CustomerID != caller.UserID {
+			return status.Error(codes.PermissionDenied, "customer_id must be the authenticated user")
+		}
+		page.Filter.CustomerID = caller.UserID
+	}
+
+	// 3. Gửi từng trang cho đến hết, client ngắt kết nối thì Send báo lỗi và dừng
+	for {
+		res, err := h.Orders.Repo.FindAll(ctx, page)
+		if err != nil {
+			return grpcError("find all", err)
+		}
+		for _, o := range res.Orders {
+			if err := stream.Send(orderToProto(o)); err != nil {
+				return err
+			}
+		}
+		if res.Next == nil {
+			return nil
+		}
+		page.After = res.Next
+	}
+}
+
+// UpdateOrderStatus giống PUT /orders/{id}, expected_version thay cho header If-Match
+func (h *OrderGRPCHandler) UpdateOrderStatus(ctx context.Context, req *orderpb.UpdateOrderStatusRequest) (*orderpb.Order, error) {
+	caller, err := identityFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if req.ExpectedVersion == 0 {
+		return nil, status.Error(codes.InvalidArgument, "expected_version is required")
+	}
+
+	o, err := h.Orders.updateStatus(ctx, caller, req.OrderId, statusUpdate{
+		Status: model.Status(req.Status),
+		Reason: req.Reason,
+		Matches: func(version uint64) bool {
+			return version == req.ExpectedVersion
+		},
+	})
+	if errors.Is(err, order.ErrVersionMismatch) && o.Version != 0 {
+		return nil, status.Errorf(codes.Aborted, "order version is %d, expected %d", o.Version, req.ExpectedVersion)
+	} else if err != nil {
+		return nil, grpcError("update", err)
+	}
+	return orderToProto(o), nil
+}
+
+// CancelOrder giống POST /orders/{id}/cancel: hủy giữ tiền hoặc hoàn tiền, chuyển đơn sang cancelled và trả hàng
+func (h *OrderGRPCHandler) CancelOrder(ctx context.Context, req *orderpb.CancelOrderRequest) (*orderpb.Order, error) {
+	caller, err := identityFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	o, err := h.Orders.cancel(ctx, caller, req.OrderId, req.Reason)
+	if err != nil {
+		return nil, grpcError("cancel", err)
+	}
+	return orderToProto(o), nil
+}
+
+// identityFromContext lấy người gọi do auth interceptor gắn vào context
+func identityFromContext(ctx context.Context) (auth.Identity, error) {
+	id, ok := auth.FromContext(ctx)
+	if !ok {
+		return auth.Identity{}, status.Error(codes.Unauthenticated, "missing identity")
+	}
+	return id, nil
+}
+
+/*
+grpcError chuyển lỗi của các thao tác trên đơn hàng thành gRPC status, tương ứng với writeOrderError:
+  - lỗi dữ liệu: 400 -> InvalidArgument, 403 -> PermissionDenied, còn lại FailedPrecondition
+  - không có order NotFound, version không khớp Aborted, trùng ID AlreadyExists
+  - không thực hiện được với trạng thái hiện tại (chuyển trạng thái, khách hàng, thiếu hàng,
+    mặt hàng hay giao dịch không hợp lệ) FailedPrecondition
+  - user-service, catalog-service hoặc cổng thanh toán không phản hồi Unavailable
+  - còn lại Internal, action dùng để ghi log
+*/
+func grpcError(action string, err error) error {
+	var reqErr *requestError
+	var illegal *lifecycle.IllegalTransitionError
+	switch {
+	case errors.As(err, &reqErr):
+		switch reqErr.status {
+		case http.StatusBadRequest:
+			return status.Error(codes.InvalidArgument, reqErr.Error())
+		case http.StatusForbidden:
+			return status.Error(codes.PermissionDenied, reqErr.Error())
+		default:
+			return status.Error(codes.FailedPrecondition, reqErr.Error())
+		}
+	case errors.As(err, &illegal):
+		return status.Errorf(codes.FailedPrecondition, "%v (allowed: %v)", illegal, illegal.Allowed)
+	case errors.Is(err, order.ErrNotExist):
+		return status.Error(codes.NotFound, "order not found")
+	case errors.Is(err, order.ErrVersionMismatch):
+		return status.Error(codes.Aborted, err.Error())
+	case errors.Is(err, order.ErrAlreadyExists):
+		fmt.Printf("failed to %s: %v\n", action, err)
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, customer.ErrUnavailable), errors.Is(err, catalog.ErrUnavailable),
+		errors.Is(err, payment.ErrUnavailable), errors.Is(err, errPaymentsDisabled):
+		fmt.Printf("failed to %s: %v\n", action, err)
+		return status.Error(codes.Unavailable, err.Error())
+	case errors.Is(err, customer.ErrUnknown), errors.Is(err, customer.ErrInactive),
+		inventory.IsInsufficientStock(err), isCatalogError(err),
+		errors.Is(err, payment.ErrDeclined), errors.Is(err, payment.ErrInvalidState),
+		errors.Is(err, payment.ErrAmountExceeded), errors.Is(err, payment.ErrUnknownIntent):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		fmt.Printf("failed to %s: %v\n", action, err)
+		return status.Error(codes.Internal, "internal error")
+	}
+}
+
+// orderToProto chuyển order sang message của OrderService
+func orderToProto(o model.Order) *orderpb.Order {
+	money := func(m model.Money) *orderpb.Money {
+		return &orderpb.Money{Amount: m.Amount, Currency: m.Currency}
+	}
+
+	res := &orderpb.Order{
+		OrderId:    o.OrderID,
+		CustomerId: o.CustomerID.String(),
+		LineItems:  make([]*orderpb.LineItem, len(o.LineItems)),
+		Currency:   o.Currency,
+		Region:     o.Region,
+		Subtotal:   money(o.Subtotal),
+		Tax:        money(o.Tax),
+		Shipping:   money(o.Shipping),
+		GrandTotal: money(o.GrandTotal),
+		Status:     string(o.OrderStatus),
+		Version:    o.Version,
+	}
+	for i, item := range o.LineItems {
+		res.LineItems[i] = &orderpb.LineItem{
+			ItemId:   item.ItemID.String(),
+			Sku:      item.SKU,
+			Name:     item.Name,
+			Quantity: uint32(item.Quantity),
+			Price:    money(item.Price),
+		}
+	}
+	if o.CreateAt != nil {
+		res.CreatedAt = timestamppb.New(*o.CreateAt)
+	}
+	return res
+}
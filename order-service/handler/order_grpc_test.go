@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/catalog"
+	"github.com/RibunLoc/microservices-learn/customer"
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/payment"
+	"github.com/RibunLoc/microservices-learn/proto/orderpb"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestGRPCError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		code codes.Code
+	}{
+		{"bad request", rejectRequest(http.StatusBadRequest, errors.New("bad")), codes.InvalidArgument},
+		{"forbidden", rejectRequest(http.StatusForbidden, errors.New("forbidden")), codes.PermissionDenied},
+		{"other request error", rejectRequest(http.StatusUnprocessableEntity, errors.New("invalid")), codes.FailedPrecondition},
+		{"illegal transition", &lifecycle.IllegalTransitionError{From: model.StatusPaid, To: model.StatusPending}, codes.FailedPrecondition},
+		{"not found", fmt.Errorf("find: %w", order.ErrNotExist), codes.NotFound},
+		{"version mismatch", order.ErrVersionMismatch, codes.Aborted},
+		{"already exists", order.ErrAlreadyExists, codes.AlreadyExists},
+		{"user-service down", customer.ErrUnavailable, codes.Unavailable},
+		{"catalog-service down", catalog.ErrUnavailable, codes.Unavailable},
+		{"payment provider down", payment.ErrUnavailable, codes.Unavailable},
+		{"payments disabled", errPaymentsDisabled, codes.Unavailable},
+		{"unknown customer", customer.ErrUnknown, codes.FailedPrecondition},
+		{"inactive customer", customer.ErrInactive, codes.FailedPrecondition},
+		{"insufficient stock", &inventory.InsufficientStockError{ItemID: testItemID, Requested: 2}, codes.FailedPrecondition},
+		{"unknown item", catalog.ErrUnknownItem, codes.FailedPrecondition},
+		{"payment declined", payment.ErrDeclined, codes.FailedPrecondition},
+		{"payment state", payment.ErrInvalidState, codes.FailedPrecondition},
+		{"unexpected", errors.New("connection reset"), codes.Internal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := status.Code(grpcError("test", tt.err)); got != tt.code {
+				t.Fatalf("code = %s, want %s", got, tt.code)
+			}
+		})
+	}
+
+	// Lỗi nội bộ không lộ chi tiết cho client
+	if msg := status.Convert(grpcError("test", errors.New("secret dsn"))).Message(); msg != "internal error" {
+		t.Fatalf("internal error message = %q", msg)
+	}
+}
+
+func TestOrderGRPCHandler(t *testing.T) {
+	owner := auth.Identity{UserID: testCustomerID, Role: auth.RoleUser}
+	stranger := auth.Identity{UserID: testAdminID, Role: auth.RoleUser}
+
+	tests := []struct {
+		name   string
+		caller *auth.Identity
+		call   func(ctx context.Context, g *OrderGRPCHandler) error
+		code   codes.Code
+	}{
+		{"get own order", &owner, func(ctx context.Context, g *OrderGRPCHandler) error {
+			_, err := g.GetOrder(ctx, &orderpb.GetOrderRequest{OrderId: 1})
+			return err
+		}, codes.OK},
+		{"get without identity", nil, func(ctx context.Context, g *OrderGRPCHandler) error {
+			_, err := g.GetOrder(ctx, &orderpb.GetOrderRequest{OrderId: 1})
+			return err
+		}, codes.Unauthenticated},
+		{"get other customer's order", &stranger, func(ctx context.Context, g *OrderGRPCHandler) error {
+			_, err := g.GetOrder(ctx, &orderpb.GetOrderRequest{OrderId: 1})
+			return err
+		}, codes.NotFound},
+		{"get missing order", &owner, func(ctx context.Context, g *OrderGRPCHandler) error {
+			_, err := g.GetOrder(ctx, &orderpb.GetOrderRequest{OrderId: 2})
+			return err
+		}, codes.NotFound},
+		{"update without expected_version", &owner, func(ctx context.Context, g *OrderGRPCHandler) error {
+			_, err := g.UpdateOrderStatus(ctx, &orderpb.UpdateOrderStatusRequest{OrderId: 1, Status: string(model.StatusCancelled)})
+			return err
+		}, codes.InvalidArgument},
+		{"update with stale version", &owner, func(ctx context.Context, g *OrderGRPCHandler) error {
+			_, err := g.UpdateOrderStatus(ctx, &orderpb.UpdateOrderStatusRequest{OrderId: 1, Status: string(model.StatusCancelled), ExpectedVersion: 7})
+			return err
+		}, codes.Aborted},
+		{"create with invalid item_id", &owner, func(ctx context.Context, g *OrderGRPCHandler) error {
+			_, err := g.CreateOrder(ctx, &orderpb.CreateOrderRequest{LineItems: []*orderpb.LineItem{{ItemId: "x", Quantity: 1}}})
+			return err
+		}, codes.InvalidArgument},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Order{Repo: order.NewMemoryRepo(), Currency: "VND"}
+			insertTestOrder(t, h, model.Order{OrderID: 1, OrderStatus: model.StatusPending})
+			ctx := context.Background()
+			if tt.caller != nil {
+				ctx = auth.WithIdentity(ctx, *tt.caller)
+			}
+			if got := status.Code(tt.call(ctx, &OrderGRPCHandler{Orders: h})); got != tt.code {
+				t.Fatalf("code = %s, want %s", got, tt.code)
+			}
+		})
+	}
+}
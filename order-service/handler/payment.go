@@ -0,0 +1,322 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/payment"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+)
+
+// writePaymentError chuyển lỗi của cổng thanh toán thành status code:
+// bị từ chối 402, không gọi được 503, thao tác không hợp lệ với giao dịch 409
+func writePaymentError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, payment.ErrDeclined):
+		http.Error(w, err.Error(), http.StatusPaymentRequired)
+	case errors.Is(err, payment.ErrUnavailable):
+		fmt.Println("failed to call payment provider: ", err)
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, payment.ErrInvalidState), errors.Is(err, payment.ErrAmountExceeded), errors.Is(err, payment.ErrUnknownIntent):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		fmt.Println("failed to call payment provider: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// canTransition kiểm tra order chuyển được sang trạng thái to trước khi gọi cổng thanh toán,
+// không được thì trả về 409 kèm các trạng thái được phép như PUT /orders/{id}
+func canTransition(w http.ResponseWriter, o model.Order, to model.Status) bool {
+	if err := checkTransition(o, to); err != nil {
+		writeOrderError(w, "apply transition", err)
+		return false
+	}
+	return true
+}
+
+// checkTransition thử chuyển bản sao của order sang trạng thái to,
+// bước chuyển không hợp lệ trả về IllegalTransitionError
+func checkTransition(o model.Order, to model.Status) error {
+	return lifecycle.Apply(&o, to, time.Now())
+}
+
+/*
+Pay là HTTP handler thanh toán đơn hàng (POST /orders/{id}/pay), chỉ chủ đơn hoặc admin:
+ 1. Chỉ thanh toán được đơn có thể chuyển sang paid (đã xác nhận) và có tổng tiền khác 0
+ 2. Dùng lần giữ tiền của saga nếu còn khớp tổng tiền, không thì giữ tiền mới
+ 3. Thu tiền rồi ghi lần thanh toán vào đơn và chuyển đơn sang paid
+
+Lần giữ tiền bị từ chối hoặc lỗi cũng được ghi vào đơn.
+*/
+func (h *Order) Pay(w http.ResponseWriter, r *http.Request) {
+	orderID, err := parseOrderID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// 1. Tìm order và kiểm tra trạng thái
+	theOrder, ok := h.findOwnedOrder(w, r, orderID)
+	if !ok {
+		return
+	}
+	if !canTransition(w, theOrder, model.StatusPaid) {
+		return
+	}
+	// Đơn 0 đồng không có gì để thu, lần giữ tiền như vậy cũng không hoàn lại được
+	if theOrder.GrandTotal.Amount == 0 {
+		http.Error(w, "order total is zero, nothing to pay", http.StatusUnprocessableEntity)
+		return
+	}
+
+	// 2. Tìm hoặc tạo lần giữ tiền
+	ctx := r.Context()
+	now := time.Now().UTC()
+	var changed []model.PaymentIntent
+	var intent model.PaymentIntent
+	if i := theOrder.FindPayment(model.PaymentAuthorized); i >= 0 && theOrder.Payments[i].Amount == theOrder.GrandTotal {
+		intent = theOrder.Payments[i]
+	} else {
+		// Đơn đã đổi tổng tiền sau khi giữ tiền thì hủy lần giữ tiền cũ
+		if i >= 0 {
+			stale := theOrder.Payments[i]
+			if err := h.Payments.Void(ctx, stale.IntentID); err != nil {
+				fmt.Println("failed to void payment: ", err)
+			} else {
+				stale.Status = model.PaymentVoided
+				stale.UpdatedAt = now
+				changed = append(changed, stale)
+			}
+		}
+
+		intent = model.PaymentIntent{
+			Provider:  h.Payments.Name(),
+			Amount:    theOrder.GrandTotal,
+			Status:    model.PaymentAuthorized,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		intent.IntentID, err = h.Payments.Authorize(ctx, orderID, theOrder.GrandTotal)
+		if err != nil {
+			intent.Status = payment.StatusOf(err)
+			intent.Error = err.Error()
+			if _, saveErr := h.savePayments(ctx, orderID, append(changed, intent), "", actorFromRequest(r), ""); saveErr != nil {
+				fmt.Println("failed to save payment: ", saveErr)
+			}
+			writePaymentError(w, err)
+			return
+		}
+	}
+
+	// 3. Thu tiền
+	if err := h.Payments.Capture(ctx, intent.IntentID, intent.Amount); err != nil {
+		// Cổng thanh toán không còn giao dịch này (bị hủy hoặc mất dữ liệu), lần sau sẽ giữ tiền lại
+		if !errors.Is(err, payment.ErrUnavailable) {
+			intent.Status = model.PaymentFailed
+			intent.Error = err.Error()
+			intent.UpdatedAt = time.Now().UTC()
+			changed = append(changed, intent)
+		}
+		if len(changed) > 0 {
+			if _, saveErr := h.savePayments(ctx, orderID, changed, "", actorFromRequest(r), ""); saveErr != nil {
+				fmt.Println("failed to save payment: ", saveErr)
+			}
+		}
+		writePaymentError(w, err)
+		return
+	}
+	intent.Captured = intent.Amount.Amount
+	intent.Status = model.PaymentCaptured
+	intent.Error = ""
+	intent.UpdatedAt = time.Now().UTC()
+
+	paid, err := h.savePayments(ctx, orderID, append(changed, intent), model.StatusPaid, actorFromRequest(r), "payment captured")
+	var illegal *lifecycle.IllegalTransitionError
+	if errors.As(err, &illegal) {
+		// Đơn vừa bị hủy bởi request khác, cancel không thấy lần thu tiền này nên hoàn lại ở đây
+		if refundErr := h.Payments.Refund(ctx, intent.IntentID, intent.Amount); refundErr != nil {
+			fmt.Println("failed to refund payment: ", refundErr)
+		} else {
+			intent.Refunded = intent.Captured
+			intent.Status = model.PaymentRefunded
+			intent.UpdatedAt = time.Now().UTC()
+		}
+		if _, saveErr := h.savePayments(ctx, orderID, append(changed, intent), "", actorFromRequest(r), ""); saveErr != nil {
+			fmt.Println("failed to save payment: ", saveErr)
+		}
+		writeOrderError(w, "pay", err)
+		return
+	}
+	if err != nil {
+		// Tiền đã thu, client gọi lại sẽ thu lại cùng giao dịch (Capture idempotent) rồi ghi lại
+		fmt.Println("failed to save payment: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	h.syncReservation(ctx, orderID, model.StatusPaid)
+
+	w.Header().Set("ETag", formatETag(paid.Version))
+	writeJSON(w, http.StatusOK, paid)
+}
+
+/*
+Refund là HTTP handler hoàn tiền (POST /orders/{id}/refund), chỉ admin được gọi.
+Body {"amount": 1000, "reason": "..."}, không có amount thì hoàn toàn bộ số tiền còn lại.
+Hoàn toàn bộ thì đơn chuyển sang refunded, hoàn một phần thì đơn giữ nguyên trạng thái.
+*/
+func (h *Order) Refund(w http.ResponseWriter, r *http.Request) {
+	orderID, err := parseOrderID(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	caller, ok := identityFromRequest(w, r)
+	if !ok {
+		return
+	}
+	if !caller.IsAdmin() {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	var body struct {
+		Amount *uint64 `json:"amount"` // số tiền hoàn (minor units)
+		Reason string  `json:"reason"` // lý do hoàn tiền, ghi vào lịch sử
+	}
+	// Body rỗng là hoàn toàn bộ
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	// 1. Tìm lần thanh toán đã thu tiền
+	theOrder, ok := h.findOwnedOrder(w, r, orderID)
+	if !ok {
+		return
+	}
+	i := theOrder.FindPayment(model.PaymentCaptured, model.PaymentPartiallyRefunded)
+	if i < 0 {
+		http.Error(w, "order has no captured payment", http.StatusConflict)
+		return
+	}
+	intent := theOrder.Payments[i]
+
+	// 2. Kiểm tra số tiền hoàn, hoàn toàn bộ thì đơn phải chuyển được sang refunded
+	amount := intent.Refundable()
+	if body.Amount != nil {
+		amount = *body.Amount
+	}
+	if amount == 0 || amount > intent.Refundable() {
+		http.Error(w, payment.ErrAmountExceeded.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	full := amount == intent.Refundable()
+	if full && !canTransition(w, theOrder, model.StatusRefunded) {
+		return
+	}
+
+	// 3. Hoàn tiền qua cổng thanh toán
+	err = h.Payments.Refund(r.Context(), intent.IntentID, model.Money{Amount: amount, Currency: intent.Amount.Currency})
+	if err != nil {
+		writePaymentError(w, err)
+		return
+	}
+	intent.Refunded += amount
+	intent.Status = model.PaymentPartiallyRefunded
+	intent.UpdatedAt = time.Now().UTC()
+	var to model.Status
+	if full {
+		intent.Status = model.PaymentRefunded
+		to = model.StatusRefunded
+	}
+
+	// 4. Ghi vào đơn
+	refunded, err := h.savePayments(r.Context(), orderID, []model.PaymentIntent{intent}, to, actorFromRequest(r), body.Reason)
+	if err != nil {
+		fmt.Println("failed to save refund: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	if full {
+		h.syncReservation(r.Context(), orderID, model.StatusRefunded)
+	}
+
+	w.Header().Set("ETag", formatETag(refunded.Version))
+	writeJSON(w, http.StatusOK, refunded)
+}
+
+/*
+savePayments ghi các lần thanh toán vào order và chuyển order sang trạng thái to (nếu khác rỗng).
+Tiền đã được thu hoặc hoàn ở cổng thanh toán nên khi order bị sửa đồng thời
+thì đọc lại và ghi lại thay vì trả về lỗi cho client.
+
+Chuyển sang cancelled thì payments là các lần thanh toán còn hiệu lực mà cancel sẽ bù trừ,
+order đọc lại có lần thanh toán khác hoặc vừa đổi trạng thái (ví dụ Pay vừa thu tiền)
+thì trả về 409 để client gọi lại.
+*/
+func (h *Order) savePayments(ctx context.Context, orderID uint64, payments []model.PaymentIntent, to model.Status, actor, reason string) (model.Order, error) {
+	var err error
+	for attempt := 0; attempt < maxInsertAttempts; attempt++ {
+		var theOrder model.Order
+		theOrder, err = h.Repo.FindByID(ctx, orderID)
+		if err != nil {
+			return model.Order{}, err
+		}
+		if to == model.StatusCancelled && !samePayments(activePayments(theOrder), payments) {
+			return model.Order{}, rejectRequest(http.StatusConflict, errPaymentsChanged)
+		}
+		for _, p := range payments {
+			theOrder.SetPayment(p)
+		}
+
+		var changes []model.StatusChange
+		from := lifecycle.Current(theOrder)
+		if to != "" && from != to {
+			now := time.Now().UTC()
+			if err := lifecycle.Apply(&theOrder, to, now); err != nil {
+				return model.Order{}, err
+			}
+			changes = append(changes, model.StatusChange{
+				OrderID:   orderID,
+				From:      from,
+				To:        to,
+				Actor:     actor,
+				Reason:    reason,
+				ChangedAt: now,
+			})
+		}
+
+		theOrder.Version++
+		err = h.Repo.Update(ctx, theOrder, changes...)
+		if err == nil {
+			return theOrder, nil
+		}
+		if !errors.Is(err, order.ErrVersionMismatch) {
+			return model.Order{}, err
+		}
+	}
+	return model.Order{}, err
+}
+
+// samePayments so sánh trạng thái và số tiền của hai danh sách lần thanh toán theo thứ tự
+func samePayments(a, b []model.PaymentIntent) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].IntentID != b[i].IntentID || a[i].Status != b[i].Status ||
+			a[i].Captured != b[i].Captured || a[i].Refunded != b[i].Refunded {
+			return false
+		}
+	}
+	return true
+}
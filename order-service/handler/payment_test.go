@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/go-chi/chi/v5"
+)
+
+// serveOrder gọi handler của /orders/{id}/... với người gọi caller, trả về status và order trong body
+func serveOrder(t *testing.T, handle http.HandlerFunc, caller auth.Identity, orderID uint64, body string) (int, model.Order) {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(body))
+	rctx := chi.NewRouteContext()
+	rctx.URLParams.Add("id", strconv.FormatUint(orderID, 10))
+	ctx := context.WithValue(req.Context(), chi.RouteCtxKey, rctx)
+	req = req.WithContext(auth.WithIdentity(ctx, caller))
+	rec := httptest.NewRecorder()
+	handle(rec, req)
+
+	var o model.Order
+	if rec.Code == http.StatusOK {
+		if err := json.Unmarshal(rec.Body.Bytes(), &o); err != nil {
+			t.Fatalf("decode response: %v", err)
+		}
+		if rec.Header().Get("ETag") != formatETag(o.Version) {
+			t.Fatalf("ETag = %q, want version %d", rec.Header().Get("ETag"), o.Version)
+		}
+	}
+	return rec.Code, o
+}
+
+func TestPay(t *testing.T) {
+	customer := auth.Identity{UserID: testCustomerID, Role: auth.RoleUser}
+	stranger := auth.Identity{UserID: testAdminID, Role: auth.RoleUser}
+
+	tests := []struct {
+		name     string
+		order    model.Order
+		saga     bool // saga đã giữ tiền trước với tổng tiền cũ
+		caller   auth.Identity
+		status   int
+		payments []model.PaymentStatus // trạng thái các lần thanh toán sau khi gọi
+	}{
+		{"confirmed order is captured", model.Order{OrderStatus: model.StatusConfirmed}, false, customer, http.StatusOK,
+			[]model.PaymentStatus{model.PaymentCaptured}},
+		{"authorization with another total is voided", model.Order{OrderStatus: model.StatusConfirmed}, true, customer, http.StatusOK,
+			[]model.PaymentStatus{model.PaymentVoided, model.PaymentCaptured}},
+		{"pending order cannot be paid", model.Order{OrderStatus: model.StatusPending}, false, customer, http.StatusConflict, nil},
+		{"paid order cannot be paid again", model.Order{OrderStatus: model.StatusPaid}, false, customer, http.StatusConflict, nil},
+		{"other customer's order", model.Order{OrderStatus: model.StatusConfirmed}, false, stranger, http.StatusNotFound, nil},
+		{"zero total", model.Order{OrderStatus: model.StatusConfirmed, GrandTotal: model.Money{Currency: "VND"}}, false, customer, http.StatusUnprocessableEntity, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			h, fake := newPaymentHandler(t)
+			tt.order.OrderID = 1
+			if tt.saga {
+				id, _ := fake.Authorize(ctx, 1, model.Money{Amount: 1000, Currency: "VND"})
+				tt.order.Payments = []model.PaymentIntent{{IntentID: id, Amount: model.Money{Amount: 1000, Currency: "VND"}, Status: model.PaymentAuthorized}}
+			}
+			insertTestOrder(t, h, tt.order)
+
+			status, paid := serveOrder(t, h.Pay, tt.caller, 1, "")
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			saved, _ := h.Repo.FindByID(ctx, 1)
+			if status == http.StatusOK && (paid.OrderStatus != model.StatusPaid || saved.OrderStatus != model.StatusPaid) {
+				t.Fatalf("order after Pay = %+v", saved)
+			}
+			if status != http.StatusOK && saved.OrderStatus != tt.order.OrderStatus {
+				t.Fatalf("status changed to %s after rejected Pay", saved.OrderStatus)
+			}
+			if tt.payments == nil {
+				tt.payments = statusesOf(tt.order.Payments)
+			}
+			if got := statusesOf(saved.Payments); fmt.Sprint(got) != fmt.Sprint(tt.payments) {
+				t.Fatalf("payments = %v, want %v", got, tt.payments)
+			}
+		})
+	}
+}
+
+func TestRefund(t *testing.T) {
+	admin := auth.Identity{UserID: testAdminID, Role: auth.RoleAdmin}
+	customer := auth.Identity{UserID: testCustomerID, Role: auth.RoleUser}
+
+	tests := []struct {
+		name     string
+		caller   auth.Identity
+		body     string
+		status   int
+		order    model.Status
+		payment  model.PaymentStatus
+		refunded uint64
+	}{
+		{"full refund", admin, "", http.StatusOK, model.StatusRefunded, model.PaymentRefunded, 3000},
+		{"full refund with reason", admin, `{"reason":"damaged"}`, http.StatusOK, model.StatusRefunded, model.PaymentRefunded, 3000},
+		{"partial refund keeps status", admin, `{"amount":1000}`, http.StatusOK, model.StatusPaid, model.PaymentPartiallyRefunded, 1000},
+		{"amount equal to the remainder is full", admin, `{"amount":3000}`, http.StatusOK, model.StatusRefunded, model.PaymentRefunded, 3000},
+		{"more than captured", admin, `{"amount":3001}`, http.StatusUnprocessableEntity, model.StatusPaid, model.PaymentCaptured, 0},
+		{"zero amount", admin, `{"amount":0}`, http.StatusUnprocessableEntity, model.StatusPaid, model.PaymentCaptured, 0},
+		{"only admins", customer, "", http.StatusForbidden, model.StatusPaid, model.PaymentCaptured, 0},
+		{"bad body", admin, `{"amount":`, http.StatusBadRequest, model.StatusPaid, model.PaymentCaptured, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			h, fake := newPaymentHandler(t)
+			intent := newIntent(t, fake, 1, true)
+			insertTestOrder(t, h, model.Order{OrderID: 1, OrderStatus: model.StatusPaid, LineItems: []model.LineItem{{Quantity: 1}}, Payments: []model.PaymentIntent{intent}})
+
+			status, _ := serveOrder(t, h.Refund, tt.caller, 1, tt.body)
+			if status != tt.status {
+				t.Fatalf("status = %d, want %d", status, tt.status)
+			}
+			saved, _ := h.Repo.FindByID(ctx, 1)
+			p := saved.Payments[0]
+			if saved.OrderStatus != tt.order || p.Status != tt.payment || p.Refunded != tt.refunded {
+				t.Fatalf("order = %s, payment = %+v", saved.OrderStatus, p)
+			}
+		})
+	}
+
+	t.Run("no captured payment", func(t *testing.T) {
+		h, _ := newPaymentHandler(t)
+		insertTestOrder(t, h, model.Order{OrderID: 1, OrderStatus: model.StatusConfirmed})
+		if status, _ := serveOrder(t, h.Refund, admin, 1, ""); status != http.StatusConflict {
+			t.Fatalf("status = %d, want 409", status)
+		}
+	})
+}
+
+func statusesOf(payments []model.PaymentIntent) []model.PaymentStatus {
+	var statuses []model.PaymentStatus
+	for _, p := range payments {
+		statuses = append(statuses, p.Status)
+	}
+	return statuses
+}
@@ -0,0 +1,104 @@
+package handler
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+)
+
+// Giới hạn số item mỗi trang khi liệt kê đơn hàng
+const (
+	defaultListLimit = 50
+	maxListLimit     = 100
+)
+
+// Định dạng ngày chỉ có ngày, dùng cho created_from/created_to dạng "2025-01-31"
+const dateLayout = "2006-01-02"
+
+/*
+parseListQuery đọc các tham số lọc từ query string:
+  - customer_id: ID của khách hàng (ID user bên user-service)
+  - status: trạng thái đơn hàng
+  - created_from / created_to: RFC3339 hoặc ngày dạng YYYY-MM-DD
+    (created_to dạng ngày được tính đến hết ngày đó)
+  - sort: "created_at" (cũ trước) hoặc "-created_at" (mới trước)
+  - limit: số item mỗi trang, từ 1 đến maxListLimit
+*/
+func parseListQuery(q url.Values) (order.FindAllPage, error) {
+	page := order.FindAllPage{
+		Size: defaultListLimit,
+		Sort: order.SortCreatedAtAsc,
+	}
+
+	if v := q.Get("customer_id"); v != "" {
+		id, err := model.ParseCustomerID(v)
+		if err != nil {
+			return page, fmt.Errorf("invalid customer_id: %w", err)
+		}
+		page.Filter.CustomerID = id
+	}
+
+	if v := q.Get("status"); v != "" {
+		status := model.Status(v)
+		if !status.Valid() {
+			return page, fmt.Errorf("invalid status: %q", v)
+		}
+		page.Filter.Status = status
+	}
+
+	if v := q.Get("created_from"); v != "" {
+		t, err := parseTimeParam(v, false)
+		if err != nil {
+			return page, fmt.Errorf("invalid created_from: %w", err)
+		}
+		page.Filter.CreatedFrom = &t
+	}
+
+	if v := q.Get("created_to"); v != "" {
+		t, err := parseTimeParam(v, true)
+		if err != nil {
+			return page, fmt.Errorf("invalid created_to: %w", err)
+		}
+		page.Filter.CreatedTo = &t
+	}
+
+	if v := q.Get("sort"); v != "" {
+		switch sort := order.SortOrder(v); sort {
+		case order.SortCreatedAtAsc, order.SortCreatedAtDesc:
+			page.Sort = sort
+		default:
+			return page, fmt.Errorf("invalid sort: %q", v)
+		}
+	}
+
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.ParseUint(v, 10, 64)
+		if err != nil || limit == 0 || limit > maxListLimit {
+			return page, fmt.Errorf("invalid limit: must be between 1 and %d", maxListLimit)
+		}
+		page.Size = limit
+	}
+
+	return page, nil
+}
+
+// parseTimeParam nhận RFC3339 hoặc ngày YYYY-MM-DD (UTC).
+// Nếu endOfDay là true thì ngày được hiểu là thời điểm cuối cùng của ngày đó.
+func parseTimeParam(v string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, v); err == nil {
+		return t, nil
+	}
+
+	t, err := time.Parse(dateLayout, v)
+	if err != nil {
+		return time.Time{}, err
+	}
+	if endOfDay {
+		t = t.Add(24*time.Hour - time.Nanosecond)
+	}
+	return t, nil
+}
@@ -0,0 +1,154 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/RibunLoc/microservices-learn/auth"
+	"github.com/RibunLoc/microservices-learn/customer"
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/payment"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/go-chi/chi/v5"
+)
+
+// parseOrderID lấy tham số "id" từ URL path và chuyển sang uint64
+func parseOrderID(r *http.Request) (uint64, error) {
+	const base = 10
+	const bitSize = 64
+
+	return strconv.ParseUint(chi.URLParam(r, "id"), base, bitSize)
+}
+
+// actorFromRequest trả về người thực hiện request để ghi vào lịch sử,
+// là user_id trong JWT của người gọi, mặc định là "anonymous"
+func actorFromRequest(r *http.Request) string {
+	if id, ok := auth.FromContext(r.Context()); ok {
+		return id.UserID.String()
+	}
+	return "anonymous"
+}
+
+// identityFromRequest lấy người gọi do auth middleware gắn vào context,
+// trả về 401 nếu request chưa được xác thực
+func identityFromRequest(w http.ResponseWriter, r *http.Request) (auth.Identity, bool) {
+	id, ok := auth.FromContext(r.Context())
+	if !ok {
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+	return id, ok
+}
+
+// writeJSON ghi status code và body dạng JSON về client
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		fmt.Println("failed to marshal: ", err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(data)
+}
+
+// Body trả về khi chuyển trạng thái đơn hàng không hợp lệ (409)
+type transitionErrorResponse struct {
+	Error         string         `json:"error"`
+	CurrentStatus model.Status   `json:"current_status"`
+	Allowed       []model.Status `json:"allowed"`
+}
+
+// requestError là lỗi do dữ liệu client gửi lên, REST trả về status kèm message của lỗi,
+// gRPC trả về code tương ứng với status (xem grpcError)
+type requestError struct {
+	status int
+	err    error
+}
+
+func (e *requestError) Error() string {
+	return e.err.Error()
+}
+
+func (e *requestError) Unwrap() error {
+	return e.err
+}
+
+// rejectRequest bọc err thành lỗi của request với HTTP status trả về cho client
+func rejectRequest(status int, err error) error {
+	return &requestError{status: status, err: err}
+}
+
+/*
+writeOrderError chuyển lỗi của các thao tác trên đơn hàng thành response:
+  - lỗi dữ liệu (requestError) trả về status của lỗi kèm message
+  - không có order 404, version không khớp 412, trùng ID 409
+  - chuyển trạng thái không hợp lệ 409 kèm các trạng thái được phép
+  - khách hàng không hợp lệ 422, thiếu hàng 409, lỗi catalog và cổng thanh toán theo từng loại
+  - còn lại 500, action dùng để ghi log (ví dụ "insert" -> "failed to insert: ...")
+*/
+func writeOrderError(w http.ResponseWriter, action string, err error) {
+	var reqErr *requestError
+	var illegal *lifecycle.IllegalTransitionError
+	switch {
+	case errors.As(err, &reqErr):
+		http.Error(w, reqErr.Error(), reqErr.status)
+	case errors.As(err, &illegal):
+		writeJSON(w, http.StatusConflict, transitionErrorResponse{
+			Error:         illegal.Error(),
+			CurrentStatus: illegal.From,
+			Allowed:       illegal.Allowed,
+		})
+	case errors.Is(err, order.ErrNotExist):
+		w.WriteHeader(http.StatusNotFound)
+	case errors.Is(err, order.ErrVersionMismatch):
+		w.WriteHeader(http.StatusPreconditionFailed)
+	case errors.Is(err, order.ErrAlreadyExists):
+		fmt.Printf("failed to %s: %v\n", action, err)
+		w.WriteHeader(http.StatusConflict)
+	case errors.Is(err, customer.ErrUnknown), errors.Is(err, customer.ErrInactive):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	case errors.Is(err, customer.ErrUnavailable):
+		fmt.Println("failed to validate customer: ", err)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	case inventory.IsInsufficientStock(err):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case isCatalogError(err):
+		writeCatalogError(w, err)
+	case errors.Is(err, errPaymentsDisabled):
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	case errors.Is(err, payment.ErrDeclined), errors.Is(err, payment.ErrUnavailable),
+		errors.Is(err, payment.ErrInvalidState), errors.Is(err, payment.ErrAmountExceeded),
+		errors.Is(err, payment.ErrUnknownIntent):
+		writePaymentError(w, err)
+	default:
+		fmt.Printf("failed to %s: %v\n", action, err)
+		w.WriteHeader(http.StatusInternalServerError)
+	}
+}
+
+// formatETag tạo ETag từ version của order, ví dụ: "3"
+func formatETag(version uint64) string {
+	return strconv.Quote(strconv.FormatUint(version, 10))
+}
+
+// etagMatches kiểm tra header If-Match có chứa ETag của version hiện tại hay không.
+// Header có thể chứa nhiều ETag cách nhau bởi dấu phẩy, hoặc "*" để khớp mọi version.
+// If-Match dùng so sánh strong nên ETag dạng weak (W/"...") không bao giờ khớp.
+func etagMatches(header string, version uint64) bool {
+	current := formatETag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == current {
+			return true
+		}
+	}
+	return false
+}
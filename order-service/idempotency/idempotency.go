@@ -0,0 +1,44 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+)
+
+// Thời gian giữ kết quả của một Idempotency-Key nếu không cấu hình
+const DefaultTTL = 24 * time.Hour
+
+// Record là thông tin được lưu cho mỗi Idempotency-Key
+type Record struct {
+	Fingerprint string `json:"fingerprint"`           // hash của request đầu tiên dùng key này
+	Completed   bool   `json:"completed"`             // false khi request đầu tiên vẫn đang xử lý
+	StatusCode  int    `json:"status_code,omitempty"` // response đã trả về cho request đầu tiên
+	ContentType string `json:"content_type,omitempty"`
+	Body        []byte `json:"body,omitempty"`
+}
+
+/*
+Store lưu Idempotency-Key và response tương ứng:
+  - Begin giữ chỗ key cho request hiện tại. Nếu key đã tồn tại thì trả về record
+    đang lưu và started = false.
+  - Complete lưu response của request đã giữ chỗ.
+  - Release xóa key khi request lỗi để client có thể thử lại với cùng key.
+*/
+type Store interface {
+	Begin(ctx context.Context, key, fingerprint string) (rec Record, started bool, err error)
+	Complete(ctx context.Context, key string, rec Record) error
+	Release(ctx context.Context, key string) error
+}
+
+// Fingerprint tính hash SHA-256 của method, path và body request
+func Fingerprint(method, path string, body []byte) string {
+	h := sha256.New()
+	h.Write([]byte(method))
+	h.Write([]byte{0})
+	h.Write([]byte(path))
+	h.Write([]byte{0})
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
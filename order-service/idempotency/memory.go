@@ -0,0 +1,73 @@
+package idempotency
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore lưu Idempotency-Key trong bộ nhớ, dùng khi không có Redis
+type MemoryStore struct {
+	TTL time.Duration // để 0 sẽ dùng DefaultTTL
+
+	mu      sync.Mutex
+	records map[string]memoryEntry
+}
+
+type memoryEntry struct {
+	rec       Record
+	expiresAt time.Time
+}
+
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{
+		TTL:     ttl,
+		records: make(map[string]memoryEntry),
+	}
+}
+
+func (s *MemoryStore) expiry() time.Time {
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return time.Now().Add(ttl)
+}
+
+func (s *MemoryStore) Begin(ctx context.Context, key, fingerprint string) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Dọn các key đã hết hạn (store này chỉ dùng khi dev nên số key nhỏ)
+	now := time.Now()
+	for k, entry := range s.records {
+		if !now.Before(entry.expiresAt) {
+			delete(s.records, k)
+		}
+	}
+
+	if entry, exist := s.records[key]; exist {
+		return entry.rec, false, nil
+	}
+
+	rec := Record{Fingerprint: fingerprint}
+	s.records[key] = memoryEntry{rec: rec, expiresAt: s.expiry()}
+	return rec, true, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, rec Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rec.Completed = true
+	s.records[key] = memoryEntry{rec: rec, expiresAt: s.expiry()}
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.records, key)
+	return nil
+}
@@ -0,0 +1,81 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisStore lưu Idempotency-Key trong Redis, mỗi key tự hết hạn sau TTL
+type RedisStore struct {
+	Client *redis.Client
+	TTL    time.Duration // để 0 sẽ dùng DefaultTTL
+}
+
+// Tạo key Redis dạng: "idempotency:abc-123"
+func recordKey(key string) string {
+	return fmt.Sprintf("idempotency:%s", key)
+}
+
+func (s *RedisStore) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return DefaultTTL
+	}
+	return s.TTL
+}
+
+func (s *RedisStore) Begin(ctx context.Context, key, fingerprint string) (Record, bool, error) {
+	// 1. Giữ chỗ key bằng SETNX với record chưa hoàn thành
+	rec := Record{Fingerprint: fingerprint}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return Record{}, false, fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+
+	ok, err := s.Client.SetNX(ctx, recordKey(key), data, s.ttl()).Result()
+	if err != nil {
+		return Record{}, false, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if ok {
+		return rec, true, nil
+	}
+
+	// 2. Key đã tồn tại: đọc record đang lưu
+	value, err := s.Client.Get(ctx, recordKey(key)).Bytes()
+	if errors.Is(err, redis.Nil) {
+		// Key vừa hết hạn hoặc bị Release, thử giữ chỗ lại
+		return s.Begin(ctx, key, fingerprint)
+	} else if err != nil {
+		return Record{}, false, fmt.Errorf("failed to get idempotency record: %w", err)
+	}
+
+	var existing Record
+	if err := json.Unmarshal(value, &existing); err != nil {
+		return Record{}, false, fmt.Errorf("failed to decode idempotency record: %w", err)
+	}
+	return existing, false, nil
+}
+
+func (s *RedisStore) Complete(ctx context.Context, key string, rec Record) error {
+	rec.Completed = true
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode idempotency record: %w", err)
+	}
+
+	if err := s.Client.Set(ctx, recordKey(key), data, s.ttl()).Err(); err != nil {
+		return fmt.Errorf("failed to save idempotency record: %w", err)
+	}
+	return nil
+}
+
+func (s *RedisStore) Release(ctx context.Context, key string) error {
+	if err := s.Client.Del(ctx, recordKey(key)).Err(); err != nil {
+		return fmt.Errorf("failed to release idempotency key: %w", err)
+	}
+	return nil
+}
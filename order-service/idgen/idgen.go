@@ -0,0 +1,17 @@
+package idgen
+
+import (
+	"context"
+)
+
+// Generator sinh order ID mới. ID phải không trùng nhau và tăng dần
+// theo thời gian tạo, để danh sách đơn hàng có thể sắp xếp theo ID.
+type Generator interface {
+	NextID(ctx context.Context) (uint64, error)
+}
+
+// Advancer là Generator có thể bỏ qua các ID đã được dùng ở nơi khác
+// (ví dụ đơn hàng được nhập hàng loạt), để NextID sau đó luôn lớn hơn id
+type Advancer interface {
+	Advance(ctx context.Context, id uint64) error
+}
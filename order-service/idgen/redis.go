@@ -0,0 +1,52 @@
+package idgen
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Key mặc định chứa bộ đếm order ID trong Redis
+const DefaultSequenceKey = "order_id_seq"
+
+// RedisSequence sinh ID bằng lệnh INCR của Redis.
+// INCR là atomic nên nhiều instance dùng chung một Redis vẫn không bị trùng ID.
+type RedisSequence struct {
+	Client *redis.Client
+	Key    string // để trống sẽ dùng DefaultSequenceKey
+}
+
+var _ Advancer = (*RedisSequence)(nil)
+
+func (s *RedisSequence) key() string {
+	if s.Key == "" {
+		return DefaultSequenceKey
+	}
+	return s.Key
+}
+
+func (s *RedisSequence) NextID(ctx context.Context) (uint64, error) {
+	id, err := s.Client.Incr(ctx, s.key()).Result()
+	if err != nil {
+		return 0, fmt.Errorf("failed to incr order id sequence: %w", err)
+	}
+	return uint64(id), nil
+}
+
+// advanceScript chỉ tăng bộ đếm lên id, không bao giờ làm bộ đếm giảm
+var advanceScript = redis.NewScript(`
+local current = tonumber(redis.call('GET', KEYS[1]) or '0')
+if current < tonumber(ARGV[1]) then
+	redis.call('SET', KEYS[1], ARGV[1])
+end
+return 0
+`)
+
+// Advance đưa bộ đếm lên ít nhất id để INCR sau đó không sinh lại ID đã dùng
+func (s *RedisSequence) Advance(ctx context.Context, id uint64) error {
+	if err := advanceScript.Run(ctx, s.Client, []string{s.key()}, id).Err(); err != nil {
+		return fmt.Errorf("failed to advance order id sequence: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package idgen
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+/*
+Snowflake sinh ID 63 bit theo thời gian, không cần lưu trạng thái chung:
+  - 41 bit: số mili giây tính từ Epoch (dùng được khoảng 69 năm)
+  - 10 bit: node ID, mỗi instance order-service phải có node ID khác nhau
+  - 12 bit: số thứ tự trong cùng một mili giây (tối đa 4096 ID/ms)
+*/
+const (
+	nodeBits     = 10
+	sequenceBits = 12
+
+	MaxNodeID   = 1<<nodeBits - 1
+	maxSequence = 1<<sequenceBits - 1
+)
+
+// Epoch là mốc thời gian bắt đầu tính ID (2024-01-01 UTC)
+var Epoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+type Snowflake struct {
+	mu       sync.Mutex
+	node     uint64
+	lastMs   int64  // mili giây của ID sinh gần nhất
+	sequence uint64 // số thứ tự trong lastMs
+
+	now func() time.Time // cho phép thay đồng hồ khi test
+}
+
+func NewSnowflake(nodeID uint16) (*Snowflake, error) {
+	if nodeID > MaxNodeID {
+		return nil, fmt.Errorf("node id %d out of range [0, %d]", nodeID, MaxNodeID)
+	}
+	return &Snowflake{
+		node: uint64(nodeID),
+		now:  time.Now,
+	}, nil
+}
+
+func (s *Snowflake) NextID(ctx context.Context) (uint64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ms := s.now().Sub(Epoch).Milliseconds()
+
+	// Nếu đồng hồ bị lùi lại thì tiếp tục dùng mốc cũ để ID vẫn tăng dần
+	if ms < s.lastMs {
+		ms = s.lastMs
+	}
+
+	if ms == s.lastMs {
+		s.sequence = (s.sequence + 1) & maxSequence
+		// Hết số thứ tự trong mili giây này, chờ sang mili giây kế tiếp
+		if s.sequence == 0 {
+			for ms <= s.lastMs {
+				if err := ctx.Err(); err != nil {
+					return 0, err
+				}
+				time.Sleep(time.Millisecond / 10)
+				ms = s.now().Sub(Epoch).Milliseconds()
+			}
+		}
+	} else {
+		s.sequence = 0
+	}
+	s.lastMs = ms
+
+	return uint64(ms)<<(nodeBits+sequenceBits) | s.node<<sequenceBits | s.sequence, nil
+}
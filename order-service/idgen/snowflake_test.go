@@ -0,0 +1,115 @@
+package idgen
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// fakeClock trả về lần lượt các thời điểm trong times, hết thì giữ thời điểm cuối
+type fakeClock struct {
+	times []time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	t := c.times[0]
+	if len(c.times) > 1 {
+		c.times = c.times[1:]
+	}
+	return t
+}
+
+func newTestSnowflake(t *testing.T, nodeID uint16, times ...time.Time) *Snowflake {
+	t.Helper()
+	s, err := NewSnowflake(nodeID)
+	if err != nil {
+		t.Fatalf("NewSnowflake: %v", err)
+	}
+	s.now = (&fakeClock{times: times}).now
+	return s
+}
+
+func TestNewSnowflakeRejectsNodeOutOfRange(t *testing.T) {
+	if _, err := NewSnowflake(MaxNodeID); err != nil {
+		t.Fatalf("NewSnowflake(%d): %v", MaxNodeID, err)
+	}
+	if _, err := NewSnowflake(MaxNodeID + 1); err == nil {
+		t.Fatalf("NewSnowflake(%d) succeeded", MaxNodeID+1)
+	}
+}
+
+func TestSnowflakeNextID(t *testing.T) {
+	base := Epoch.Add(time.Hour)
+	ms := func(n int) time.Time { return base.Add(time.Duration(n) * time.Millisecond) }
+
+	tests := []struct {
+		name  string
+		clock []time.Time
+		want  []int64 // mili giây được mã hóa trong từng ID
+	}{
+		{"same millisecond", []time.Time{ms(0), ms(0), ms(0)}, []int64{0, 0, 0}},
+		{"clock moves forward", []time.Time{ms(0), ms(1), ms(5)}, []int64{0, 1, 5}},
+		{"clock rolls back", []time.Time{ms(5), ms(2), ms(3), ms(6)}, []int64{5, 5, 5, 6}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := newTestSnowflake(t, 7, tt.clock...)
+			var last uint64
+			for i, want := range tt.want {
+				id, err := s.NextID(context.Background())
+				if err != nil {
+					t.Fatalf("NextID: %v", err)
+				}
+				if i > 0 && id <= last {
+					t.Fatalf("id %d = %d, not greater than previous %d", i, id, last)
+				}
+				last = id
+
+				gotMs := int64(id>>(nodeBits+sequenceBits)) - base.Sub(Epoch).Milliseconds()
+				node := id >> sequenceBits & MaxNodeID
+				if gotMs != want || node != 7 {
+					t.Fatalf("id %d encodes ms %d node %d, want ms %d node 7", i, gotMs, node, want)
+				}
+			}
+		})
+	}
+}
+
+// Hết số thứ tự trong một mili giây thì chờ sang mili giây kế tiếp
+func TestSnowflakeSequenceOverflow(t *testing.T) {
+	base := Epoch.Add(time.Hour)
+	clock := make([]time.Time, maxSequence+2)
+	for i := range clock {
+		clock[i] = base
+	}
+	clock = append(clock, base.Add(time.Millisecond))
+	s := newTestSnowflake(t, 1, clock...)
+
+	ctx := context.Background()
+	var last uint64
+	for i := 0; i <= maxSequence+1; i++ {
+		id, err := s.NextID(ctx)
+		if err != nil {
+			t.Fatalf("NextID: %v", err)
+		}
+		if i > 0 && id <= last {
+			t.Fatalf("id %d = %d, not greater than previous %d", i, id, last)
+		}
+		last = id
+	}
+	if seq := last & maxSequence; seq != 0 {
+		t.Fatalf("sequence after overflow = %d, want 0", seq)
+	}
+
+	// Đồng hồ đứng yên thì dừng khi context bị hủy
+	stuck := newTestSnowflake(t, 1, base)
+	for i := 0; i <= maxSequence; i++ {
+		stuck.NextID(ctx)
+	}
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := stuck.NextID(cancelled); !errors.Is(err, context.Canceled) {
+		t.Fatalf("NextID with stuck clock error = %v, want context.Canceled", err)
+	}
+}
@@ -0,0 +1,69 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/google/uuid"
+)
+
+var (
+	// Dùng để báo lỗi khi order ID đã có reservation (ID bị trùng)
+	ErrReservationExists = errors.New("reservation already exists")
+
+	// Dùng để báo lỗi khi order không có reservation (đã hết hạn, đã hủy hoặc đã commit)
+	ErrNoReservation = errors.New("reservation does not exist")
+)
+
+// InsufficientStockError được trả về khi một mặt hàng không đủ hàng để giữ chỗ.
+// Khi lỗi này xảy ra không có mặt hàng nào của order được giữ chỗ.
+type InsufficientStockError struct {
+	ItemID    uuid.UUID
+	Requested uint64
+	Available uint64
+}
+
+func (e *InsufficientStockError) Error() string {
+	return fmt.Sprintf("insufficient stock for item %s: requested %d, available %d", e.ItemID, e.Requested, e.Available)
+}
+
+// Line là số lượng cần giữ chỗ của một mặt hàng
+type Line struct {
+	ItemID   uuid.UUID
+	Quantity uint64
+}
+
+// LinesOf gom line item của order thành số lượng theo từng mặt hàng
+func LinesOf(o model.Order) []Line {
+	lines := make([]Line, 0, len(o.LineItems))
+	index := make(map[uuid.UUID]int, len(o.LineItems))
+	for _, item := range o.LineItems {
+		if i, ok := index[item.ItemID]; ok {
+			lines[i].Quantity += uint64(item.Quantity)
+			continue
+		}
+		index[item.ItemID] = len(lines)
+		lines = append(lines, Line{ItemID: item.ItemID, Quantity: uint64(item.Quantity)})
+	}
+	return lines
+}
+
+// Stock là tình trạng tồn kho của một mặt hàng
+type Stock struct {
+	ItemID    uuid.UUID `json:"item_id"`
+	OnHand    uint64    `json:"on_hand"`   // số lượng thực có trong kho
+	Reserved  uint64    `json:"reserved"`  // số lượng đang được giữ cho các đơn chưa giao
+	Available uint64    `json:"available"` // OnHand - Reserved, số lượng còn đặt được
+}
+
+// Reserver là các thao tác giữ chỗ hàng mà handler đơn hàng cần,
+// RedisStore hiện thực interface này
+type Reserver interface {
+	Reserve(ctx context.Context, orderID uint64, lines []Line) error // giữ chỗ cho đơn mới
+	Replace(ctx context.Context, orderID uint64, lines []Line) error // giữ chỗ lại khi line item thay đổi
+	Release(ctx context.Context, orderID uint64) error               // trả hàng khi đơn bị hủy
+	Commit(ctx context.Context, orderID uint64) error                // trừ kho khi đơn được giao đi
+	Persist(ctx context.Context, orderID uint64) error               // bỏ hạn giữ chỗ khi đơn được xác nhận
+}
@@ -0,0 +1,351 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Thời gian giữ chỗ mặc định của đơn chưa được xác nhận
+const DefaultTTL = 30 * time.Minute
+
+// Số lần đọc lại reservation khi nó bị request khác sửa giữa lúc đọc và chạy script
+const maxScriptAttempts = 3
+
+// Mã trả về của các Lua script
+const (
+	scriptOK       = 0
+	scriptExists   = -1 // order đã có reservation
+	scriptStale    = -2 // danh sách mặt hàng truyền vào không khớp reservation hiện tại
+	scriptNotFound = -3 // order không có reservation
+)
+
+/*
+Các key Redis của kho:
+  - "inventory:item:{item_id}": hash {on_hand, reserved} của một mặt hàng
+  - "inventory:reservation:{order_id}": hash {item_id: số lượng} đang giữ cho một order
+  - "inventory:reservations": sorted set order ID, score là thời điểm hết hạn (ms)
+
+Mọi thay đổi số lượng đều chạy trong Lua script nên Redis thực hiện nguyên khối:
+các request đồng thời không thể cùng lấy phần hàng cuối cùng.
+*/
+const reservationsKey = "inventory:reservations"
+
+func stockKey(id uuid.UUID) string {
+	return fmt.Sprintf("inventory:item:%s", id)
+}
+
+func reservationKey(orderID uint64) string {
+	return fmt.Sprintf("inventory:reservation:%d", orderID)
+}
+
+/*
+reserveScript giữ chỗ cho toàn bộ mặt hàng của một order (tất cả hoặc không gì cả).
+
+	KEYS[1] reservation của order, KEYS[2] sorted set hạn giữ chỗ, KEYS[3..] hash tồn kho từng mặt hàng
+	ARGV[1] order ID, ARGV[2] thời điểm hết hạn (ms), ARGV[3] "new" hoặc "replace",
+	ARGV[4..] từng cặp item_id, số lượng theo đúng thứ tự KEYS[3..]
+
+Với "replace", số lượng đang giữ của order được tính lại thành số lượng mới,
+mặt hàng có số lượng 0 được trả lại kho. Trả về {0} nếu thành công,
+{i, available} nếu mặt hàng thứ i không đủ hàng.
+*/
+var reserveScript = redis.NewScript(`
+local reservation = KEYS[1]
+local exists = redis.call('EXISTS', reservation) == 1
+if exists and ARGV[3] ~= 'replace' then
+  return {-1}
+end
+
+local n = #KEYS - 2
+if exists then
+  local given = {}
+  for i = 1, n do given[ARGV[2 + 2 * i]] = true end
+  for _, item in ipairs(redis.call('HKEYS', reservation)) do
+    if not given[item] then return {-2} end
+  end
+end
+
+for i = 1, n do
+  local qty = tonumber(ARGV[3 + 2 * i])
+  local onHand = tonumber(redis.call('HGET', KEYS[2 + i], 'on_hand') or 0)
+  local reserved = tonumber(redis.call('HGET', KEYS[2 + i], 'reserved') or 0)
+  local held = tonumber(redis.call('HGET', reservation, ARGV[2 + 2 * i]) or 0)
+  local available = onHand - reserved + held
+  if qty > available then
+    return {i, math.max(available, 0)}
+  end
+end
+
+for i = 1, n do
+  local item = ARGV[2 + 2 * i]
+  local qty = tonumber(ARGV[3 + 2 * i])
+  local held = tonumber(redis.call('HGET', reservation, item) or 0)
+  if qty ~= held then
+    redis.call('HINCRBY', KEYS[2 + i], 'reserved', qty - held)
+  end
+  if qty > 0 then
+    redis.call('HSET', reservation, item, qty)
+  else
+    redis.call('HDEL', reservation, item)
+  end
+end
+
+if redis.call('EXISTS', reservation) == 0 then
+  redis.call('ZREM', KEYS[2], ARGV[1])
+elseif not exists then
+  redis.call('ZADD', KEYS[2], ARGV[2], ARGV[1])
+end
+return {0}
+`)
+
+/*
+settleScript kết thúc reservation của một order.
+
+	KEYS[1] reservation của order, KEYS[2] sorted set hạn giữ chỗ, KEYS[3..] hash tồn kho từng mặt hàng
+	ARGV[1] order ID, ARGV[2] "release" (trả hàng về kho) hoặc "commit" (trừ hẳn khỏi kho),
+	ARGV[3..] item_id theo đúng thứ tự KEYS[3..]
+*/
+var settleScript = redis.NewScript(`
+local reservation = KEYS[1]
+if redis.call('EXISTS', reservation) == 0 then
+  redis.call('ZREM', KEYS[2], ARGV[1])
+  return -3
+end
+
+local n = #KEYS - 2
+if redis.call('HLEN', reservation) ~= n then return -2 end
+for i = 1, n do
+  if not redis.call('HGET', reservation, ARGV[2 + i]) then return -2 end
+end
+
+for i = 1, n do
+  local qty = tonumber(redis.call('HGET', reservation, ARGV[2 + i]))
+  redis.call('HINCRBY', KEYS[2 + i], 'reserved', -qty)
+  if ARGV[2] == 'commit' then
+    redis.call('HINCRBY', KEYS[2 + i], 'on_hand', -qty)
+  end
+end
+redis.call('DEL', reservation)
+redis.call('ZREM', KEYS[2], ARGV[1])
+return 0
+`)
+
+// RedisStore quản lý tồn kho và giữ chỗ hàng cho đơn hàng trong Redis
+type RedisStore struct {
+	Client *redis.Client
+	TTL    time.Duration // thời gian giữ chỗ của đơn chưa xác nhận, 0 thì dùng DefaultTTL
+}
+
+func (s *RedisStore) ttl() time.Duration {
+	if s.TTL <= 0 {
+		return DefaultTTL
+	}
+	return s.TTL
+}
+
+// SetStock đặt số lượng thực có trong kho của một mặt hàng
+func (s *RedisStore) SetStock(ctx context.Context, itemID uuid.UUID, onHand uint64) error {
+	if err := s.Client.HSet(ctx, stockKey(itemID), "on_hand", onHand).Err(); err != nil {
+		return fmt.Errorf("failed to set stock: %w", err)
+	}
+	return nil
+}
+
+// GetStock trả về tình trạng tồn kho của một mặt hàng, mặt hàng chưa nhập kho có số lượng 0
+func (s *RedisStore) GetStock(ctx context.Context, itemID uuid.UUID) (Stock, error) {
+	values, err := s.Client.HMGet(ctx, stockKey(itemID), "on_hand", "reserved").Result()
+	if err != nil {
+		return Stock{}, fmt.Errorf("failed to get stock: %w", err)
+	}
+
+	stock := Stock{ItemID: itemID}
+	for i, target := range []*uint64{&stock.OnHand, &stock.Reserved} {
+		value, ok := values[i].(string)
+		if !ok {
+			continue
+		}
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return Stock{}, fmt.Errorf("invalid stock value %q: %w", value, err)
+		}
+		if n > 0 {
+			*target = uint64(n)
+		}
+	}
+	if stock.OnHand > stock.Reserved {
+		stock.Available = stock.OnHand - stock.Reserved
+	}
+	return stock, nil
+}
+
+// Reserve giữ chỗ toàn bộ lines cho một order mới, hết hạn sau TTL nếu không được Persist.
+// Trả về *InsufficientStockError nếu có mặt hàng không đủ hàng (không giữ mặt hàng nào),
+// ErrReservationExists nếu order ID đã có reservation.
+func (s *RedisStore) Reserve(ctx context.Context, orderID uint64, lines []Line) error {
+	return s.reserve(ctx, orderID, lines, "new")
+}
+
+// Replace thay số lượng đang giữ của order bằng lines (khi line item thay đổi),
+// mặt hàng không còn trong lines được trả lại kho. Không đủ hàng thì giữ nguyên reservation cũ.
+func (s *RedisStore) Replace(ctx context.Context, orderID uint64, lines []Line) error {
+	return s.reserve(ctx, orderID, lines, "replace")
+}
+
+func (s *RedisStore) reserve(ctx context.Context, orderID uint64, lines []Line, mode string) error {
+	expiresAt := time.Now().Add(s.ttl()).UnixMilli()
+
+	for attempt := 0; attempt < maxScriptAttempts; attempt++ {
+		// 1. Với "replace", thêm các mặt hàng đang giữ nhưng không còn trong lines (số lượng 0)
+		all := lines
+		if mode == "replace" {
+			held, err := s.Client.HKeys(ctx, reservationKey(orderID)).Result()
+			if err != nil {
+				return fmt.Errorf("failed to get reservation: %w", err)
+			}
+			all = withReleased(lines, held)
+		}
+
+		keys := []string{reservationKey(orderID), reservationsKey}
+		args := []interface{}{orderID, expiresAt, mode}
+		for _, line := range all {
+			keys = append(keys, stockKey(line.ItemID))
+			args = append(args, line.ItemID.String(), line.Quantity)
+		}
+
+		// 2. Chạy script giữ chỗ
+		res, err := reserveScript.Run(ctx, s.Client, keys, args...).Int64Slice()
+		if err != nil {
+			return fmt.Errorf("failed to reserve stock: %w", err)
+		}
+
+		switch code := res[0]; {
+		case code == scriptOK:
+			return nil
+		case code == scriptExists:
+			return ErrReservationExists
+		case code == scriptStale:
+			continue // reservation vừa bị sửa, đọc lại rồi thử lại
+		case code > 0 && len(res) > 1:
+			line := all[code-1]
+			return &InsufficientStockError{ItemID: line.ItemID, Requested: line.Quantity, Available: uint64(res[1])}
+		default:
+			return fmt.Errorf("unexpected reserve result %v", res)
+		}
+	}
+	return fmt.Errorf("failed to reserve stock: reservation of order %d keeps changing", orderID)
+}
+
+// withReleased trả về lines kèm các mặt hàng trong held không có trong lines với số lượng 0
+func withReleased(lines []Line, held []string) []Line {
+	all := append([]Line{}, lines...)
+	for _, value := range held {
+		id, err := uuid.Parse(value)
+		if err != nil {
+			continue
+		}
+		found := false
+		for _, line := range lines {
+			if line.ItemID == id {
+				found = true
+				break
+			}
+		}
+		if !found {
+			all = append(all, Line{ItemID: id})
+		}
+	}
+	return all
+}
+
+// Release trả toàn bộ hàng đang giữ của order về kho (đơn bị hủy hoặc hết hạn giữ chỗ).
+// Trả về ErrNoReservation nếu order không còn reservation.
+func (s *RedisStore) Release(ctx context.Context, orderID uint64) error {
+	return s.settle(ctx, orderID, "release")
+}
+
+// Commit trừ hẳn hàng đang giữ của order khỏi kho (đơn đã giao cho vận chuyển).
+// Trả về ErrNoReservation nếu order không còn reservation.
+func (s *RedisStore) Commit(ctx context.Context, orderID uint64) error {
+	return s.settle(ctx, orderID, "commit")
+}
+
+func (s *RedisStore) settle(ctx context.Context, orderID uint64, mode string) error {
+	for attempt := 0; attempt < maxScriptAttempts; attempt++ {
+		held, err := s.Client.HKeys(ctx, reservationKey(orderID)).Result()
+		if err != nil {
+			return fmt.Errorf("failed to get reservation: %w", err)
+		}
+
+		keys := []string{reservationKey(orderID), reservationsKey}
+		args := []interface{}{orderID, mode}
+		for _, item := range held {
+			id, err := uuid.Parse(item)
+			if err != nil {
+				return fmt.Errorf("invalid item id %q in reservation: %w", item, err)
+			}
+			keys = append(keys, stockKey(id))
+			args = append(args, item)
+		}
+
+		code, err := settleScript.Run(ctx, s.Client, keys, args...).Int64()
+		if err != nil {
+			return fmt.Errorf("failed to %s reservation: %w", mode, err)
+		}
+		switch code {
+		case scriptOK:
+			return nil
+		case scriptNotFound:
+			return ErrNoReservation
+		case scriptStale:
+			continue
+		default:
+			return fmt.Errorf("unexpected %s result %d", mode, code)
+		}
+	}
+	return fmt.Errorf("failed to %s reservation: reservation of order %d keeps changing", mode, orderID)
+}
+
+// Persist bỏ hạn giữ chỗ của order, dùng khi đơn đã được xác nhận:
+// hàng được giữ cho đến khi đơn được giao (Commit) hoặc bị hủy (Release)
+func (s *RedisStore) Persist(ctx context.Context, orderID uint64) error {
+	if err := s.Client.ZRem(ctx, reservationsKey, orderID).Err(); err != nil {
+		return fmt.Errorf("failed to persist reservation: %w", err)
+	}
+	return nil
+}
+
+// Expired trả về tối đa limit order ID có reservation đã hết hạn tại thời điểm now
+func (s *RedisStore) Expired(ctx context.Context, now time.Time, limit int64) ([]uint64, error) {
+	members, err := s.Client.ZRangeArgs(ctx, redis.ZRangeArgs{
+		Key:     reservationsKey,
+		Start:   "-inf",
+		Stop:    strconv.FormatInt(now.UnixMilli(), 10),
+		ByScore: true,
+		Count:   limit,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get expired reservations: %w", err)
+	}
+
+	ids := make([]uint64, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid order id %q in reservations: %w", member, err)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// IsInsufficientStock cho biết err có phải lỗi không đủ hàng hay không
+func IsInsufficientStock(err error) bool {
+	var insufficient *InsufficientStockError
+	return errors.As(err, &insufficient)
+}
@@ -0,0 +1,181 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	itemA = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+	itemB = uuid.MustParse("00000000-0000-0000-0000-00000000000b")
+	itemC = uuid.MustParse("00000000-0000-0000-0000-00000000000c")
+)
+
+func newTestStore(t *testing.T) *RedisStore {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	s := &RedisStore{Client: client}
+	for id, onHand := range map[uuid.UUID]uint64{itemA: 10, itemB: 5, itemC: 2} {
+		if err := s.SetStock(context.Background(), id, onHand); err != nil {
+			t.Fatalf("SetStock: %v", err)
+		}
+	}
+	return s
+}
+
+// expectStock so sánh on_hand và reserved của từng mặt hàng
+func expectStock(t *testing.T, s *RedisStore, want map[uuid.UUID][2]uint64) {
+	t.Helper()
+	for id, w := range want {
+		stock, err := s.GetStock(context.Background(), id)
+		if err != nil {
+			t.Fatalf("GetStock: %v", err)
+		}
+		if stock.OnHand != w[0] || stock.Reserved != w[1] || stock.Available != w[0]-w[1] {
+			t.Fatalf("stock of %s = %+v, want on_hand=%d reserved=%d", id, stock, w[0], w[1])
+		}
+	}
+}
+
+func TestReserveAllOrNothing(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	err := s.Reserve(ctx, 1, []Line{{ItemID: itemA, Quantity: 3}, {ItemID: itemC, Quantity: 3}})
+	var insufficient *InsufficientStockError
+	if !errors.As(err, &insufficient) || insufficient.ItemID != itemC || insufficient.Requested != 3 || insufficient.Available != 2 {
+		t.Fatalf("Reserve error = %v, want insufficient stock of item C", err)
+	}
+	expectStock(t, s, map[uuid.UUID][2]uint64{itemA: {10, 0}, itemC: {2, 0}})
+
+	if err := s.Reserve(ctx, 1, []Line{{ItemID: itemA, Quantity: 3}, {ItemID: itemC, Quantity: 2}}); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	expectStock(t, s, map[uuid.UUID][2]uint64{itemA: {10, 3}, itemC: {2, 2}})
+
+	if err := s.Reserve(ctx, 1, []Line{{ItemID: itemB, Quantity: 1}}); !errors.Is(err, ErrReservationExists) {
+		t.Fatalf("second Reserve error = %v, want ErrReservationExists", err)
+	}
+
+	// Đơn khác không lấy được phần hàng đã giữ
+	err = s.Reserve(ctx, 2, []Line{{ItemID: itemC, Quantity: 1}})
+	if !errors.As(err, &insufficient) || insufficient.Available != 0 {
+		t.Fatalf("Reserve of held item error = %v, want insufficient stock", err)
+	}
+}
+
+func TestReplace(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	if err := s.Reserve(ctx, 1, []Line{{ItemID: itemA, Quantity: 3}, {ItemID: itemB, Quantity: 2}}); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+
+	// Số lượng đang giữ của chính đơn được tính vào phần còn đặt được
+	if err := s.Replace(ctx, 1, []Line{{ItemID: itemA, Quantity: 10}, {ItemID: itemC, Quantity: 1}}); err != nil {
+		t.Fatalf("Replace: %v", err)
+	}
+	expectStock(t, s, map[uuid.UUID][2]uint64{itemA: {10, 10}, itemB: {5, 0}, itemC: {2, 1}})
+
+	// Không đủ hàng thì giữ nguyên reservation cũ
+	err := s.Replace(ctx, 1, []Line{{ItemID: itemA, Quantity: 1}, {ItemID: itemB, Quantity: 6}})
+	if !IsInsufficientStock(err) {
+		t.Fatalf("Replace error = %v, want insufficient stock", err)
+	}
+	expectStock(t, s, map[uuid.UUID][2]uint64{itemA: {10, 10}, itemB: {5, 0}, itemC: {2, 1}})
+
+	// Bỏ hết mặt hàng thì không còn reservation
+	if err := s.Replace(ctx, 1, nil); err != nil {
+		t.Fatalf("Replace with no lines: %v", err)
+	}
+	expectStock(t, s, map[uuid.UUID][2]uint64{itemA: {10, 0}, itemC: {2, 0}})
+	if err := s.Release(ctx, 1); !errors.Is(err, ErrNoReservation) {
+		t.Fatalf("Release error = %v, want ErrNoReservation", err)
+	}
+}
+
+func TestReleaseAndCommit(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+
+	for id := uint64(1); id <= 2; id++ {
+		if err := s.Reserve(ctx, id, []Line{{ItemID: itemA, Quantity: 4}, {ItemID: itemB, Quantity: 1}}); err != nil {
+			t.Fatalf("Reserve: %v", err)
+		}
+	}
+
+	if err := s.Release(ctx, 1); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	expectStock(t, s, map[uuid.UUID][2]uint64{itemA: {10, 4}, itemB: {5, 1}})
+
+	if err := s.Commit(ctx, 2); err != nil {
+		t.Fatalf("Commit: %v", err)
+	}
+	expectStock(t, s, map[uuid.UUID][2]uint64{itemA: {6, 0}, itemB: {4, 0}})
+
+	for _, id := range []uint64{1, 2, 3} {
+		if err := s.Release(ctx, id); !errors.Is(err, ErrNoReservation) {
+			t.Fatalf("Release(%d) error = %v, want ErrNoReservation", id, err)
+		}
+		if err := s.Commit(ctx, id); !errors.Is(err, ErrNoReservation) {
+			t.Fatalf("Commit(%d) error = %v, want ErrNoReservation", id, err)
+		}
+	}
+}
+
+func TestExpiredAndPersist(t *testing.T) {
+	ctx := context.Background()
+	s := newTestStore(t)
+	s.TTL = time.Minute
+
+	for id := uint64(1); id <= 2; id++ {
+		if err := s.Reserve(ctx, id, []Line{{ItemID: itemA, Quantity: 1}}); err != nil {
+			t.Fatalf("Reserve: %v", err)
+		}
+	}
+	if err := s.Persist(ctx, 2); err != nil {
+		t.Fatalf("Persist: %v", err)
+	}
+
+	ids, err := s.Expired(ctx, time.Now(), 10)
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("Expired now = %v, %v, want none", ids, err)
+	}
+	ids, err = s.Expired(ctx, time.Now().Add(2*time.Minute), 10)
+	if err != nil || len(ids) != 1 || ids[0] != 1 {
+		t.Fatalf("Expired after TTL = %v, %v, want [1]", ids, err)
+	}
+
+	// Trả hàng thì order không còn trong danh sách hết hạn
+	if err := s.Release(ctx, 1); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	ids, err = s.Expired(ctx, time.Now().Add(2*time.Minute), 10)
+	if err != nil || len(ids) != 0 {
+		t.Fatalf("Expired after release = %v, %v, want none", ids, err)
+	}
+}
+
+func TestLinesOf(t *testing.T) {
+	o := model.Order{LineItems: []model.LineItem{
+		{ItemID: itemA, Quantity: 2},
+		{ItemID: itemB, Quantity: 1},
+		{ItemID: itemA, Quantity: 3},
+	}}
+	lines := LinesOf(o)
+	if len(lines) != 2 || lines[0] != (Line{ItemID: itemA, Quantity: 5}) || lines[1] != (Line{ItemID: itemB, Quantity: 1}) {
+		t.Fatalf("LinesOf = %+v", lines)
+	}
+}
@@ -0,0 +1,117 @@
+package inventory
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+)
+
+// Chu kỳ quét reservation hết hạn mặc định
+const DefaultSweepInterval = time.Minute
+
+// Số reservation hết hạn xử lý mỗi lần quét
+const sweepBatch = 100
+
+// Actor ghi vào lịch sử khi đơn bị hủy do hết hạn giữ chỗ
+const sweeperActor = "system:inventory"
+
+/*
+Sweeper định kỳ xử lý các reservation đã hết hạn:
+  - Đơn vẫn đang pending: hủy đơn (ghi lịch sử) rồi trả hàng về kho
+  - Đơn đã bị xóa hoặc đã hủy: chỉ trả hàng về kho
+  - Đơn đã được xác nhận: bỏ hạn giữ chỗ, hàng được giữ đến khi giao hoặc hủy
+*/
+type Sweeper struct {
+	Store    *RedisStore
+	Orders   order.OrderRepository
+	Interval time.Duration // 0 thì dùng DefaultSweepInterval
+}
+
+// Run quét cho đến khi ctx bị hủy, lỗi chỉ được ghi log rồi thử lại ở lần quét sau
+func (s *Sweeper) Run(ctx context.Context) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultSweepInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := s.Sweep(ctx, time.Now()); err != nil && ctx.Err() == nil {
+				fmt.Println("failed to sweep expired reservations: ", err)
+			}
+		}
+	}
+}
+
+// Sweep xử lý các reservation đã hết hạn tại thời điểm now
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) error {
+	ids, err := s.Store.Expired(ctx, now, sweepBatch)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := s.expire(ctx, id, now); err != nil {
+			fmt.Printf("failed to expire reservation of order %d: %v\n", id, err)
+		}
+	}
+	return nil
+}
+
+func (s *Sweeper) expire(ctx context.Context, orderID uint64, now time.Time) error {
+	// 1. Đơn đã bị xóa thì chỉ cần trả hàng
+	o, err := s.Orders.FindByID(ctx, orderID)
+	if errors.Is(err, order.ErrNotExist) {
+		return s.release(ctx, orderID)
+	} else if err != nil {
+		return err
+	}
+
+	switch lifecycle.Current(o) {
+	case model.StatusPending:
+		// 2. Hủy đơn trước rồi mới trả hàng, để hàng không được bán lại
+		// cho đơn khác trong khi đơn này vẫn có thể được xác nhận
+		previous := lifecycle.Current(o)
+		if err := lifecycle.Apply(&o, model.StatusCancelled, now); err != nil {
+			return err
+		}
+		o.Version++
+		err := s.Orders.Update(ctx, o, model.StatusChange{
+			OrderID:   o.OrderID,
+			From:      previous,
+			To:        model.StatusCancelled,
+			Actor:     sweeperActor,
+			Reason:    "inventory reservation expired",
+			ChangedAt: now.UTC(),
+		})
+		if errors.Is(err, order.ErrVersionMismatch) {
+			return nil // đơn vừa được sửa (có thể đã xác nhận), xử lý lại ở lần quét sau
+		} else if err != nil {
+			return err
+		}
+		return s.release(ctx, orderID)
+	case model.StatusCancelled, model.StatusRefunded:
+		return s.release(ctx, orderID)
+	default:
+		// 3. Đơn đã xác nhận nhưng reservation chưa được bỏ hạn
+		return s.Store.Persist(ctx, orderID)
+	}
+}
+
+func (s *Sweeper) release(ctx context.Context, orderID uint64) error {
+	err := s.Store.Release(ctx, orderID)
+	if errors.Is(err, ErrNoReservation) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,249 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/util"
+)
+
+// Guard kiểm tra thêm điều kiện nghiệp vụ trước khi cho phép chuyển trạng thái.
+// Trả về lỗi nếu order chưa đủ điều kiện.
+type Guard func(o model.Order) error
+
+// Transition mô tả một bước chuyển trạng thái hợp lệ
+type Transition struct {
+	From  model.Status
+	To    model.Status
+	Guard Guard // có thể nil nếu không cần kiểm tra thêm
+}
+
+var (
+	errNoLineItems = errors.New("order has no line items")
+	errNotPaid     = errors.New("order has not been paid")
+)
+
+// Chỉ cho phép giao hàng khi đơn có ít nhất một mặt hàng
+func hasLineItems(o model.Order) error {
+	if len(o.LineItems) == 0 {
+		return errNoLineItems
+	}
+	return nil
+}
+
+// Chỉ hoàn tiền khi đơn đã từng được thanh toán
+func wasPaid(o model.Order) error {
+	if o.PaidAt == nil {
+		return errNotPaid
+	}
+	return nil
+}
+
+/*
+Transitions là bảng chuyển trạng thái của đơn hàng:
+
+	pending → confirmed → paid → shipped → delivered → completed
+	   ↓          ↓         ↓                  ↓           ↓
+	cancelled  cancelled  cancelled/refunded  refunded   refunded
+	   ↓
+	refunded (nếu đã thanh toán)
+*/
+var Transitions = []Transition{
+	{From: model.StatusPending, To: model.StatusConfirmed},
+	{From: model.StatusPending, To: model.StatusCancelled},
+
+	{From: model.StatusConfirmed, To: model.StatusPaid},
+	{From: model.StatusConfirmed, To: model.StatusCancelled},
+
+	{From: model.StatusPaid, To: model.StatusShipped, Guard: hasLineItems},
+	{From: model.StatusPaid, To: model.StatusCancelled},
+	{From: model.StatusPaid, To: model.StatusRefunded},
+
+	{From: model.StatusShipped, To: model.StatusDelivered},
+
+	{From: model.StatusDelivered, To: model.StatusCompleted},
+	{From: model.StatusDelivered, To: model.StatusRefunded, Guard: wasPaid},
+
+	{From: model.StatusCompleted, To: model.StatusRefunded, Guard: wasPaid},
+
+	{From: model.StatusCancelled, To: model.StatusRefunded, Guard: wasPaid},
+}
+
+// IllegalTransitionError được trả về khi không thể chuyển sang trạng thái mong muốn
+type IllegalTransitionError struct {
+	From    model.Status
+	To      model.Status
+	Allowed []model.Status // các trạng thái có thể chuyển tới từ From
+	Reason  error          // lỗi của guard, nil nếu bảng không có bước chuyển này
+}
+
+func (e *IllegalTransitionError) Error() string {
+	if e.Reason != nil {
+		return fmt.Sprintf("cannot transition order from %q to %q: %v", e.From, e.To, e.Reason)
+	}
+	return fmt.Sprintf("cannot transition order from %q to %q", e.From, e.To)
+}
+
+func (e *IllegalTransitionError) Unwrap() error {
+	return e.Reason
+}
+
+// legacyStatuses ánh xạ các giá trị order_status tự do thường gặp ở order cũ
+// (client được gửi chuỗi bất kỳ khi tạo đơn) sang trạng thái của state machine
+var legacyStatuses = map[string]model.Status{
+	"new":        model.StatusPending,
+	"created":    model.StatusPending,
+	"open":       model.StatusPending,
+	"placed":     model.StatusPending,
+	"processing": model.StatusConfirmed,
+	"accepted":   model.StatusConfirmed,
+	"approved":   model.StatusConfirmed,
+	"in_transit": model.StatusShipped,
+	"shipping":   model.StatusShipped,
+	"received":   model.StatusDelivered,
+	"complete":   model.StatusCompleted,
+	"done":       model.StatusCompleted,
+	"finished":   model.StatusCompleted,
+	"canceled":   model.StatusCancelled,
+	"refund":     model.StatusRefunded,
+}
+
+// LegacyStatuses trả về bản sao bảng ánh xạ order_status tự do sang trạng thái chuẩn,
+// dùng khi chuẩn hóa dữ liệu cũ ngoài Go (migration SQL)
+func LegacyStatuses() map[string]model.Status {
+	statuses := make(map[string]model.Status, len(legacyStatuses))
+	for name, status := range legacyStatuses {
+		statuses[name] = status
+	}
+	return statuses
+}
+
+/*
+Current trả về trạng thái hiện tại của order.
+Order cũ được tạo trước khi có state machine có thể không có trạng thái hoặc có
+order_status tự do do client gửi lên (ví dụ "Processing", "canceled"), khi đó:
+ 1. Suy ra từ các mốc thời gian đã lưu (PUT cũ chỉ ghi mốc thời gian, không đổi order_status)
+ 2. Chuẩn hóa chuỗi (chữ thường, "-"/khoảng trắng thành "_") rồi tra bảng legacyStatuses
+ 3. Không nhận ra thì coi là pending
+*/
+func Current(o model.Order) model.Status {
+	if o.OrderStatus.Valid() {
+		return o.OrderStatus
+	}
+	switch {
+	case o.CompletedAt != nil:
+		return model.StatusCompleted
+	case o.ShippedAt != nil:
+		return model.StatusShipped
+	}
+
+	name := strings.ToLower(strings.TrimSpace(string(o.OrderStatus)))
+	name = strings.NewReplacer("-", "_", " ", "_").Replace(name)
+	if status := model.Status(name); status.Valid() {
+		return status
+	}
+	if status, ok := legacyStatuses[name]; ok {
+		return status
+	}
+	return model.StatusPending
+}
+
+// Created trả về bản ghi lịch sử đầu tiên của order vừa tạo ("" → pending),
+// actor là người tạo đơn
+func Created(o model.Order, actor string) model.StatusChange {
+	change := model.StatusChange{
+		OrderID: o.OrderID,
+		From:    "",
+		To:      model.StatusPending,
+		Actor:   actor,
+		Reason:  "order created",
+	}
+	if o.CreateAt != nil {
+		change.ChangedAt = o.CreateAt.UTC()
+	}
+	return change
+}
+
+// AllowedNext liệt kê các trạng thái có thể chuyển tới từ from theo bảng Transitions
+func AllowedNext(from model.Status) []model.Status {
+	allowed := []model.Status{}
+	for _, t := range Transitions {
+		if t.From == from {
+			allowed = append(allowed, t.To)
+		}
+	}
+	return allowed
+}
+
+func find(from, to model.Status) (Transition, bool) {
+	for _, t := range Transitions {
+		if t.From == from && t.To == to {
+			return t, true
+		}
+	}
+	return Transition{}, false
+}
+
+// Apply chuyển order sang trạng thái to và ghi lại mốc thời gian tương ứng.
+// Nếu bước chuyển không có trong bảng hoặc guard từ chối thì order giữ nguyên
+// và trả về *IllegalTransitionError.
+func Apply(o *model.Order, to model.Status, now time.Time) error {
+	from := Current(*o)
+
+	t, ok := find(from, to)
+	if !ok {
+		return &IllegalTransitionError{From: from, To: to, Allowed: AllowedNext(from)}
+	}
+	if t.Guard != nil {
+		if err := t.Guard(*o); err != nil {
+			return &IllegalTransitionError{From: from, To: to, Allowed: AllowedNext(from), Reason: err}
+		}
+	}
+
+	ts := util.CustomTime(now)
+	switch to {
+	case model.StatusConfirmed:
+		o.ConfirmedAt = &ts
+	case model.StatusPaid:
+		o.PaidAt = &ts
+	case model.StatusShipped:
+		o.ShippedAt = &ts
+	case model.StatusDelivered:
+		o.DeliveredAt = &ts
+	case model.StatusCompleted:
+		o.CompletedAt = &ts
+	case model.StatusCancelled:
+		o.CancelledAt = &ts
+	case model.StatusRefunded:
+		o.RefundedAt = &ts
+	}
+	o.OrderStatus = to
+
+	return nil
+}
+
+// Dùng để báo lỗi khi sửa line item của đơn đã thanh toán, đã giao hoặc đã đóng
+var ErrItemsLocked = errors.New("line items can only be changed before the order is paid")
+
+// CanEditItems kiểm tra order còn được thêm/sửa/xóa line item hay không:
+// chỉ khi đơn còn pending/confirmed, chưa giao và chưa thu tiền lần nào.
+// Tiền giữ chỗ (authorized) sai số tiền sẽ được hủy và giữ lại ở lần Pay sau.
+func CanEditItems(o model.Order) error {
+	if o.ShippedAt != nil {
+		return ErrItemsLocked
+	}
+	for _, p := range o.Payments {
+		if p.Captured > 0 {
+			return ErrItemsLocked
+		}
+	}
+	switch Current(o) {
+	case model.StatusPending, model.StatusConfirmed:
+		return nil
+	default:
+		return ErrItemsLocked
+	}
+}
@@ -0,0 +1,183 @@
+package lifecycle
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/util"
+	"github.com/google/uuid"
+)
+
+var allStatuses = []model.Status{
+	model.StatusPending,
+	model.StatusConfirmed,
+	model.StatusPaid,
+	model.StatusShipped,
+	model.StatusDelivered,
+	model.StatusCompleted,
+	model.StatusCancelled,
+	model.StatusRefunded,
+}
+
+func TestAllowedNext(t *testing.T) {
+	tests := []struct {
+		from model.Status
+		want []model.Status
+	}{
+		{model.StatusPending, []model.Status{model.StatusConfirmed, model.StatusCancelled}},
+		{model.StatusConfirmed, []model.Status{model.StatusPaid, model.StatusCancelled}},
+		{model.StatusPaid, []model.Status{model.StatusShipped, model.StatusCancelled, model.StatusRefunded}},
+		{model.StatusShipped, []model.Status{model.StatusDelivered}},
+		{model.StatusDelivered, []model.Status{model.StatusCompleted, model.StatusRefunded}},
+		{model.StatusCompleted, []model.Status{model.StatusRefunded}},
+		{model.StatusCancelled, []model.Status{model.StatusRefunded}},
+		{model.StatusRefunded, []model.Status{}},
+	}
+	for _, tt := range tests {
+		if got := AllowedNext(tt.from); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("AllowedNext(%s) = %v, want %v", tt.from, got, tt.want)
+		}
+	}
+}
+
+// Mọi cặp trạng thái không có trong bảng Transitions đều bị từ chối và order giữ nguyên
+func TestApplyRejectsUnknownTransitions(t *testing.T) {
+	for _, from := range allStatuses {
+		for _, to := range allStatuses {
+			if _, ok := find(from, to); ok {
+				continue
+			}
+			o := model.Order{OrderStatus: from}
+			err := Apply(&o, to, time.Now())
+			var illegal *IllegalTransitionError
+			if !errors.As(err, &illegal) || illegal.From != from || illegal.To != to || illegal.Reason != nil {
+				t.Errorf("Apply(%s → %s) error = %v, want IllegalTransitionError", from, to, err)
+			}
+			if o.OrderStatus != from {
+				t.Errorf("Apply(%s → %s) changed status to %s", from, to, o.OrderStatus)
+			}
+		}
+	}
+}
+
+func TestApplySetsTimestamps(t *testing.T) {
+	now := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	paidAt := util.CustomTime(now.Add(-time.Hour))
+	item := model.LineItem{ItemID: uuid.New(), Quantity: 1}
+
+	tests := []struct {
+		from  model.Status
+		to    model.Status
+		stamp func(o model.Order) *util.CustomTime
+	}{
+		{model.StatusPending, model.StatusConfirmed, func(o model.Order) *util.CustomTime { return o.ConfirmedAt }},
+		{model.StatusConfirmed, model.StatusPaid, func(o model.Order) *util.CustomTime { return o.PaidAt }},
+		{model.StatusPaid, model.StatusShipped, func(o model.Order) *util.CustomTime { return o.ShippedAt }},
+		{model.StatusShipped, model.StatusDelivered, func(o model.Order) *util.CustomTime { return o.DeliveredAt }},
+		{model.StatusDelivered, model.StatusCompleted, func(o model.Order) *util.CustomTime { return o.CompletedAt }},
+		{model.StatusPending, model.StatusCancelled, func(o model.Order) *util.CustomTime { return o.CancelledAt }},
+		{model.StatusCancelled, model.StatusRefunded, func(o model.Order) *util.CustomTime { return o.RefundedAt }},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s→%s", tt.from, tt.to), func(t *testing.T) {
+			o := model.Order{OrderStatus: tt.from, LineItems: []model.LineItem{item}, PaidAt: &paidAt}
+			if err := Apply(&o, tt.to, now); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			if o.OrderStatus != tt.to {
+				t.Fatalf("status = %s, want %s", o.OrderStatus, tt.to)
+			}
+			if ts := tt.stamp(o); ts == nil || !time.Time(*ts).Equal(now) {
+				t.Fatalf("timestamp = %v, want %v", ts, now)
+			}
+		})
+	}
+}
+
+func TestApplyGuards(t *testing.T) {
+	now := time.Now()
+
+	empty := model.Order{OrderStatus: model.StatusPaid}
+	if err := Apply(&empty, model.StatusShipped, now); !errors.Is(err, errNoLineItems) {
+		t.Fatalf("ship without line items error = %v, want errNoLineItems", err)
+	}
+
+	unpaid := model.Order{OrderStatus: model.StatusCancelled}
+	err := Apply(&unpaid, model.StatusRefunded, now)
+	var illegal *IllegalTransitionError
+	if !errors.As(err, &illegal) || !errors.Is(err, errNotPaid) {
+		t.Fatalf("refund unpaid order error = %v, want errNotPaid", err)
+	}
+	if fmt.Sprint(illegal.Allowed) != fmt.Sprint([]model.Status{model.StatusRefunded}) {
+		t.Fatalf("allowed = %v", illegal.Allowed)
+	}
+	if unpaid.OrderStatus != model.StatusCancelled || unpaid.RefundedAt != nil {
+		t.Fatalf("order changed after guard rejected: %+v", unpaid)
+	}
+}
+
+func TestCurrentInfersLegacyStatus(t *testing.T) {
+	ts := util.CustomTime(time.Now())
+	tests := []struct {
+		name  string
+		order model.Order
+		want  model.Status
+	}{
+		{"explicit status", model.Order{OrderStatus: model.StatusPaid, ShippedAt: &ts}, model.StatusPaid},
+		{"no timestamps", model.Order{}, model.StatusPending},
+		{"shipped", model.Order{ShippedAt: &ts}, model.StatusShipped},
+		{"completed", model.Order{ShippedAt: &ts, CompletedAt: &ts}, model.StatusCompleted},
+		{"different case", model.Order{OrderStatus: "Paid"}, model.StatusPaid},
+		{"synonym", model.Order{OrderStatus: " Processing "}, model.StatusConfirmed},
+		{"american spelling", model.Order{OrderStatus: "canceled"}, model.StatusCancelled},
+		{"separator", model.Order{OrderStatus: "In-Transit"}, model.StatusShipped},
+		{"timestamp wins over free-form status", model.Order{OrderStatus: "processing", ShippedAt: &ts}, model.StatusShipped},
+		{"unknown", model.Order{OrderStatus: "waiting for stock"}, model.StatusPending},
+	}
+	for _, tt := range tests {
+		if got := Current(tt.order); got != tt.want {
+			t.Errorf("%s: Current = %s, want %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+// Order cũ có order_status tự do vẫn chuyển trạng thái được và được ghi lại trạng thái chuẩn
+func TestApplyFromLegacyStatus(t *testing.T) {
+	o := model.Order{OrderStatus: "New"}
+	if err := Apply(&o, model.StatusConfirmed, time.Now()); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if o.OrderStatus != model.StatusConfirmed {
+		t.Fatalf("status = %q, want confirmed", o.OrderStatus)
+	}
+}
+
+func TestCanEditItems(t *testing.T) {
+	ts := util.CustomTime(time.Now())
+	tests := []struct {
+		name  string
+		order model.Order
+		ok    bool
+	}{
+		{"pending", model.Order{OrderStatus: model.StatusPending}, true},
+		{"confirmed with authorized payment", model.Order{OrderStatus: model.StatusConfirmed, Payments: []model.PaymentIntent{{Status: model.PaymentAuthorized}}}, true},
+		{"confirmed with captured payment", model.Order{OrderStatus: model.StatusConfirmed, Payments: []model.PaymentIntent{{Captured: 100}}}, false},
+		{"paid", model.Order{OrderStatus: model.StatusPaid}, false},
+		{"shipped", model.Order{OrderStatus: model.StatusShipped, ShippedAt: &ts}, false},
+		{"cancelled", model.Order{OrderStatus: model.StatusCancelled}, false},
+		{"refunded", model.Order{OrderStatus: model.StatusRefunded}, false},
+		{"legacy shipped", model.Order{ShippedAt: &ts}, false},
+	}
+	for _, tt := range tests {
+		err := CanEditItems(tt.order)
+		if tt.ok && err != nil {
+			t.Errorf("%s: CanEditItems = %v, want nil", tt.name, err)
+		}
+		if !tt.ok && !errors.Is(err, ErrItemsLocked) {
+			t.Errorf("%s: CanEditItems = %v, want ErrItemsLocked", tt.name, err)
+		}
+	}
+}
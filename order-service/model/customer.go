@@ -0,0 +1,41 @@
+package model
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+)
+
+// CustomerID là ID của khách hàng, chính là ID của user bên user-service
+// (Mongo ObjectID dạng 24 ký tự hex, ví dụ "64b7f3a2c9e1d2a3b4c5d6e7").
+// Order tạo trước đây có thể còn lưu customer_id dạng uuid, các order này
+// cần chạy lệnh cmd/migrate-customer-ids để chuyển sang ID của user-service.
+type CustomerID string
+
+// Dùng để báo lỗi khi customer_id không đúng định dạng ID của user-service
+var ErrInvalidCustomerID = errors.New("customer id must be a 24-character hex string")
+
+// Độ dài ObjectID của MongoDB khi viết dạng hex
+const customerIDLength = 24
+
+// ParseCustomerID kiểm tra và chuẩn hóa customer ID về chữ thường
+func ParseCustomerID(s string) (CustomerID, error) {
+	s = strings.ToLower(s)
+	if len(s) != customerIDLength {
+		return "", ErrInvalidCustomerID
+	}
+	if _, err := hex.DecodeString(s); err != nil {
+		return "", ErrInvalidCustomerID
+	}
+	return CustomerID(s), nil
+}
+
+// Valid trả về true nếu ID đã đúng định dạng chuẩn của user-service
+func (id CustomerID) Valid() bool {
+	parsed, err := ParseCustomerID(string(id))
+	return err == nil && parsed == id
+}
+
+func (id CustomerID) String() string {
+	return string(id)
+}
@@ -0,0 +1,102 @@
+package model
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Dùng để báo lỗi khi mã tiền tệ không đúng ISO 4217 hoặc chưa được hỗ trợ
+var ErrInvalidCurrency = errors.New("currency must be a supported ISO 4217 code")
+
+// Dùng để báo lỗi khi line item có tiền tệ khác với tiền tệ của order
+var ErrCurrencyMismatch = errors.New("all line items must use the order currency")
+
+// Số chữ số thập phân của đơn vị nhỏ nhất (minor unit) theo ISO 4217,
+// ví dụ 1 USD = 100 cent, VND không có đơn vị nhỏ hơn đồng
+var currencyDigits = map[string]int{
+	"VND": 0,
+	"USD": 2,
+	"EUR": 2,
+	"GBP": 2,
+	"JPY": 0,
+	"KRW": 0,
+	"CNY": 2,
+	"SGD": 2,
+	"THB": 2,
+	"AUD": 2,
+}
+
+// ParseCurrency kiểm tra và chuẩn hóa mã tiền tệ về chữ hoa
+func ParseCurrency(s string) (string, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	if _, ok := currencyDigits[code]; !ok {
+		return "", ErrInvalidCurrency
+	}
+	return code, nil
+}
+
+// CurrencyDigits trả về số chữ số thập phân của minor unit, false nếu không hỗ trợ
+func CurrencyDigits(code string) (int, bool) {
+	digits, ok := currencyDigits[code]
+	return digits, ok
+}
+
+/*
+Money là một số tiền theo đơn vị nhỏ nhất của tiền tệ (minor units) kèm mã ISO 4217:
+
+	{"amount": 1999, "currency": "USD"} // 19.99 USD
+
+Mã tiền tệ được chuyển về chữ hoa khi giải mã. Dữ liệu cũ lưu giá dạng số trần
+(ví dụ "price": 500) vẫn giải mã được, khi đó Currency rỗng và được lấy theo tiền tệ của order.
+*/
+type Money struct {
+	Amount   uint64 `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+func (m *Money) UnmarshalJSON(data []byte) error {
+	// Số trần của dữ liệu cũ chưa có tiền tệ
+	if len(data) > 0 && data[0] != '{' && !bytes.Equal(data, []byte("null")) {
+		m.Currency = ""
+		return json.Unmarshal(data, &m.Amount)
+	}
+
+	type money Money // bỏ method UnmarshalJSON để tránh đệ quy
+	if err := json.Unmarshal(data, (*money)(m)); err != nil {
+		return err
+	}
+	m.Currency = strings.ToUpper(strings.TrimSpace(m.Currency))
+	return nil
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%d %s", m.Amount, m.Currency)
+}
+
+// SetCurrency gán tiền tệ cho order và cho các line item chưa có tiền tệ
+// (line item gửi lên không kèm currency hoặc dữ liệu cũ)
+func (o *Order) SetCurrency(code string) {
+	o.Currency = code
+	for i := range o.LineItems {
+		if o.LineItems[i].Price.Currency == "" {
+			o.LineItems[i].Price.Currency = code
+		}
+	}
+}
+
+// CheckCurrency kiểm tra order có tiền tệ hợp lệ và mọi line item cùng tiền tệ đó
+func (o Order) CheckCurrency() error {
+	if _, ok := currencyDigits[o.Currency]; !ok {
+		return ErrInvalidCurrency
+	}
+	for _, item := range o.LineItems {
+		if item.Price.Currency != o.Currency {
+			return fmt.Errorf("%w: item %s is priced in %q, order is in %s",
+				ErrCurrencyMismatch, item.ItemID, item.Price.Currency, o.Currency)
+		}
+	}
+	return nil
+}
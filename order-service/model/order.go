@@ -9,16 +9,44 @@ import (
 
 type Order struct {
 	OrderID     uint64           `json:"order_id"`
-	CustomerID  uuid.UUID        `json:"customer_id"`
+	CustomerID  CustomerID       `json:"customer_id"`
 	LineItems   []LineItem       `json:"Line_items"`
-	OrderStatus string           `json:"order_status"`
+	Currency    string           `json:"currency"`             // mã ISO 4217, mọi số tiền của order đều theo tiền tệ này
+	Region      string           `json:"region,omitempty"`     // khu vực giao hàng, dùng để tính thuế
+	Subtotal    Money            `json:"subtotal"`             // tổng tiền hàng
+	Tax         Money            `json:"tax"`                  // tổng thuế
+	Shipping    Money            `json:"shipping"`             // phí vận chuyển
+	GrandTotal  Money            `json:"grand_total"`          // Subtotal + Tax + Shipping
+	BaseTotal   *Money           `json:"base_total,omitempty"` // GrandTotal quy đổi sang tiền tệ gốc để báo cáo
+	Payments    []PaymentIntent  `json:"payments,omitempty"`   // các lần thanh toán, lần mới nhất ở cuối
+	OrderStatus Status           `json:"order_status"`
+	Version     uint64           `json:"version"` // tăng 1 sau mỗi lần cập nhật, dùng làm ETag
 	CreateAt    *time.Time       `json:"created_at"`
+	ConfirmedAt *util.CustomTime `json:"confirmed_at,omitempty"`
+	PaidAt      *util.CustomTime `json:"paid_at,omitempty"`
 	ShippedAt   *util.CustomTime `json:"shipped_at,omitempty"`
+	DeliveredAt *util.CustomTime `json:"delivered_at,omitempty"`
 	CompletedAt *util.CustomTime `json:"completed_at,omitempty"`
+	CancelledAt *util.CustomTime `json:"cancelled_at,omitempty"`
+	RefundedAt  *util.CustomTime `json:"refunded_at,omitempty"`
+	DeletedAt   *util.CustomTime `json:"deleted_at,omitempty"` // khác nil nếu order đã bị xóa (lưu trữ)
+	DeletedBy   string           `json:"deleted_by,omitempty"` // user đã xóa order
 }
 
 type LineItem struct {
 	ItemID   uuid.UUID `json:"item_id"`
+	SKU      string    `json:"sku,omitempty"`  // mã hàng lấy từ catalog-service lúc đặt
+	Name     string    `json:"name,omitempty"` // tên mặt hàng lấy từ catalog-service lúc đặt
 	Quantity uint      `json:"quantity"`
-	Price    uint      `json:"price"`
+	Price    Money     `json:"price"`
+}
+
+// FindLineItem trả về vị trí của line item có ItemID là id, -1 nếu không có
+func (o Order) FindLineItem(id uuid.UUID) int {
+	for i, item := range o.LineItems {
+		if item.ItemID == id {
+			return i
+		}
+	}
+	return -1
 }
@@ -0,0 +1,57 @@
+package model
+
+import "time"
+
+// PaymentStatus là trạng thái của một lần thanh toán qua cổng thanh toán
+type PaymentStatus string
+
+const (
+	PaymentAuthorized        PaymentStatus = "authorized"         // đã giữ tiền, chưa thu
+	PaymentCaptured          PaymentStatus = "captured"           // đã thu tiền
+	PaymentPartiallyRefunded PaymentStatus = "partially_refunded" // đã hoàn một phần số tiền đã thu
+	PaymentRefunded          PaymentStatus = "refunded"           // đã hoàn toàn bộ số tiền đã thu
+	PaymentVoided            PaymentStatus = "voided"             // đã hủy giữ tiền, không thu
+	PaymentDeclined          PaymentStatus = "declined"           // cổng thanh toán từ chối
+	PaymentFailed            PaymentStatus = "failed"             // không gọi được cổng thanh toán
+)
+
+// PaymentIntent là một lần thanh toán của order, order giữ lại mọi lần thanh toán kể cả bị từ chối
+type PaymentIntent struct {
+	IntentID  string        `json:"intent_id,omitempty"` // mã thanh toán do cổng thanh toán cấp, rỗng nếu bị từ chối
+	Provider  string        `json:"provider"`            // tên cổng thanh toán
+	Amount    Money         `json:"amount"`              // số tiền đã giữ
+	Captured  uint64        `json:"captured"`            // số tiền đã thu (minor units)
+	Refunded  uint64        `json:"refunded"`            // số tiền đã hoàn (minor units)
+	Status    PaymentStatus `json:"status"`
+	Error     string        `json:"error,omitempty"` // lý do bị từ chối hoặc lỗi
+	CreatedAt time.Time     `json:"created_at"`
+	UpdatedAt time.Time     `json:"updated_at"`
+}
+
+// Refundable trả về số tiền đã thu mà chưa hoàn
+func (p PaymentIntent) Refundable() uint64 {
+	return p.Captured - p.Refunded
+}
+
+// FindPayment trả về vị trí lần thanh toán gần nhất có trạng thái nằm trong statuses, -1 nếu không có
+func (o Order) FindPayment(statuses ...PaymentStatus) int {
+	for i := len(o.Payments) - 1; i >= 0; i-- {
+		for _, status := range statuses {
+			if o.Payments[i].Status == status {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// SetPayment thay lần thanh toán có cùng IntentID, chưa có thì thêm vào cuối
+func (o *Order) SetPayment(p PaymentIntent) {
+	for i := range o.Payments {
+		if p.IntentID != "" && o.Payments[i].IntentID == p.IntentID {
+			o.Payments[i] = p
+			return
+		}
+	}
+	o.Payments = append(o.Payments, p)
+}
@@ -0,0 +1,25 @@
+package model
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+// Dùng để báo lỗi khi mã khu vực giao hàng không đúng định dạng
+var ErrInvalidRegion = errors.New("region must be up to 32 letters, digits, '-' or '_', starting with a letter or digit")
+
+// Mã khu vực (sau khi chuyển chữ hoa), ví dụ "VN", "VN-HN", "US_CA"
+var regionPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{0,31}$`)
+
+// ParseRegion kiểm tra và chuẩn hóa mã khu vực về chữ hoa, chuỗi rỗng là đơn không ghi khu vực
+func ParseRegion(s string) (string, error) {
+	region := strings.ToUpper(strings.TrimSpace(s))
+	if region == "" {
+		return "", nil
+	}
+	if !regionPattern.MatchString(region) {
+		return "", ErrInvalidRegion
+	}
+	return region, nil
+}
@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// Status là trạng thái của đơn hàng trong vòng đời xử lý
+type Status string
+
+const (
+	StatusPending   Status = "pending"   // vừa tạo, chờ xác nhận
+	StatusConfirmed Status = "confirmed" // shop đã xác nhận đơn
+	StatusPaid      Status = "paid"      // khách đã thanh toán
+	StatusShipped   Status = "shipped"   // đã giao cho đơn vị vận chuyển
+	StatusDelivered Status = "delivered" // khách đã nhận hàng
+	StatusCompleted Status = "completed" // đơn hàng hoàn tất
+	StatusCancelled Status = "cancelled" // đơn bị hủy trước khi giao
+	StatusRefunded  Status = "refunded"  // đã hoàn tiền cho khách
+)
+
+// Statuses liệt kê tất cả trạng thái hợp lệ
+var Statuses = []Status{
+	StatusPending,
+	StatusConfirmed,
+	StatusPaid,
+	StatusShipped,
+	StatusDelivered,
+	StatusCompleted,
+	StatusCancelled,
+	StatusRefunded,
+}
+
+// Valid kiểm tra trạng thái có nằm trong danh sách hợp lệ hay không
+func (s Status) Valid() bool {
+	for _, status := range Statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+// StatusChange là một bản ghi trong lịch sử thay đổi trạng thái của đơn hàng.
+// Lịch sử chỉ được ghi thêm, không sửa hay xóa bản ghi cũ.
+type StatusChange struct {
+	OrderID   uint64    `json:"order_id"`
+	From      Status    `json:"from"`
+	To        Status    `json:"to"`
+	Actor     string    `json:"actor"`            // ai thực hiện thay đổi
+	Reason    string    `json:"reason,omitempty"` // lý do thay đổi (nếu có)
+	ChangedAt time.Time `json:"changed_at"`
+}
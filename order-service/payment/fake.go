@@ -0,0 +1,178 @@
+package payment
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+// Cách cổng thanh toán giả phản hồi, chọn qua biến môi trường PAYMENT_FAKE_BEHAVIOR
+type Behavior string
+
+const (
+	BehaviorApprove Behavior = "approve" // chấp nhận mọi giao dịch
+	BehaviorDecline Behavior = "decline" // từ chối mọi lần giữ tiền
+	BehaviorTimeout Behavior = "timeout" // mọi lần gọi đều treo rồi trả về ErrUnavailable
+)
+
+// Thời gian treo mặc định của cổng thanh toán giả khi giả lập timeout
+const DefaultFakeDelay = 2 * time.Second
+
+// Tên cổng thanh toán giả ghi vào order
+const fakeName = "fake"
+
+// Giao dịch do cổng thanh toán giả giữ trong bộ nhớ
+type fakeIntent struct {
+	orderID  uint64
+	amount   model.Money
+	captured uint64
+	refunded uint64
+	voided   bool
+}
+
+/*
+Fake là cổng thanh toán giả chạy trong bộ nhớ, dùng khi phát triển và kiểm thử.
+Kết quả chỉ phụ thuộc vào Behavior và các lần gọi trước nên luôn lặp lại được:
+mã giao dịch có dạng "pi_fake_<order_id>_<lần giữ tiền>".
+Dữ liệu mất khi service khởi động lại và không chia sẻ giữa các instance.
+*/
+type Fake struct {
+	Behavior Behavior
+	Delay    time.Duration // thời gian treo khi Behavior là timeout, 0 thì dùng DefaultFakeDelay
+
+	mu       sync.Mutex
+	intents  map[string]*fakeIntent
+	attempts map[uint64]int // số lần giữ tiền của từng order
+}
+
+func NewFake(behavior Behavior) (*Fake, error) {
+	switch behavior {
+	case BehaviorApprove, BehaviorDecline, BehaviorTimeout:
+	default:
+		return nil, fmt.Errorf("unknown fake payment behavior: %q", behavior)
+	}
+	return &Fake{
+		Behavior: behavior,
+		intents:  map[string]*fakeIntent{},
+		attempts: map[uint64]int{},
+	}, nil
+}
+
+func (f *Fake) Name() string {
+	return fakeName
+}
+
+// wait giả lập cổng thanh toán không phản hồi
+func (f *Fake) wait(ctx context.Context) error {
+	delay := f.Delay
+	if delay <= 0 {
+		delay = DefaultFakeDelay
+	}
+	select {
+	case <-ctx.Done():
+		return fmt.Errorf("%w: %v", ErrUnavailable, ctx.Err())
+	case <-time.After(delay):
+		return fmt.Errorf("%w: timed out after %s", ErrUnavailable, delay)
+	}
+}
+
+func (f *Fake) Authorize(ctx context.Context, orderID uint64, amount model.Money) (string, error) {
+	if f.Behavior == BehaviorTimeout {
+		return "", f.wait(ctx)
+	}
+	if f.Behavior == BehaviorDecline {
+		return "", fmt.Errorf("%w: card declined by fake provider", ErrDeclined)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	// Giao dịch gần nhất của order còn hiệu lực và cùng số tiền thì dùng lại
+	if n := f.attempts[orderID]; n > 0 {
+		id := fakeIntentID(orderID, n)
+		if intent := f.intents[id]; !intent.voided && intent.amount == amount {
+			return id, nil
+		}
+	}
+
+	f.attempts[orderID]++
+	id := fakeIntentID(orderID, f.attempts[orderID])
+	f.intents[id] = &fakeIntent{orderID: orderID, amount: amount}
+	return id, nil
+}
+
+func fakeIntentID(orderID uint64, attempt int) string {
+	return fmt.Sprintf("pi_fake_%d_%d", orderID, attempt)
+}
+
+func (f *Fake) Capture(ctx context.Context, intentID string, amount model.Money) error {
+	if f.Behavior == BehaviorTimeout {
+		return f.wait(ctx)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return ErrUnknownIntent
+	}
+	if intent.voided || amount.Currency != intent.amount.Currency {
+		return ErrInvalidState
+	}
+	if intent.captured > 0 {
+		if intent.captured == amount.Amount {
+			return nil // đã thu ở lần gọi trước
+		}
+		return ErrInvalidState
+	}
+	if amount.Amount > intent.amount.Amount {
+		return ErrAmountExceeded
+	}
+	intent.captured = amount.Amount
+	return nil
+}
+
+func (f *Fake) Refund(ctx context.Context, intentID string, amount model.Money) error {
+	if f.Behavior == BehaviorTimeout {
+		return f.wait(ctx)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return ErrUnknownIntent
+	}
+	if intent.captured == 0 || amount.Currency != intent.amount.Currency {
+		return ErrInvalidState
+	}
+	if amount.Amount > intent.captured-intent.refunded {
+		return ErrAmountExceeded
+	}
+	intent.refunded += amount.Amount
+	return nil
+}
+
+func (f *Fake) Void(ctx context.Context, intentID string) error {
+	if f.Behavior == BehaviorTimeout {
+		return f.wait(ctx)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	intent, ok := f.intents[intentID]
+	if !ok {
+		return ErrUnknownIntent
+	}
+	if intent.captured > 0 {
+		return ErrInvalidState
+	}
+	intent.voided = true
+	return nil
+}
@@ -0,0 +1,147 @@
+package payment
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+func vnd(amount uint64) model.Money { return model.Money{Amount: amount, Currency: "VND"} }
+
+func usd(amount uint64) model.Money { return model.Money{Amount: amount, Currency: "USD"} }
+
+func newTestFake(t *testing.T) *Fake {
+	t.Helper()
+	f, err := NewFake(BehaviorApprove)
+	if err != nil {
+		t.Fatalf("NewFake: %v", err)
+	}
+	return f
+}
+
+func TestNewFakeRejectsUnknownBehavior(t *testing.T) {
+	if _, err := NewFake("flaky"); err == nil {
+		t.Fatalf("NewFake with unknown behavior succeeded")
+	}
+}
+
+func TestFakeAuthorizeReusesIntent(t *testing.T) {
+	ctx := context.Background()
+	f := newTestFake(t)
+
+	first, err := f.Authorize(ctx, 1, vnd(1000))
+	if err != nil || first != "pi_fake_1_1" {
+		t.Fatalf("Authorize = %q, %v", first, err)
+	}
+	// Cùng số tiền thì dùng lại giao dịch cũ
+	if again, _ := f.Authorize(ctx, 1, vnd(1000)); again != first {
+		t.Fatalf("Authorize same amount = %q, want %q", again, first)
+	}
+	// Đổi số tiền hoặc giao dịch cũ đã hủy thì giữ tiền mới
+	changed, _ := f.Authorize(ctx, 1, vnd(2000))
+	if changed != "pi_fake_1_2" {
+		t.Fatalf("Authorize new amount = %q", changed)
+	}
+	if err := f.Void(ctx, changed); err != nil {
+		t.Fatalf("Void: %v", err)
+	}
+	if after, _ := f.Authorize(ctx, 1, vnd(2000)); after != "pi_fake_1_3" {
+		t.Fatalf("Authorize after void = %q", after)
+	}
+	if other, _ := f.Authorize(ctx, 2, vnd(1000)); other != "pi_fake_2_1" {
+		t.Fatalf("Authorize other order = %q", other)
+	}
+}
+
+func TestFakeOperations(t *testing.T) {
+	tests := []struct {
+		name string
+		run  func(ctx context.Context, f *Fake, id string) error
+		want error
+	}{
+		{"capture", func(ctx context.Context, f *Fake, id string) error {
+			return f.Capture(ctx, id, vnd(1000))
+		}, nil},
+		{"capture twice is idempotent", func(ctx context.Context, f *Fake, id string) error {
+			f.Capture(ctx, id, vnd(1000))
+			return f.Capture(ctx, id, vnd(1000))
+		}, nil},
+		{"capture a different amount after capture", func(ctx context.Context, f *Fake, id string) error {
+			f.Capture(ctx, id, vnd(1000))
+			return f.Capture(ctx, id, vnd(500))
+		}, ErrInvalidState},
+		{"capture more than authorized", func(ctx context.Context, f *Fake, id string) error {
+			return f.Capture(ctx, id, vnd(1001))
+		}, ErrAmountExceeded},
+		{"capture in another currency", func(ctx context.Context, f *Fake, id string) error {
+			return f.Capture(ctx, id, usd(1000))
+		}, ErrInvalidState},
+		{"capture voided intent", func(ctx context.Context, f *Fake, id string) error {
+			f.Void(ctx, id)
+			return f.Capture(ctx, id, vnd(1000))
+		}, ErrInvalidState},
+		{"void captured intent", func(ctx context.Context, f *Fake, id string) error {
+			f.Capture(ctx, id, vnd(1000))
+			return f.Void(ctx, id)
+		}, ErrInvalidState},
+		{"refund before capture", func(ctx context.Context, f *Fake, id string) error {
+			return f.Refund(ctx, id, vnd(100))
+		}, ErrInvalidState},
+		{"partial refunds up to the captured amount", func(ctx context.Context, f *Fake, id string) error {
+			f.Capture(ctx, id, vnd(1000))
+			if err := f.Refund(ctx, id, vnd(400)); err != nil {
+				return err
+			}
+			return f.Refund(ctx, id, vnd(600))
+		}, nil},
+		{"refund more than remaining", func(ctx context.Context, f *Fake, id string) error {
+			f.Capture(ctx, id, vnd(1000))
+			f.Refund(ctx, id, vnd(400))
+			return f.Refund(ctx, id, vnd(601))
+		}, ErrAmountExceeded},
+		{"unknown intent", func(ctx context.Context, f *Fake, id string) error {
+			return f.Void(ctx, "pi_fake_9_9")
+		}, ErrUnknownIntent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			f := newTestFake(t)
+			id, err := f.Authorize(ctx, 1, vnd(1000))
+			if err != nil {
+				t.Fatalf("Authorize: %v", err)
+			}
+			if err := tt.run(ctx, f, id); !errors.Is(err, tt.want) {
+				t.Fatalf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestFakeBehaviors(t *testing.T) {
+	ctx := context.Background()
+
+	decline, _ := NewFake(BehaviorDecline)
+	_, err := decline.Authorize(ctx, 1, vnd(1000))
+	if !errors.Is(err, ErrDeclined) || StatusOf(err) != model.PaymentDeclined {
+		t.Fatalf("declined Authorize error = %v", err)
+	}
+
+	timeout, _ := NewFake(BehaviorTimeout)
+	timeout.Delay = time.Millisecond
+	_, err = timeout.Authorize(ctx, 1, vnd(1000))
+	if !errors.Is(err, ErrUnavailable) || StatusOf(err) != model.PaymentFailed {
+		t.Fatalf("timed out Authorize error = %v", err)
+	}
+
+	// Hết hạn context thì trả về ngay
+	timeout.Delay = time.Hour
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if err := timeout.Capture(cancelled, "pi_fake_1_1", vnd(1000)); !errors.Is(err, ErrUnavailable) {
+		t.Fatalf("Capture with cancelled context error = %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package payment
+
+import (
+	"context"
+	"errors"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+var (
+	// Dùng để báo lỗi khi cổng thanh toán từ chối giao dịch (thẻ hết tiền, bị khóa, v.v...)
+	ErrDeclined = errors.New("payment declined")
+
+	// Dùng để báo lỗi khi không gọi được cổng thanh toán (timeout, mất kết nối, v.v...)
+	ErrUnavailable = errors.New("payment provider unavailable")
+
+	// Dùng để báo lỗi khi thao tác không hợp lệ với trạng thái hiện tại của giao dịch
+	// (thu tiền của giao dịch đã hủy, hoàn tiền khi chưa thu, v.v...)
+	ErrInvalidState = errors.New("payment is not in a valid state for this operation")
+
+	// Dùng để báo lỗi khi số tiền thu hoặc hoàn lớn hơn số tiền còn lại của giao dịch
+	ErrAmountExceeded = errors.New("amount exceeds the remaining payment amount")
+
+	// Dùng để báo lỗi khi cổng thanh toán không có giao dịch với mã này
+	ErrUnknownIntent = errors.New("payment intent does not exist")
+)
+
+/*
+Provider là cổng thanh toán:
+  - Authorize giữ amount của khách cho order, trả về mã giao dịch.
+    Gọi lại với cùng order và số tiền khi giao dịch cũ còn hiệu lực thì trả về mã cũ.
+  - Capture thu amount từ giao dịch đã giữ tiền
+  - Refund hoàn amount từ số tiền đã thu
+  - Void hủy giữ tiền của giao dịch chưa thu
+
+Capture và Void gọi lại sau khi đã thành công thì không bị tính hai lần.
+*/
+type Provider interface {
+	Name() string
+	Authorize(ctx context.Context, orderID uint64, amount model.Money) (intentID string, err error)
+	Capture(ctx context.Context, intentID string, amount model.Money) error
+	Refund(ctx context.Context, intentID string, amount model.Money) error
+	Void(ctx context.Context, intentID string) error
+}
+
+// StatusOf trả về trạng thái cần ghi vào order khi một lần gọi cổng thanh toán bị lỗi
+func StatusOf(err error) model.PaymentStatus {
+	if errors.Is(err, ErrDeclined) {
+		return model.PaymentDeclined
+	}
+	return model.PaymentFailed
+}
@@ -0,0 +1,52 @@
+package pricing
+
+import (
+	"errors"
+	"math/bits"
+)
+
+// Dùng để báo lỗi khi số tiền vượt quá giới hạn của uint64
+var ErrOverflow = errors.New("amount overflows")
+
+// Mọi số tiền đều là số nguyên theo đơn vị nhỏ nhất của tiền tệ (minor units,
+// ví dụ: đồng với VND, cent với USD) nên không có sai số của số thực.
+// Các hàm dưới đây trả về ErrOverflow thay vì để phép tính tràn số âm thầm.
+
+// Add trả về a + b
+func Add(a, b uint64) (uint64, error) {
+	sum, carry := bits.Add64(a, b, 0)
+	if carry != 0 {
+		return 0, ErrOverflow
+	}
+	return sum, nil
+}
+
+// Mul trả về a * b
+func Mul(a, b uint64) (uint64, error) {
+	hi, lo := bits.Mul64(a, b)
+	if hi != 0 {
+		return 0, ErrOverflow
+	}
+	return lo, nil
+}
+
+// MulDivRound trả về a * b / d làm tròn nửa lên, tích a * b được giữ ở 128 bit
+// nên không bị tràn ở bước trung gian
+func MulDivRound(a, b, d uint64) (uint64, error) {
+	if d == 0 {
+		return 0, errors.New("division by zero")
+	}
+	hi, lo := bits.Mul64(a, b)
+
+	// Cộng d/2 để làm tròn nửa lên
+	var carry uint64
+	lo, carry = bits.Add64(lo, d/2, 0)
+	hi += carry
+
+	// Thương phải vừa 64 bit
+	if hi >= d {
+		return 0, ErrOverflow
+	}
+	q, _ := bits.Div64(hi, lo, d)
+	return q, nil
+}
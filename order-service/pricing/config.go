@@ -0,0 +1,116 @@
+package pricing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/google/uuid"
+)
+
+/*
+ParseAmounts đọc số tiền theo tiền tệ dạng "CURRENCY=AMOUNT,...", ví dụ:
+
+	VND=30000,USD=500
+
+Một số trần (ví dụ "30000") là số tiền theo defaultCurrency.
+*/
+func ParseAmounts(s, defaultCurrency string) (Amounts, error) {
+	amounts := Amounts{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		code, value, ok := strings.Cut(part, "=")
+		if !ok {
+			code, value = defaultCurrency, part
+		}
+		currency, err := model.ParseCurrency(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount %q: %w", part, err)
+		}
+		amount, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount %q: %w", part, err)
+		}
+		amounts[currency] = amount
+	}
+	return amounts, nil
+}
+
+/*
+ParseFlatRates đọc danh sách thuế suất dạng "REGION=PERCENT,...", ví dụ:
+
+	VN=10,US-CA=7.25,*=0
+
+"*" là thuế suất mặc định cho các khu vực còn lại và luôn được xếp cuối.
+Phần trăm có tối đa 2 chữ số thập phân (được lưu dưới dạng basis point).
+*/
+func ParseFlatRates(s string) ([]Rule, error) {
+	var (
+		rules    []Rule
+		fallback *FlatRate
+	)
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		region, percent, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid tax rate %q, want REGION=PERCENT", part)
+		}
+		bp, err := parsePercent(strings.TrimSpace(percent))
+		if err != nil {
+			return nil, fmt.Errorf("invalid tax rate %q: %w", part, err)
+		}
+
+		region = strings.ToUpper(strings.TrimSpace(region))
+		if region == "*" {
+			fallback = &FlatRate{BasisPoints: bp}
+			continue
+		}
+		rules = append(rules, FlatRate{Region: region, BasisPoints: bp})
+	}
+	if fallback != nil {
+		rules = append(rules, *fallback)
+	}
+	return rules, nil
+}
+
+// parsePercent chuyển "7.25" thành 725 basis point
+func parsePercent(s string) (uint64, error) {
+	whole, frac, _ := strings.Cut(s, ".")
+	if len(frac) > 2 {
+		return 0, fmt.Errorf("at most 2 decimal places")
+	}
+	frac += strings.Repeat("0", 2-len(frac))
+
+	bp, err := strconv.ParseUint(whole+frac, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	if bp > basisPointsPerUnit {
+		return 0, fmt.Errorf("rate above 100%%")
+	}
+	return bp, nil
+}
+
+// ParseExemptItems đọc danh sách item_id được miễn thuế, cách nhau bởi dấu phẩy
+func ParseExemptItems(s string) (ExemptItems, error) {
+	items := ExemptItems{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := uuid.Parse(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid tax-exempt item %q: %w", part, err)
+		}
+		items[id] = true
+	}
+	return items, nil
+}
@@ -0,0 +1,97 @@
+package pricing
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+// Dùng để báo lỗi khi bảng tỷ giá không có tỷ giá của tiền tệ cần quy đổi
+var ErrNoRate = errors.New("no exchange rate for currency")
+
+/*
+ExchangeRates là bảng tỷ giá dùng để báo cáo tổng tiền theo một tiền tệ gốc (Base).
+Bảng được đọc từ file JSON, tỷ giá là số đơn vị Base của 1 đơn vị tiền tệ (không phải minor unit):
+
+	{
+	  "base": "VND",
+	  "rates": {"USD": "25400", "EUR": "27350.5"}
+	}
+
+Tỷ giá được giữ dạng số hữu tỉ (big.Rat) nên quy đổi không có sai số của số thực.
+*/
+type ExchangeRates struct {
+	Base  string
+	rates map[string]*big.Rat
+}
+
+// LoadExchangeRates đọc bảng tỷ giá từ file JSON ở path
+func LoadExchangeRates(path string) (*ExchangeRates, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read exchange rates: %w", err)
+	}
+
+	var file struct {
+		Base  string                 `json:"base"`
+		Rates map[string]json.Number `json:"rates"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to decode exchange rates: %w", err)
+	}
+
+	base, err := model.ParseCurrency(file.Base)
+	if err != nil {
+		return nil, fmt.Errorf("invalid base currency %q: %w", file.Base, err)
+	}
+
+	x := &ExchangeRates{Base: base, rates: make(map[string]*big.Rat, len(file.Rates))}
+	for code, value := range file.Rates {
+		currency, err := model.ParseCurrency(code)
+		if err != nil {
+			return nil, fmt.Errorf("invalid currency %q: %w", code, err)
+		}
+		rate, ok := new(big.Rat).SetString(value.String())
+		if !ok || rate.Sign() <= 0 {
+			return nil, fmt.Errorf("invalid exchange rate for %s: %q", currency, value)
+		}
+		x.rates[currency] = rate
+	}
+	return x, nil
+}
+
+// Convert quy đổi m sang tiền tệ gốc, làm tròn nửa lên theo minor unit của Base
+func (x *ExchangeRates) Convert(m model.Money) (model.Money, error) {
+	if m.Currency == x.Base {
+		return m, nil
+	}
+	rate, ok := x.rates[m.Currency]
+	if !ok {
+		return model.Money{}, fmt.Errorf("%w %s", ErrNoRate, m.Currency)
+	}
+	fromDigits, _ := model.CurrencyDigits(m.Currency)
+	toDigits, _ := model.CurrencyDigits(x.Base)
+
+	// amount (minor unit) / 10^fromDigits * rate * 10^toDigits
+	value := new(big.Rat).SetInt(new(big.Int).SetUint64(m.Amount))
+	value.Mul(value, rate)
+	value.Mul(value, new(big.Rat).SetInt(pow10(toDigits)))
+	value.Quo(value, new(big.Rat).SetInt(pow10(fromDigits)))
+
+	// Làm tròn nửa lên: (num * 2 + den) / (den * 2)
+	num := new(big.Int).Mul(value.Num(), big.NewInt(2))
+	num.Add(num, value.Denom())
+	amount := num.Quo(num, new(big.Int).Mul(value.Denom(), big.NewInt(2)))
+	if !amount.IsUint64() {
+		return model.Money{}, ErrOverflow
+	}
+	return model.Money{Amount: amount.Uint64(), Currency: x.Base}, nil
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
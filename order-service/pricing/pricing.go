@@ -0,0 +1,90 @@
+package pricing
+
+import (
+	"errors"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+// Amounts là các số tiền (minor units) theo từng mã tiền tệ
+type Amounts map[string]uint64
+
+// Calculator tính tiền hàng, thuế, phí vận chuyển và tổng tiền của đơn hàng.
+// Giá trị zero dùng được: không có thuế và miễn phí vận chuyển.
+type Calculator struct {
+	Tax              TaxEngine
+	ShippingFees     Amounts        // phí vận chuyển cố định cho mỗi đơn có hàng, tiền tệ không có trong bảng được miễn phí
+	FreeShippingFrom Amounts        // tiền hàng từ mức này trở lên được miễn phí vận chuyển, không có hoặc 0 là không miễn
+	Rates            *ExchangeRates // bảng tỷ giá để tính BaseTotal, nil nếu không dùng
+}
+
+/*
+Apply tính lại các trường tiền của order từ LineItems:
+  - Subtotal   = tổng Price * Quantity của các line item
+  - Tax        = tổng thuế của từng line item theo TaxEngine (làm tròn theo từng item)
+  - Shipping   = ShippingFee, miễn phí nếu đơn trống hoặc Subtotal đạt FreeShippingFrom
+  - GrandTotal = Subtotal + Tax + Shipping
+  - BaseTotal  = GrandTotal quy đổi theo Rates, nil nếu không có tỷ giá
+
+Mọi line item phải cùng tiền tệ với order (model.ErrCurrencyMismatch).
+Nếu có phép tính bị tràn số thì order giữ nguyên và trả về ErrOverflow.
+*/
+func (c Calculator) Apply(o *model.Order) error {
+	if err := o.CheckCurrency(); err != nil {
+		return err
+	}
+
+	var subtotal, tax uint64
+	for _, item := range o.LineItems {
+		amount, err := Mul(item.Price.Amount, uint64(item.Quantity))
+		if err != nil {
+			return err
+		}
+		if subtotal, err = Add(subtotal, amount); err != nil {
+			return err
+		}
+
+		itemTax, err := c.Tax.ItemTax(o.Region, item, amount)
+		if err != nil {
+			return err
+		}
+		if tax, err = Add(tax, itemTax); err != nil {
+			return err
+		}
+	}
+
+	var shipping uint64
+	if len(o.LineItems) > 0 {
+		// Mức 0 (FREE_SHIPPING_FROM=0) nghĩa là không miễn phí, giống như không cấu hình
+		threshold := c.FreeShippingFrom[o.Currency]
+		if threshold == 0 || subtotal < threshold {
+			shipping = c.ShippingFees[o.Currency]
+		}
+	}
+
+	total, err := Add(subtotal, tax)
+	if err != nil {
+		return err
+	}
+	if total, err = Add(total, shipping); err != nil {
+		return err
+	}
+
+	grandTotal := model.Money{Amount: total, Currency: o.Currency}
+	var baseTotal *model.Money
+	if c.Rates != nil {
+		converted, err := c.Rates.Convert(grandTotal)
+		if err != nil && !errors.Is(err, ErrNoRate) {
+			return err
+		} else if err == nil {
+			baseTotal = &converted
+		}
+	}
+
+	o.Subtotal = model.Money{Amount: subtotal, Currency: o.Currency}
+	o.Tax = model.Money{Amount: tax, Currency: o.Currency}
+	o.Shipping = model.Money{Amount: shipping, Currency: o.Currency}
+	o.GrandTotal = grandTotal
+	o.BaseTotal = baseTotal
+	return nil
+}
@@ -0,0 +1,146 @@
+package pricing
+
+import (
+	"errors"
+	"math"
+	"testing"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/google/uuid"
+)
+
+func TestAddMul(t *testing.T) {
+	if got, err := Add(math.MaxUint64-1, 1); err != nil || got != math.MaxUint64 {
+		t.Fatalf("Add(max-1, 1) = %d, %v", got, err)
+	}
+	if _, err := Add(math.MaxUint64, 1); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Add(max, 1) error = %v, want ErrOverflow", err)
+	}
+	if got, err := Mul(1<<32, 1<<31); err != nil || got != 1<<63 {
+		t.Fatalf("Mul(2^32, 2^31) = %d, %v", got, err)
+	}
+	if _, err := Mul(1<<32, 1<<32); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("Mul(2^32, 2^32) error = %v, want ErrOverflow", err)
+	}
+}
+
+func TestMulDivRound(t *testing.T) {
+	tests := []struct {
+		name    string
+		a, b, d uint64
+		want    uint64
+		err     bool
+	}{
+		{"exact", 20000, 1000, 10000, 2000, false},
+		{"round half up", 5, 1000, 10000, 1, false},         // 0.5 → 1
+		{"round down below half", 4, 1000, 10000, 0, false}, // 0.4 → 0
+		{"7.25% of 999", 999, 725, 10000, 72, false},        // 72.4275 → 72
+		{"7.25% of 1000", 1000, 725, 10000, 73, false},      // 72.5 → 73
+		{"zero", 0, 725, 10000, 0, false},
+		{"product above 64 bits", math.MaxUint64, 1000, 10000, math.MaxUint64/10 + 1, false}, // 1844674407370955161.5 → 1844674407370955162
+		{"quotient above 64 bits", math.MaxUint64, 3, 2, 0, true},
+		{"division by zero", 1, 1, 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := MulDivRound(tt.a, tt.b, tt.d)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("MulDivRound = %d, want error", got)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Fatalf("MulDivRound(%d, %d, %d) = %d, %v, want %d", tt.a, tt.b, tt.d, got, err, tt.want)
+			}
+		})
+	}
+	if _, err := MulDivRound(math.MaxUint64, 3, 2); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("overflow error = %v, want ErrOverflow", err)
+	}
+}
+
+func TestCalculatorApply(t *testing.T) {
+	exempt := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+	taxed := uuid.MustParse("00000000-0000-0000-0000-000000000002")
+	calc := Calculator{
+		Tax: TaxEngine{Rules: []Rule{
+			ExemptItems{exempt: true},
+			FlatRate{Region: "VN", BasisPoints: 1000},
+			FlatRate{BasisPoints: 500},
+		}},
+		ShippingFees:     Amounts{"VND": 30000, "USD": 500},
+		FreeShippingFrom: Amounts{"VND": 500000, "USD": 0},
+	}
+	item := func(id uuid.UUID, qty uint, price uint64, currency string) model.LineItem {
+		return model.LineItem{ItemID: id, Quantity: qty, Price: model.Money{Amount: price, Currency: currency}}
+	}
+
+	tests := []struct {
+		name                                string
+		order                               model.Order
+		subtotal, tax, shipping, grandTotal uint64
+	}{
+		{
+			name:     "region rate and exempt item",
+			order:    model.Order{Currency: "VND", Region: "VN", LineItems: []model.LineItem{item(taxed, 2, 100000, "VND"), item(exempt, 1, 50000, "VND")}},
+			subtotal: 250000, tax: 20000, shipping: 30000, grandTotal: 300000,
+		},
+		{
+			name:     "default rate and free shipping",
+			order:    model.Order{Currency: "VND", Region: "US-CA", LineItems: []model.LineItem{item(taxed, 5, 100000, "VND")}},
+			subtotal: 500000, tax: 25000, shipping: 0, grandTotal: 525000,
+		},
+		{
+			name:     "zero threshold is not free shipping",
+			order:    model.Order{Currency: "USD", LineItems: []model.LineItem{item(taxed, 1, 10, "USD")}},
+			subtotal: 10, tax: 1, shipping: 500, grandTotal: 511, // 0.5 → 1
+		},
+		{
+			name:  "empty order has no shipping",
+			order: model.Order{Currency: "VND"},
+		},
+		{
+			name:     "currency without shipping fee",
+			order:    model.Order{Currency: "EUR", Region: "VN", LineItems: []model.LineItem{item(exempt, 3, 700, "EUR")}},
+			subtotal: 2100, grandTotal: 2100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			o := tt.order
+			if err := calc.Apply(&o); err != nil {
+				t.Fatalf("Apply: %v", err)
+			}
+			got := [4]uint64{o.Subtotal.Amount, o.Tax.Amount, o.Shipping.Amount, o.GrandTotal.Amount}
+			want := [4]uint64{tt.subtotal, tt.tax, tt.shipping, tt.grandTotal}
+			if got != want {
+				t.Fatalf("subtotal, tax, shipping, grand total = %v, want %v", got, want)
+			}
+			if o.GrandTotal.Currency != o.Currency || o.BaseTotal != nil {
+				t.Fatalf("grand total = %+v, base total = %v", o.GrandTotal, o.BaseTotal)
+			}
+		})
+	}
+}
+
+func TestCalculatorApplyErrors(t *testing.T) {
+	calc := Calculator{}
+	id := uuid.MustParse("00000000-0000-0000-0000-000000000001")
+
+	mismatch := model.Order{Currency: "VND", LineItems: []model.LineItem{{ItemID: id, Quantity: 1, Price: model.Money{Amount: 1, Currency: "USD"}}}}
+	if err := calc.Apply(&mismatch); !errors.Is(err, model.ErrCurrencyMismatch) {
+		t.Fatalf("currency mismatch error = %v", err)
+	}
+
+	overflow := model.Order{Currency: "VND", LineItems: []model.LineItem{
+		{ItemID: id, Quantity: 2, Price: model.Money{Amount: math.MaxUint64 / 2, Currency: "VND"}},
+		{ItemID: id, Quantity: 1, Price: model.Money{Amount: 2, Currency: "VND"}},
+	}}
+	if err := calc.Apply(&overflow); !errors.Is(err, ErrOverflow) {
+		t.Fatalf("overflow error = %v, want ErrOverflow", err)
+	}
+	if overflow.GrandTotal.Amount != 0 {
+		t.Fatalf("order changed after overflow: %+v", overflow.GrandTotal)
+	}
+}
@@ -0,0 +1,67 @@
+package pricing
+
+import (
+	"strings"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/google/uuid"
+)
+
+// Thuế suất tính theo basis point: 1% = 100, 10% = 1000
+const basisPointsPerUnit = 10000
+
+// Rule quyết định thuế của một line item.
+// amount là thành tiền của line item (Price * Quantity).
+// matched bằng false nghĩa là rule không áp dụng cho line item này.
+type Rule interface {
+	Tax(region string, item model.LineItem, amount uint64) (tax uint64, matched bool, err error)
+}
+
+// ExemptItems miễn thuế cho các mặt hàng trong danh sách
+type ExemptItems map[uuid.UUID]bool
+
+func (e ExemptItems) Tax(region string, item model.LineItem, amount uint64) (uint64, bool, error) {
+	if e[item.ItemID] {
+		return 0, true, nil
+	}
+	return 0, false, nil
+}
+
+// FlatRate áp dụng một thuế suất cố định cho mọi mặt hàng giao tới Region.
+// Region rỗng áp dụng cho mọi khu vực (dùng làm thuế suất mặc định).
+type FlatRate struct {
+	Region      string
+	BasisPoints uint64 // thuế suất, ví dụ 1000 = 10%
+}
+
+func (f FlatRate) Tax(region string, item model.LineItem, amount uint64) (uint64, bool, error) {
+	if f.Region != "" && !strings.EqualFold(f.Region, region) {
+		return 0, false, nil
+	}
+	tax, err := MulDivRound(amount, f.BasisPoints, basisPointsPerUnit)
+	if err != nil {
+		return 0, false, err
+	}
+	return tax, true, nil
+}
+
+// TaxEngine hỏi lần lượt các rule, rule đầu tiên khớp quyết định thuế của line item.
+// Line item không khớp rule nào thì không chịu thuế.
+// Nên đặt ExemptItems trước, rồi FlatRate theo từng khu vực, cuối cùng là FlatRate mặc định.
+type TaxEngine struct {
+	Rules []Rule
+}
+
+// ItemTax trả về thuế của một line item có thành tiền amount
+func (e TaxEngine) ItemTax(region string, item model.LineItem, amount uint64) (uint64, error) {
+	for _, rule := range e.Rules {
+		tax, matched, err := rule.Tax(region, item, amount)
+		if err != nil {
+			return 0, err
+		}
+		if matched {
+			return tax, nil
+		}
+	}
+	return 0, nil
+}
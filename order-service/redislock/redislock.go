@@ -0,0 +1,75 @@
+package redislock
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Dùng để báo lỗi khi khóa đã hết hạn và có thể đang thuộc về instance khác
+var ErrLost = errors.New("lock lost")
+
+/*
+Lock là một khóa trong Redis giữ trong ttl, giá trị của key là token riêng của
+người giữ khóa. Gia hạn và trả khóa chỉ thực hiện khi key còn đúng token,
+nên instance chạy chậm (khóa đã hết hạn và bị instance khác lấy) không thể
+gia hạn hay xóa nhầm khóa của instance khác.
+*/
+type Lock struct {
+	client *redis.Client
+	key    string
+	token  string
+}
+
+// Acquire lấy khóa key trong ttl. Trả về nil nếu khóa đang thuộc về nơi khác.
+func Acquire(ctx context.Context, client *redis.Client, key string, ttl time.Duration) (*Lock, error) {
+	token := uuid.NewString()
+	ok, err := client.SetNX(ctx, key, token, ttl).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to acquire lock %s: %w", key, err)
+	}
+	if !ok {
+		return nil, nil
+	}
+	return &Lock{client: client, key: key, token: token}, nil
+}
+
+// renewScript đặt lại hạn của khóa nếu key còn đúng token, trả về 0 nếu không
+var renewScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return 0
+`)
+
+// releaseScript xóa khóa nếu key còn đúng token
+var releaseScript = redis.NewScript(`
+if redis.call('GET', KEYS[1]) == ARGV[1] then
+	return redis.call('DEL', KEYS[1])
+end
+return 0
+`)
+
+// Renew gia hạn khóa thêm ttl, trả về ErrLost nếu khóa không còn thuộc về l
+func (l *Lock) Renew(ctx context.Context, ttl time.Duration) error {
+	n, err := renewScript.Run(ctx, l.client, []string{l.key}, l.token, ttl.Milliseconds()).Int()
+	if err != nil {
+		return fmt.Errorf("failed to renew lock %s: %w", l.key, err)
+	}
+	if n == 0 {
+		return ErrLost
+	}
+	return nil
+}
+
+// Release trả khóa, khóa đã hết hạn hoặc thuộc về nơi khác thì giữ nguyên
+func (l *Lock) Release(ctx context.Context) error {
+	if err := releaseScript.Run(ctx, l.client, []string{l.key}, l.token).Err(); err != nil {
+		return fmt.Errorf("failed to release lock %s: %w", l.key, err)
+	}
+	return nil
+}
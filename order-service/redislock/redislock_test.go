@@ -0,0 +1,60 @@
+package redislock
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Khóa đã hết hạn và bị instance khác lấy thì người giữ cũ không gia hạn hay xóa được
+func TestLockOwnership(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	first, err := Acquire(ctx, client, "test:lock", time.Minute)
+	if err != nil || first == nil {
+		t.Fatalf("Acquire = %v, %v", first, err)
+	}
+	if other, err := Acquire(ctx, client, "test:lock", time.Minute); err != nil || other != nil {
+		t.Fatalf("second Acquire = %v, %v, want nil", other, err)
+	}
+
+	// Gia hạn khi còn giữ khóa
+	mr.FastForward(50 * time.Second)
+	if err := first.Renew(ctx, time.Minute); err != nil {
+		t.Fatalf("Renew: %v", err)
+	}
+	mr.FastForward(50 * time.Second)
+	if !mr.Exists("test:lock") {
+		t.Fatalf("lock expired after renew")
+	}
+
+	// Hết hạn, instance khác lấy khóa
+	mr.FastForward(time.Minute)
+	second, err := Acquire(ctx, client, "test:lock", time.Minute)
+	if err != nil || second == nil {
+		t.Fatalf("Acquire after expiry = %v, %v", second, err)
+	}
+	if err := first.Renew(ctx, time.Minute); !errors.Is(err, ErrLost) {
+		t.Fatalf("Renew of expired lock = %v, want ErrLost", err)
+	}
+	if err := first.Release(ctx); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if !mr.Exists("test:lock") {
+		t.Fatalf("stale Release deleted another owner's lock")
+	}
+
+	if err := second.Release(ctx); err != nil {
+		t.Fatalf("Release: %v", err)
+	}
+	if mr.Exists("test:lock") {
+		t.Fatalf("lock still held after Release")
+	}
+}
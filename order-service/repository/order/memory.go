@@ -0,0 +1,241 @@
+package order
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/util"
+)
+
+// MemoryRepo lưu đơn hàng ngay trong bộ nhớ của process.
+// Dùng cho unit test hoặc chạy thử order-service khi không có Redis,
+// dữ liệu sẽ mất khi tắt service.
+type MemoryRepo struct {
+	mu       sync.RWMutex
+	orders   map[uint64]model.Order
+	archived map[uint64]model.Order // order đã bị xóa mềm
+	history  map[uint64][]model.StatusChange
+}
+
+func NewMemoryRepo() *MemoryRepo {
+	return &MemoryRepo{
+		orders:   make(map[uint64]model.Order),
+		archived: make(map[uint64]model.Order),
+		history:  make(map[uint64][]model.StatusChange),
+	}
+}
+
+// copyOrder sao chép slice LineItems và Payments để caller không sửa được dữ liệu bên trong repo
+func copyOrder(o model.Order) model.Order {
+	if o.LineItems != nil {
+		items := make([]model.LineItem, len(o.LineItems))
+		copy(items, o.LineItems)
+		o.LineItems = items
+	}
+	if o.Payments != nil {
+		payments := make([]model.PaymentIntent, len(o.Payments))
+		copy(payments, o.Payments)
+		o.Payments = payments
+	}
+	return o
+}
+
+// Insert lưu order nếu ID chưa tồn tại (giống SETNX của Redis), ngược lại trả về ErrAlreadyExists
+func (r *MemoryRepo) Insert(ctx context.Context, order model.Order, changes ...model.StatusChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exist := r.orders[order.OrderID]; exist {
+		return ErrAlreadyExists
+	}
+	if _, exist := r.archived[order.OrderID]; exist {
+		return ErrAlreadyExists
+	}
+	r.orders[order.OrderID] = copyOrder(order)
+	r.history[order.OrderID] = append(r.history[order.OrderID], changes...)
+	return nil
+}
+
+// InsertMany lưu lần lượt từng order như Insert trong cùng một lần khóa
+func (r *MemoryRepo) InsertMany(ctx context.Context, orders []model.Order) ([]error, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	errs := make([]error, len(orders))
+	for i, order := range orders {
+		_, active := r.orders[order.OrderID]
+		_, archived := r.archived[order.OrderID]
+		if active || archived {
+			errs[i] = ErrAlreadyExists
+			continue
+		}
+		r.orders[order.OrderID] = copyOrder(order)
+	}
+	return errs, nil
+}
+
+// Exists kiểm tra từng ID trong order đang hoạt động và vùng lưu trữ
+func (r *MemoryRepo) Exists(ctx context.Context, ids []uint64) ([]bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	exists := make([]bool, len(ids))
+	for i, id := range ids {
+		_, active := r.orders[id]
+		_, archived := r.archived[id]
+		exists[i] = active || archived
+	}
+	return exists, nil
+}
+
+func (r *MemoryRepo) FindByID(ctx context.Context, id uint64) (model.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, exist := r.orders[id]
+	if !exist {
+		return model.Order{}, ErrNotExist
+	}
+	return copyOrder(o), nil
+}
+
+// Update chỉ ghi đè khi order đã tồn tại (giống SETXX của Redis)
+// và version đang lưu bằng order.Version - 1
+func (r *MemoryRepo) Update(ctx context.Context, order model.Order, changes ...model.StatusChange) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	old, exist := r.orders[order.OrderID]
+	if !exist {
+		return ErrNotExist
+	}
+	if old.Version+1 != order.Version {
+		return ErrVersionMismatch
+	}
+	r.orders[order.OrderID] = copyOrder(order)
+	r.history[order.OrderID] = append(r.history[order.OrderID], changes...)
+	return nil
+}
+
+func (r *MemoryRepo) FindHistory(ctx context.Context, id uint64) ([]model.StatusChange, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	history := make([]model.StatusChange, len(r.history[id]))
+	copy(history, r.history[id])
+	return history, nil
+}
+
+// DeleteByID chuyển order sang vùng lưu trữ, lịch sử trạng thái được giữ lại
+func (r *MemoryRepo) DeleteByID(ctx context.Context, id uint64, deletedBy string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o, exist := r.orders[id]
+	if !exist {
+		return ErrNotExist
+	}
+	now := util.CustomTime(time.Now().UTC())
+	o.DeletedAt = &now
+	o.DeletedBy = deletedBy
+	o.Version++
+
+	r.archived[id] = o
+	delete(r.orders, id)
+	return nil
+}
+
+func (r *MemoryRepo) FindDeletedByID(ctx context.Context, id uint64) (model.Order, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	o, exist := r.archived[id]
+	if !exist {
+		return model.Order{}, ErrNotExist
+	}
+	return copyOrder(o), nil
+}
+
+func (r *MemoryRepo) Restore(ctx context.Context, id uint64) (model.Order, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	o, exist := r.archived[id]
+	if !exist {
+		return model.Order{}, ErrNotExist
+	}
+	if _, exist := r.orders[id]; exist {
+		return model.Order{}, ErrAlreadyExists
+	}
+	o.DeletedAt = nil
+	o.DeletedBy = ""
+	o.Version++
+
+	r.orders[id] = o
+	delete(r.archived, id)
+	return copyOrder(o), nil
+}
+
+// FindAll trả về các order thỏa bộ lọc, sắp xếp theo thời điểm tạo rồi đến ID.
+// Trang bắt đầu ngay sau page.After, Next trả về nil khi đã hết dữ liệu.
+func (r *MemoryRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	desc := page.Sort == SortCreatedAtDesc
+	// less so sánh hai vị trí (thời điểm tạo, ID) theo thứ tự sắp xếp
+	less := func(ti int64, idi uint64, tj int64, idj uint64) bool {
+		if ti != tj {
+			return (ti < tj && !desc) || (ti > tj && desc)
+		}
+		return (idi < idj && !desc) || (idi > idj && desc)
+	}
+
+	matched := make([]model.Order, 0, len(r.orders))
+	for _, o := range r.orders {
+		if !page.Filter.Match(o) {
+			continue
+		}
+		// Bỏ các order đứng trước hoặc tại vị trí After
+		if page.After != nil && !less(page.After.CreatedAt.UnixNano(), page.After.OrderID, createdUnixNano(o), o.OrderID) {
+			continue
+		}
+		matched = append(matched, o)
+	}
+
+	sort.Slice(matched, func(i, j int) bool {
+		return less(createdUnixNano(matched[i]), matched[i].OrderID, createdUnixNano(matched[j]), matched[j].OrderID)
+	})
+
+	end := uint64(len(matched))
+	if page.Size > 0 && page.Size < end {
+		end = page.Size
+	}
+
+	orders := make([]model.Order, 0, end)
+	for _, o := range matched[:end] {
+		orders = append(orders, copyOrder(o))
+	}
+
+	var next *Position
+	if end < uint64(len(matched)) {
+		last := orders[len(orders)-1]
+		next = &Position{CreatedAt: time.Unix(0, createdUnixNano(last)).UTC(), OrderID: last.OrderID}
+	}
+
+	return FindResult{
+		Orders: orders,
+		Next:   next,
+	}, nil
+}
+
+// createdUnixNano trả về thời điểm tạo của order, order không có created_at xếp đầu tiên
+func createdUnixNano(o model.Order) int64 {
+	if o.CreateAt == nil {
+		return 0
+	}
+	return o.CreateAt.UnixNano()
+}
@@ -5,14 +5,46 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"os"
+	"time"
 
+	"github.com/RibunLoc/microservices-learn/events"
 	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/util"
 	"github.com/redis/go-redis/v9"
 )
 
 type RedisRepo struct {
 	Client *redis.Client // Redis client từ go-redis
+
+	// Stream nhận event thay đổi đơn hàng (outbox), mặc định events.DefaultStream.
+	// Event được XADD trong cùng MULTI/EXEC với lệnh ghi order.
+	Stream string
+
+	// Số event tối đa giữ lại trong Stream (cắt gần đúng), mặc định events.DefaultMaxLen
+	MaxLen int64
+}
+
+func (r *RedisRepo) stream() string {
+	if r.Stream == "" {
+		return events.DefaultStream
+	}
+	return r.Stream
+}
+
+// orderEvents tạo các event cho một lần Update: mỗi lần đổi trạng thái là một
+// order.status_changed, Update không đổi trạng thái thì là order.updated
+func orderEvents(order model.Order, changes []model.StatusChange, now time.Time) []events.Event {
+	if len(changes) == 0 {
+		return []events.Event{events.New(events.TypeOrderUpdated, order, now)}
+	}
+
+	list := make([]events.Event, 0, len(changes))
+	for i := range changes {
+		e := events.New(events.TypeOrderStatusChanged, order, now)
+		e.Change = &changes[i]
+		list = append(list, e)
+	}
+	return list
 }
 
 // Tạo key Redis dạng: "order:123"
@@ -20,46 +52,179 @@ func orderIDKey(id uint64) string {
 	return fmt.Sprintf("order:%d", id)
 }
 
-// Hàm Insert lưu order vào Redis
-func (r *RedisRepo) Insert(ctx context.Context, order model.Order) error {
+// Hàm Insert lưu order vào Redis cùng các bản ghi lịch sử trạng thái (nếu có)
+func (r *RedisRepo) Insert(ctx context.Context, order model.Order, changes ...model.StatusChange) error {
 	// 1. Mã hóa strut Order thành chuỗi JSON
 	data, err := json.Marshal(order)
 	if err != nil {
 		return fmt.Errorf("failed to encode order: %w", err)
 	}
+	entries, err := encodeChanges(changes)
+	if err != nil {
+		return err
+	}
 
 	// 2. Tạo key Redis cho đơn hàng (ví dụ: "order:123")
 	key := orderIDKey(order.OrderID)
 
-	// 3. Tạo pipeline transaction (Gom nhiều lệnh lại và thực thi cùng một lúc)
-	txn := r.Client.TxPipeline()
+	// 3. WATCH key order: nếu key được tạo bởi request khác giữa lúc kiểm tra và ghi
+	// thì transaction bị hủy, order cũ không bao giờ bị ghi đè
+	archiveKey := archivedOrderKey(order.OrderID)
+	err = r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// 4. Kiểm tra key đã tồn tại chưa, kể cả order đã bị xóa trong vùng lưu trữ
+		n, err := tx.Exists(ctx, key, archiveKey).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check order: %w", err)
+		}
+		if n > 0 {
+			return ErrAlreadyExists
+		}
+
+		// 5. Tạo pipeline transaction (Gom nhiều lệnh lại và thực thi cùng một lúc)
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// 5.1 Lưu order vào Redis nếu key chưa tồn tại (SETNX)
+			pipe.SetNX(ctx, key, string(data), 0)
+
+			// 5.2 Thêm key order vào tập hợp "orders" (để dễ truy vấn sau này)
+			pipe.SAdd(ctx, "orders", key)
+
+			// 5.3 Thêm vào các index phụ (theo khách hàng, trạng thái, ngày tạo)
+			addToIndexes(ctx, pipe, order)
+			if len(entries) > 0 {
+				pipe.RPush(ctx, historyKey(order.OrderID), entries...)
+			}
+
+			// 5.4 Ghi event order.created vào stream (outbox)
+			return events.Append(ctx, pipe, r.stream(), r.MaxLen, events.New(events.TypeOrderCreated, order, time.Now()))
+		})
+		return err
+	}, key, archiveKey)
+
+	// 6. Key đã tồn tại (hoặc vừa bị tạo bởi request khác), order cũ không bị ghi đè
+	if errors.Is(err, ErrAlreadyExists) || errors.Is(err, redis.TxFailedErr) {
+		return ErrAlreadyExists
+	} else if err != nil {
+		return fmt.Errorf("failed to exec: %w", err)
+	}
+	return nil
+}
 
-	// 4. Lưu order vào Redis nếu key chưa tồn tại (SETNX)
-	res := txn.SetNX(ctx, key, string(data), 0)
+/*
+InsertMany lưu cả lô order bằng hai lần gửi lệnh thay vì một lần cho mỗi order:
+ 1. WATCH key của cả lô và kiểm tra các key đã tồn tại trong một pipeline
+ 2. Ghi các order còn lại cùng index và event trong một MULTI/EXEC
 
-	// 5. Kiểm tra khi lỗi gọi SETNX
-	if err := res.Err(); err != nil {
-		txn.Discard()
-		return fmt.Errorf("failed to set: %w", err)
+Nếu có key bị request khác tạo giữa hai bước thì transaction bị hủy,
+khi đó ghi lại từng order bằng Insert.
+*/
+func (r *RedisRepo) InsertMany(ctx context.Context, orders []model.Order) ([]error, error) {
+	if len(orders) == 0 {
+		return nil, nil
 	}
 
-	// 6. Thêm key order vào tập hợp "orders" (để dễ truy vấn sau này)
-	if err := txn.SAdd(ctx, "orders", key).Err(); err != nil {
-		txn.Discard()
-		return fmt.Errorf("failed to add to orders set: %w", err)
+	// 1. Mã hóa toàn bộ order trước, lỗi ở đây là lỗi của cả lô
+	data := make([]string, len(orders))
+	keys := make([]string, 0, 2*len(orders))
+	for i, order := range orders {
+		b, err := json.Marshal(order)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode order: %w", err)
+		}
+		data[i] = string(b)
+		keys = append(keys, orderIDKey(order.OrderID), archivedOrderKey(order.OrderID))
 	}
 
-	// 7. Thực thi tất cả lệnh trong pipeline
-	if _, err := txn.Exec(ctx); err != nil {
-		return fmt.Errorf("failed to exec: %w", err)
+	var errs []error
+	err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// 2. Kiểm tra các key đã tồn tại (kể cả trong vùng lưu trữ) trong một pipeline
+		exists := make([]*redis.IntCmd, len(orders))
+		_, err := tx.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, order := range orders {
+				exists[i] = pipe.Exists(ctx, orderIDKey(order.OrderID), archivedOrderKey(order.OrderID))
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to check orders: %w", err)
+		}
+
+		errs = make([]error, len(orders))
+		seen := make(map[uint64]bool, len(orders))
+		for i, order := range orders {
+			if exists[i].Val() > 0 || seen[order.OrderID] {
+				errs[i] = ErrAlreadyExists
+			}
+			seen[order.OrderID] = true
+		}
+
+		// 3. Ghi các order chưa tồn tại giống Insert
+		now := time.Now()
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			for i, order := range orders {
+				if errs[i] != nil {
+					continue
+				}
+				key := orderIDKey(order.OrderID)
+				pipe.SetNX(ctx, key, data[i], 0)
+				pipe.SAdd(ctx, "orders", key)
+				addToIndexes(ctx, pipe, order)
+				if err := events.Append(ctx, pipe, r.stream(), r.MaxLen, events.New(events.TypeOrderCreated, order, now)); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return err
+	}, keys...)
+
+	// 4. Có key vừa bị tạo bởi request khác, ghi lại từng order
+	if errors.Is(err, redis.TxFailedErr) {
+		errs = make([]error, len(orders))
+		for i, order := range orders {
+			err := r.Insert(ctx, order)
+			if errors.Is(err, ErrAlreadyExists) {
+				errs[i] = err
+			} else if err != nil {
+				return nil, err
+			}
+		}
+		return errs, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to exec: %w", err)
 	}
-	return nil
+	return errs, nil
 }
 
 // Dùng để báo lỗi khi không tìm thấy order
 var ErrNotExist = errors.New("order does not exist")
 
+// Dùng để báo lỗi khi Insert một order có ID đã tồn tại
+var ErrAlreadyExists = errors.New("order already exists")
+
+// Dùng để báo lỗi khi Update nhưng order đã bị thay đổi bởi request khác
+var ErrVersionMismatch = errors.New("order version mismatch")
+
 // findByID truy vấn Redis để lấy order theo ID
+// Exists kiểm tra key của từng order và key trong vùng lưu trữ trong một pipeline
+func (r *RedisRepo) Exists(ctx context.Context, ids []uint64) ([]bool, error) {
+	cmds := make([]*redis.IntCmd, len(ids))
+	_, err := r.Client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, id := range ids {
+			cmds[i] = pipe.Exists(ctx, orderIDKey(id), archivedOrderKey(id))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to check orders: %w", err)
+	}
+
+	exists := make([]bool, len(ids))
+	for i, cmd := range cmds {
+		exists[i] = cmd.Val() > 0
+	}
+	return exists, nil
+}
+
 func (r *RedisRepo) FindByID(ctx context.Context, id uint64) (model.Order, error) {
 	// 1. Tạo key Redis từ order ID
 	key := orderIDKey(id)
@@ -85,122 +250,376 @@ func (r *RedisRepo) FindByID(ctx context.Context, id uint64) (model.Order, error
 	return order, nil
 }
 
-// DeleteByID xóa order dựa trên id cung cấp
-func (r *RedisRepo) DeleteByID(ctx context.Context, id uint64) error {
+/*
+Vùng lưu trữ (archive) chứa các order đã bị xóa mềm:
+  - "archive:order:{id}": JSON của order kèm deleted_at/deleted_by
+  - "archive:orders": set các key order trong vùng lưu trữ
+
+Order trong vùng lưu trữ không nằm trong set "orders" và các index phụ
+nên không xuất hiện khi liệt kê, lịch sử "order:{id}:history" được giữ nguyên.
+*/
+const archivedOrdersKey = "archive:orders"
+
+func archivedOrderKey(id uint64) string {
+	return fmt.Sprintf("archive:order:%d", id)
+}
+
+// DeleteByID xóa mềm order: chuyển order sang vùng lưu trữ kèm người xóa và thời điểm xóa
+func (r *RedisRepo) DeleteByID(ctx context.Context, id uint64, deletedBy string) error {
 	// 1. Tạo key Redis từ order ID
 	key := orderIDKey(id)
+	archiveKey := archivedOrderKey(id)
+
+	// 2. WATCH key order, cần đọc order cũ để biết phải xóa khỏi index nào
+	err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		old, err := getOrder(ctx, tx, key)
+		if err != nil {
+			return err
+		}
 
-	// 2. Tạo pipline transaction để gom lệnh thực thi
-	txn := r.Client.TxPipeline()
+		// 3. Đánh dấu đã xóa, tăng version để ETag cũ không còn dùng được
+		deleted := old
+		now := util.CustomTime(time.Now().UTC())
+		deleted.DeletedAt = &now
+		deleted.DeletedBy = deletedBy
+		deleted.Version++
 
-	// 3. Thêm lệnh xóa key order
-	err := txn.Del(ctx, key).Err()
+		data, err := json.Marshal(deleted)
+		if err != nil {
+			return fmt.Errorf("failed to encode order: %w", err)
+		}
+
+		// 4. Tạo pipline transaction để gom lệnh thực thi
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			// 4.1 Chuyển order từ key đang hoạt động sang vùng lưu trữ
+			pipe.Del(ctx, key)
+			pipe.Set(ctx, archiveKey, string(data), 0)
+			pipe.SAdd(ctx, archivedOrdersKey, archiveKey)
+
+			// 4.2 Thêm lệnh xóa key khỏi set "orders" (danh sách order) và các index phụ
+			pipe.SRem(ctx, "orders", key)
+			removeFromIndexes(ctx, pipe, old)
+
+			// 4.3 Ghi event order.deleted kèm order đã được lưu trữ
+			return events.Append(ctx, pipe, r.stream(), r.MaxLen, events.New(events.TypeOrderDeleted, deleted, time.Now()))
+		})
+		return err
+	}, key)
+
+	// 5. Kiểm tra kết quả thực thi pipeline
+	if errors.Is(err, ErrNotExist) {
+		return ErrNotExist // trả về lỗi dữ liệu không tồn tại
+	} else if errors.Is(err, redis.TxFailedErr) {
+		return ErrVersionMismatch // order bị request khác sửa trong lúc đang xóa
+	} else if err != nil {
+		return fmt.Errorf("failed to exec: %w", err)
+	}
+
+	return nil
+}
+
+// FindDeletedByID đọc order đã bị xóa mềm trong vùng lưu trữ
+func (r *RedisRepo) FindDeletedByID(ctx context.Context, id uint64) (model.Order, error) {
+	value, err := r.Client.Get(ctx, archivedOrderKey(id)).Result()
 	if errors.Is(err, redis.Nil) {
-		txn.Discard()         // Hủy luôn pipline
-		return os.ErrNotExist // trả về lỗi dữ liệu không tồn tại
+		return model.Order{}, ErrNotExist
 	} else if err != nil {
-		txn.Discard()
-		return fmt.Errorf("get order: %w", err)
+		return model.Order{}, fmt.Errorf("get archived order: %w", err)
 	}
 
-	// 4. Thêm lệnh xóa key khỏi set "orders" (danh sách order)
-	if err := txn.SRem(ctx, "orders", key).Err(); err != nil {
-		txn.Discard()
-		return fmt.Errorf("failed to remove from orders set: %w", err)
+	var order model.Order
+	if err := json.Unmarshal([]byte(value), &order); err != nil {
+		return model.Order{}, fmt.Errorf("failed to decode order json: %w", err)
 	}
+	return order, nil
+}
 
-	// 5. Thực thi pipeline, gồm cả lệnh xóa key và xóa khỏi set
-	if _, err := txn.Exec(ctx); err != nil {
-		return fmt.Errorf("failed to exec: %w", err)
+// RewriteDeletedCustomerIDs ghi lại customer_id của các order trong vùng lưu trữ,
+// mỗi order được ghi trong một transaction WATCH key lưu trữ của nó
+func (r *RedisRepo) RewriteDeletedCustomerIDs(ctx context.Context, rewrite func(o model.Order) (model.CustomerID, bool)) (int, error) {
+	const batchSize = 100
+
+	rewritten := 0
+	var cursor uint64
+	for {
+		// 1. Quét dần set "archive:orders"
+		keys, next, err := r.Client.SScan(ctx, archivedOrdersKey, cursor, "*", batchSize).Result()
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to scan archived orders: %w", err)
+		}
+
+		// 2. Đọc, đổi customer_id và ghi lại từng order
+		for _, key := range keys {
+			var changed bool
+			err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+				archived, err := getOrder(ctx, tx, key)
+				if errors.Is(err, ErrNotExist) {
+					return nil // order vừa được Restore
+				} else if err != nil {
+					return err
+				}
+
+				newID, ok := rewrite(archived)
+				if !ok {
+					return nil
+				}
+				archived.CustomerID = newID
+				archived.Version++
+				data, err := json.Marshal(archived)
+				if err != nil {
+					return fmt.Errorf("failed to encode order: %w", err)
+				}
+
+				_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+					pipe.Set(ctx, key, string(data), 0)
+					return nil
+				})
+				changed = err == nil
+				return err
+			}, key)
+			if errors.Is(err, redis.TxFailedErr) {
+				return rewritten, fmt.Errorf("%s: %w", key, ErrVersionMismatch)
+			} else if err != nil {
+				return rewritten, fmt.Errorf("%s: %w", key, err)
+			}
+			if changed {
+				rewritten++
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return rewritten, nil
+		}
 	}
+}
 
-	return nil
+// Restore đưa order trong vùng lưu trữ trở lại hoạt động, thêm lại vào "orders" và các index
+func (r *RedisRepo) Restore(ctx context.Context, id uint64) (model.Order, error) {
+	key := orderIDKey(id)
+	archiveKey := archivedOrderKey(id)
+
+	var restored model.Order
+	err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// 1. Order phải đang nằm trong vùng lưu trữ
+		archived, err := getOrder(ctx, tx, archiveKey)
+		if err != nil {
+			return err
+		}
+
+		// 2. Không ghi đè nếu key đang hoạt động đã có order
+		n, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return fmt.Errorf("failed to check order: %w", err)
+		}
+		if n > 0 {
+			return ErrAlreadyExists
+		}
+
+		// 3. Bỏ đánh dấu xóa và tăng version
+		restored = archived
+		restored.DeletedAt = nil
+		restored.DeletedBy = ""
+		restored.Version++
+
+		data, err := json.Marshal(restored)
+		if err != nil {
+			return fmt.Errorf("failed to encode order: %w", err)
+		}
+
+		// 4. Chuyển order về key hoạt động và thêm lại vào các index trong cùng MULTI/EXEC
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetNX(ctx, key, string(data), 0)
+			pipe.SAdd(ctx, "orders", key)
+			addToIndexes(ctx, pipe, restored)
+
+			pipe.Del(ctx, archiveKey)
+			pipe.SRem(ctx, archivedOrdersKey, archiveKey)
+
+			return events.Append(ctx, pipe, r.stream(), r.MaxLen, events.New(events.TypeOrderRestored, restored, time.Now()))
+		})
+		return err
+	}, key, archiveKey)
+
+	if errors.Is(err, ErrNotExist) || errors.Is(err, ErrAlreadyExists) {
+		return model.Order{}, err
+	} else if errors.Is(err, redis.TxFailedErr) {
+		return model.Order{}, ErrVersionMismatch
+	} else if err != nil {
+		return model.Order{}, fmt.Errorf("failed to exec: %w", err)
+	}
+	return restored, nil
+}
+
+// getOrder đọc và giải mã order trong một transaction đang WATCH key
+func getOrder(ctx context.Context, tx *redis.Tx, key string) (model.Order, error) {
+	value, err := tx.Get(ctx, key).Result()
+	if errors.Is(err, redis.Nil) {
+		return model.Order{}, ErrNotExist
+	} else if err != nil {
+		return model.Order{}, fmt.Errorf("get order: %w", err)
+	}
+
+	var order model.Order
+	if err := json.Unmarshal([]byte(value), &order); err != nil {
+		return model.Order{}, fmt.Errorf("failed to decode order json: %w", err)
+	}
+	return order, nil
 }
 
-// Cập nhật thông tin đơn hàng vảo Redis nếu key đã tồn tại
-func (r *RedisRepo) Update(ctx context.Context, order model.Order) error {
+// Cập nhật thông tin đơn hàng vảo Redis nếu key đã tồn tại và version đang lưu
+// bằng order.Version - 1, đồng thời ghi thêm các bản ghi lịch sử trạng thái
+// vào cuối list lịch sử của order
+func (r *RedisRepo) Update(ctx context.Context, order model.Order, changes ...model.StatusChange) error {
 	// 1. Chuyển struct Order thành chuỗi JSON để lưu vào Redis
 	data, err := json.Marshal(order)
 	if err != nil {
 		return fmt.Errorf("failed to encode order: %w", err)
 	}
 
-	// 2. Thông báo tên key cần thay đổi
+	// 2. Mã hóa các bản ghi lịch sử
+	entries, err := encodeChanges(changes)
+	if err != nil {
+		return err
+	}
+
+	// 3. Thông báo tên key cần thay đổi
 	key := orderIDKey(order.OrderID)
 
-	// 3. Dùng lệnh SETXX: chỉ cập nhật giá trị nếu key đã tồi tại trong Redis
-	err = r.Client.SetXX(ctx, key, string(data), 0).Err()
-	if errors.Is(err, redis.Nil) {
-		return os.ErrNotExist
+	// 4. WATCH key order: nếu key bị sửa hoặc xóa giữa lúc kiểm tra và ghi
+	// thì transaction bị hủy (compare-and-set)
+	err = r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// 4.1 Chỉ cập nhật nếu key đã tồn tại trong Redis,
+		// order cũ dùng để chuyển order sang đúng index mới
+		old, err := getOrder(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+
+		// 4.2 Version đang lưu phải đúng là version client đã đọc
+		if old.Version+1 != order.Version {
+			return ErrVersionMismatch
+		}
+
+		// 4.3 Ghi order, lịch sử, cập nhật index và event trong cùng một MULTI/EXEC
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.SetXX(ctx, key, string(data), 0)
+			if len(entries) > 0 {
+				pipe.RPush(ctx, historyKey(order.OrderID), entries...)
+			}
+			removeFromIndexes(ctx, pipe, old)
+			addToIndexes(ctx, pipe, order)
+
+			for _, e := range orderEvents(order, changes, time.Now()) {
+				if err := events.Append(ctx, pipe, r.stream(), r.MaxLen, e); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		return err
+	}, key)
+	if errors.Is(err, ErrNotExist) || errors.Is(err, ErrVersionMismatch) {
+		return err
+	} else if errors.Is(err, redis.TxFailedErr) {
+		// Order bị request khác ghi đè trong lúc đang cập nhật
+		return ErrVersionMismatch
+	} else if err != nil {
+		return fmt.Errorf("failed to exec: %w", err)
 	}
 
 	return nil
 }
 
-/*
-	Định nghĩa thông tin phân trang: lấy bao nhiêu phần tử (Size),
+// encodeChanges mã hóa các bản ghi lịch sử thành JSON để RPUSH vào list lịch sử
+func encodeChanges(changes []model.StatusChange) ([]interface{}, error) {
+	entries := make([]interface{}, 0, len(changes))
+	for _, change := range changes {
+		entry, err := json.Marshal(change)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode status change: %w", err)
+		}
+		entries = append(entries, string(entry))
+	}
+	return entries, nil
+}
 
-bắt đầu từ đâu (Offset)
-*/
-type FindAllPage struct {
-	Size   uint64 // Số lượng kết quả muốn lấy mỗi lần
-	Offset uint64 // Vị trí bắt đầu (cursor) trong tập hợp
+// Key Redis chứa lịch sử trạng thái của order, dạng: "order:123:history"
+func historyKey(id uint64) string {
+	return fmt.Sprintf("order:%d:history", id)
 }
 
-// là kết quả trả về khi truy vấn: danh sách đơn hàng + cursor tiếp theo để phân trang
-type FindResult struct {
-	Orders []model.Order // Danh sách đơn hàng lấy được
-	Cursor uint64        // Con trỏ tiếp theo (cursor) để try vấn trang tiếp theo
+// FindHistory trả về lịch sử trạng thái của order theo thứ tự thời gian
+func (r *RedisRepo) FindHistory(ctx context.Context, id uint64) ([]model.StatusChange, error) {
+	values, err := r.Client.LRange(ctx, historyKey(id), 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get order history: %w", err)
+	}
+
+	history := make([]model.StatusChange, len(values))
+	for i, value := range values {
+		if err := json.Unmarshal([]byte(value), &history[i]); err != nil {
+			return nil, fmt.Errorf("failed to decode status change json: %w", err)
+		}
+	}
+	return history, nil
 }
 
-// FindALL thực hiện lấy danh sahcs các đơn hàng từ Redis (phân trang bằng SSCAN)
+// FindALL thực hiện lấy danh sách các đơn hàng từ Redis theo bộ lọc,
+// thứ tự dựa trên index created_at (sorted set), phân trang bằng vị trí After
 func (r *RedisRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
-	/*
-		1. Dùng Redis SSCAN để lấy các key đơn hàng từ tập hợp "orders"
-			- page.Offset: con trỏ bắt đầu (cursor)
-			- "*": lấy tất cả key
-			- page.Size: số lượng key cần lấy
-	*/
-	res := r.Client.SScan(ctx, "orders", uint64(page.Offset), "*", int64(page.Size))
-
-	// 2. Lấy danh sách key và cursor tiếp theo từ kết quả SSCAN
-	keys, cursor, err := res.Result()
+	// 1. Lấy danh sách key đơn hàng thỏa bộ lọc, đã sắp xếp và phân trang
+	indexed, more, err := r.findIndexedKeys(ctx, page)
 	if err != nil {
-		return FindResult{}, fmt.Errorf("failed to get order ids: %w", err)
+		return FindResult{}, err
 	}
 
-	// 3. Nếu không có key nào được trả về, trả về danh sách rỗng
-	if len(keys) == 0 {
+	// 2. Nếu không có key nào được trả về, trả về danh sách rỗng
+	if len(indexed) == 0 {
 		return FindResult{
 			Orders: []model.Order{},
 		}, nil
 	}
 
-	// 4. Dùng MGET để lấy dữ liệu chi tiết (giá trị) của các key cùng lúc
+	// 3. Dùng MGET để lấy dữ liệu chi tiết (giá trị) của các key cùng lúc
+	keys := make([]string, len(indexed))
+	for i, z := range indexed {
+		keys[i], _ = z.Member.(string)
+	}
 	xs, err := r.Client.MGet(ctx, keys...).Result()
 	if err != nil {
 		return FindResult{}, fmt.Errorf("failed to get orders: %w", err)
 	}
 
-	// 5. Tạo danh sách đơn hàng (orders) để lưu kết quả slice go
-	orders := make([]model.Order, len(xs))
+	// 4. Tạo danh sách đơn hàng (orders) để lưu kết quả slice go
+	orders := make([]model.Order, 0, len(xs))
 
-	// 6. Lặp qua từng kết quả Redis trả về
-	for i, x := range xs {
-		x := x.(string) // Ép kiểu kết quả sang string (Redis trả về kiểu interface{})
-		var order model.Order
+	// 5. Lặp qua từng kết quả Redis trả về
+	for _, x := range xs {
+		// Order có thể vừa bị xóa sau khi đọc index, MGET trả về nil thì bỏ qua
+		value, ok := x.(string)
+		if !ok {
+			continue
+		}
 
-		// 7. Giải mã chuỗi JSON thành struct `model.Order`
-		err := json.Unmarshal([]byte(x), &order)
-		if err != nil {
+		// 6. Giải mã chuỗi JSON thành struct `model.Order`
+		var order model.Order
+		if err := json.Unmarshal([]byte(value), &order); err != nil {
 			return FindResult{}, fmt.Errorf("failed to decode order json: %w", err)
 		}
-		orders[i] = order // Lưu đơn hàng vào danh sách
+		orders = append(orders, order) // Lưu đơn hàng vào danh sách
 	}
 
-	// 8. Trả về danh sách đơn hàng và cursor để phân trang cho lần sau
+	// 7. Vị trí để lấy trang sau là key cuối trong index (kể cả khi order đó vừa bị xóa),
+	// nil nếu đã hết
+	var next *Position
+	if more {
+		if next, err = indexPosition(indexed[len(indexed)-1]); err != nil {
+			return FindResult{}, err
+		}
+	}
 	return FindResult{
 		Orders: orders,
-		Cursor: cursor,
+		Next:   next,
 	}, nil
 }
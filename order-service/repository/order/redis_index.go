@@ -0,0 +1,332 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+Các index phụ giúp lọc và sắp xếp đơn hàng mà không phải quét toàn bộ.
+Tất cả là sorted set các key order với score là thời điểm tạo (ms):
+  - "orders:created_at": mọi order
+  - "orders:by_customer:{customer_id}": order của một khách hàng
+  - "orders:by_status:{status}": order đang ở một trạng thái
+
+Index luôn được cập nhật trong cùng MULTI/EXEC với order ở Insert/Update/DeleteByID.
+Phiên bản cũ dùng set "orders:customer:*" và "orders:status:*" không có score,
+Reindex xóa chúng và dựng lại index mới.
+*/
+const createdAtIndexKey = "orders:created_at"
+
+func customerIndexKey(id model.CustomerID) string {
+	return fmt.Sprintf("orders:by_customer:%s", id)
+}
+
+func statusIndexKey(status model.Status) string {
+	return fmt.Sprintf("orders:by_status:%s", status)
+}
+
+// legacyIndexPatterns là các index dạng set của phiên bản cũ
+var legacyIndexPatterns = []string{"orders:customer:*", "orders:status:*"}
+
+// createdScore trả về score của order trong index created_at
+func createdScore(o model.Order) float64 {
+	if o.CreateAt == nil {
+		return 0
+	}
+	return float64(o.CreateAt.UnixMilli())
+}
+
+// addToIndexes thêm các lệnh ghi index của order vào pipeline
+func addToIndexes(ctx context.Context, pipe redis.Pipeliner, o model.Order) {
+	z := redis.Z{Score: createdScore(o), Member: orderIDKey(o.OrderID)}
+	pipe.ZAdd(ctx, customerIndexKey(o.CustomerID), z)
+	pipe.ZAdd(ctx, statusIndexKey(lifecycle.Current(o)), z)
+	pipe.ZAdd(ctx, createdAtIndexKey, z)
+}
+
+// removeFromIndexes thêm các lệnh xóa order khỏi index vào pipeline
+func removeFromIndexes(ctx context.Context, pipe redis.Pipeliner, o model.Order) {
+	key := orderIDKey(o.OrderID)
+	pipe.ZRem(ctx, customerIndexKey(o.CustomerID), key)
+	pipe.ZRem(ctx, statusIndexKey(lifecycle.Current(o)), key)
+	pipe.ZRem(ctx, createdAtIndexKey, key)
+}
+
+// scoreBound đổi thời gian thành biên score cho ZRANGE BYSCORE
+func scoreBound(t time.Time) string {
+	return strconv.FormatInt(t.UnixMilli(), 10)
+}
+
+// afterPosition so sánh một key trong index created_at với vị trí pos theo thứ tự sắp xếp:
+// trả về true nếu key đứng sau pos. Cùng score thì Redis xếp theo thứ tự byte của member.
+func afterPosition(score float64, key string, pos *Position, desc bool) bool {
+	posScore, posKey := positionScore(pos), orderIDKey(pos.OrderID)
+	if score != posScore {
+		return (score > posScore && !desc) || (score < posScore && desc)
+	}
+	return (key > posKey && !desc) || (key < posKey && desc)
+}
+
+// positionScore trả về score của vị trí trong index created_at
+func positionScore(pos *Position) float64 {
+	return float64(pos.CreatedAt.UnixMilli())
+}
+
+// indexPosition đổi một phần tử của index created_at thành Position
+func indexPosition(z redis.Z) (*Position, error) {
+	key, _ := z.Member.(string)
+	id, err := strconv.ParseUint(strings.TrimPrefix(key, "order:"), 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid order key in index: %q", key)
+	}
+	return &Position{CreatedAt: time.UnixMilli(int64(z.Score)).UTC(), OrderID: id}, nil
+}
+
+/*
+findIndexedKeys trả về các key order (kèm score created_at) thỏa bộ lọc theo thứ tự created_at,
+bắt đầu ngay sau vị trí page.After và lấy tối đa Size phần tử.
+Giá trị bool cho biết còn dữ liệu sau trang này hay không.
+*/
+func (r *RedisRepo) findIndexedKeys(ctx context.Context, page FindAllPage) ([]redis.Z, bool, error) {
+	filter := page.Filter
+	desc := page.Sort == SortCreatedAtDesc
+
+	min, max := "-inf", "+inf"
+	if filter.CreatedFrom != nil {
+		min = scoreBound(*filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		max = scoreBound(*filter.CreatedTo)
+	}
+
+	// 1. Chọn index để đọc: index khách hàng thường nhỏ nhất nên được ưu tiên,
+	// lọc theo cả khách hàng và trạng thái thì còn phải kiểm tra index trạng thái
+	key, also := createdAtIndexKey, ""
+	switch {
+	case filter.CustomerID != "" && filter.Status != "":
+		key, also = customerIndexKey(filter.CustomerID), statusIndexKey(filter.Status)
+	case filter.CustomerID != "":
+		key = customerIndexKey(filter.CustomerID)
+	case filter.Status != "":
+		key = statusIndexKey(filter.Status)
+	}
+
+	// 2. Một index: đọc thẳng một đoạn của sorted set.
+	// Lấy dư 1 phần tử để biết còn trang sau hay không.
+	if also == "" {
+		keys, err := r.rangeAfter(ctx, key, min, max, page.After, desc, int64(page.Size)+1)
+		if err != nil {
+			return nil, false, err
+		}
+		if uint64(len(keys)) > page.Size {
+			return keys[:page.Size], true, nil
+		}
+		return keys, false, nil
+	}
+
+	// 3. Hai index: đọc dần index khách hàng theo từng lô, giữ các key cũng có trong
+	// index trạng thái cho đến khi đủ Size + 1 phần tử hoặc hết dữ liệu
+	const batchSize = 100
+	matched := make([]redis.Z, 0, page.Size+1)
+	after := page.After
+	for uint64(len(matched)) <= page.Size {
+		keys, err := r.rangeAfter(ctx, key, min, max, after, desc, batchSize)
+		if err != nil {
+			return nil, false, err
+		}
+		if len(keys) == 0 {
+			break
+		}
+
+		pipe := r.Client.Pipeline()
+		scores := make([]*redis.FloatCmd, len(keys))
+		for i, z := range keys {
+			scores[i] = pipe.ZScore(ctx, also, z.Member.(string))
+		}
+		if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+			return nil, false, fmt.Errorf("failed to check status index: %w", err)
+		}
+		for i, z := range keys {
+			if scores[i].Err() == nil {
+				matched = append(matched, z)
+			}
+		}
+
+		if len(keys) < batchSize {
+			break
+		}
+		if after, err = indexPosition(keys[len(keys)-1]); err != nil {
+			return nil, false, err
+		}
+	}
+
+	// 4. Cắt theo Size
+	if uint64(len(matched)) > page.Size {
+		return matched[:page.Size], true, nil
+	}
+	return matched, false, nil
+}
+
+/*
+rangeAfter đọc tối đa count phần tử của index key có score trong khoảng [min, max]
+theo thứ tự created_at (desc là giảm dần), bắt đầu ngay sau vị trí after.
+*/
+func (r *RedisRepo) rangeAfter(ctx context.Context, key, min, max string, after *Position, desc bool, count int64) ([]redis.Z, error) {
+	var offset int64
+	if after != nil {
+		// 1. Bắt đầu từ score của vị trí after (thu hẹp khoảng score),
+		// bỏ qua các key cùng score đứng trước hoặc tại vị trí đó
+		bound := strconv.FormatFloat(positionScore(after), 'f', -1, 64)
+		if desc {
+			max = minBound(max, bound)
+		} else {
+			min = maxBound(min, bound)
+		}
+		same, err := r.Client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: bound, Max: bound}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to range order index: %w", err)
+		}
+		for _, member := range same {
+			if !afterPosition(positionScore(after), member, after, desc) {
+				offset++
+			}
+		}
+	}
+
+	// 2. Đọc đoạn kế tiếp bằng ZRANGE BYSCORE
+	keys, err := r.Client.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+		Key:     key,
+		Start:   min,
+		Stop:    max,
+		ByScore: true,
+		Rev:     desc,
+		Offset:  offset,
+		Count:   count,
+	}).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to range order index: %w", err)
+	}
+	return keys, nil
+}
+
+// minBound và maxBound chọn biên score chặt hơn, "-inf"/"+inf" được ParseFloat hiểu đúng
+func minBound(a, b string) string {
+	x, _ := strconv.ParseFloat(a, 64)
+	y, _ := strconv.ParseFloat(b, 64)
+	if x < y {
+		return a
+	}
+	return b
+}
+
+func maxBound(a, b string) string {
+	x, _ := strconv.ParseFloat(a, 64)
+	y, _ := strconv.ParseFloat(b, 64)
+	if x > y {
+		return a
+	}
+	return b
+}
+
+// NeedsReindex cho biết index created_at có thiếu order so với set "orders" hay không
+// (ví dụ dữ liệu được tạo trước khi có index), hoặc còn index dạng set của phiên bản cũ
+func (r *RedisRepo) NeedsReindex(ctx context.Context) (bool, error) {
+	total, err := r.Client.SCard(ctx, "orders").Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to count orders: %w", err)
+	}
+	indexed, err := r.Client.ZCard(ctx, createdAtIndexKey).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to count created_at index: %w", err)
+	}
+	if indexed < total {
+		return true, nil
+	}
+
+	legacy, err := r.legacyIndexes(ctx)
+	if err != nil {
+		return false, err
+	}
+	return len(legacy) > 0, nil
+}
+
+// legacyIndexes trả về các key index khách hàng / trạng thái dạng set của phiên bản cũ
+// (kể cả index theo order_status tự do trước khi lifecycle.Current chuẩn hóa trạng thái)
+func (r *RedisRepo) legacyIndexes(ctx context.Context) ([]string, error) {
+	var legacy []string
+	for _, pattern := range legacyIndexPatterns {
+		iter := r.Client.Scan(ctx, 0, pattern, 100).Iterator()
+		for iter.Next(ctx) {
+			legacy = append(legacy, iter.Val())
+		}
+		if err := iter.Err(); err != nil {
+			return nil, fmt.Errorf("failed to scan legacy indexes: %w", err)
+		}
+	}
+	return legacy, nil
+}
+
+// Reindex dựng lại các index phụ từ set "orders", dùng cho dữ liệu cũ chưa có index.
+// Index dạng set của phiên bản cũ được xóa, order của nó được ghi lại vào sorted set
+// của khách hàng và của trạng thái đã chuẩn hóa.
+func (r *RedisRepo) Reindex(ctx context.Context) error {
+	const batchSize = 100
+
+	legacy, err := r.legacyIndexes(ctx)
+	if err != nil {
+		return err
+	}
+	if len(legacy) > 0 {
+		if err := r.Client.Del(ctx, legacy...).Err(); err != nil {
+			return fmt.Errorf("failed to delete legacy indexes: %w", err)
+		}
+	}
+
+	var cursor uint64
+	for {
+		// 1. Quét dần set "orders" để không chặn Redis quá lâu
+		keys, next, err := r.Client.SScan(ctx, "orders", cursor, "*", batchSize).Result()
+		if err != nil {
+			return fmt.Errorf("failed to scan orders: %w", err)
+		}
+
+		if len(keys) > 0 {
+			xs, err := r.Client.MGet(ctx, keys...).Result()
+			if err != nil {
+				return fmt.Errorf("failed to get orders: %w", err)
+			}
+
+			// 2. Ghi lại index cho từng order còn tồn tại
+			pipe := r.Client.TxPipeline()
+			for _, x := range xs {
+				value, ok := x.(string)
+				if !ok {
+					continue
+				}
+				var order model.Order
+				if err := json.Unmarshal([]byte(value), &order); err != nil {
+					return fmt.Errorf("failed to decode order json: %w", err)
+				}
+				addToIndexes(ctx, pipe, order)
+			}
+			if _, err := pipe.Exec(ctx); err != nil {
+				return fmt.Errorf("failed to exec: %w", err)
+			}
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return nil
+		}
+	}
+}
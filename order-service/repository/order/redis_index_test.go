@@ -0,0 +1,213 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	customerA = model.CustomerID("64b7f3a2c9e1d2a3b4c5d6e1")
+	customerB = model.CustomerID("64b7f3a2c9e1d2a3b4c5d6e2")
+)
+
+func newTestRedisRepo(t *testing.T) *RedisRepo {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &RedisRepo{Client: client}
+}
+
+// pageIDs đi hết các trang của FindAll và trả về order ID theo thứ tự nhận được
+func pageIDs(t *testing.T, repo OrderRepository, page FindAllPage) []uint64 {
+	t.Helper()
+	var ids []uint64
+	for pages := 0; ; pages++ {
+		if pages > 100 {
+			t.Fatalf("too many pages")
+		}
+		res, err := repo.FindAll(context.Background(), page)
+		if err != nil {
+			t.Fatalf("FindAll: %v", err)
+		}
+		if uint64(len(res.Orders)) > page.Size {
+			t.Fatalf("page has %d orders, size is %d", len(res.Orders), page.Size)
+		}
+		for _, o := range res.Orders {
+			ids = append(ids, o.OrderID)
+		}
+		if res.Next == nil {
+			return ids
+		}
+		page.After = res.Next
+	}
+}
+
+/*
+Phân trang theo index created_at của RedisRepo phải cho cùng kết quả với MemoryRepo.
+Nhiều đơn có cùng thời điểm tạo (cùng score) để kiểm tra phần bỏ qua các key cùng score
+đứng trước vị trí After. ID cùng số chữ số và thời điểm tạo tròn mili giây để thứ tự
+của hai backend giống nhau (Redis so sánh key theo byte, score theo mili giây).
+*/
+func TestRedisFindAllMatchesMemory(t *testing.T) {
+	ctx := context.Background()
+	redisRepo := newTestRedisRepo(t)
+	memoryRepo := NewMemoryRepo()
+
+	base := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	for i := uint64(0); i < 30; i++ {
+		createdAt := base.Add(time.Duration(i/5) * time.Millisecond) // 5 đơn mỗi thời điểm
+		o := model.Order{
+			OrderID:     100 + (i*7)%30, // ID không theo thứ tự chèn
+			CustomerID:  customerA,
+			OrderStatus: model.StatusPending,
+			Version:     1,
+			CreateAt:    &createdAt,
+		}
+		if i%2 == 1 {
+			o.CustomerID = customerB
+		}
+		if i%3 == 0 {
+			o.OrderStatus = model.StatusConfirmed
+		}
+		for _, repo := range []OrderRepository{redisRepo, memoryRepo} {
+			if err := repo.Insert(ctx, o); err != nil {
+				t.Fatalf("Insert %d: %v", o.OrderID, err)
+			}
+		}
+	}
+
+	from := base.Add(time.Millisecond)
+	to := base.Add(4 * time.Millisecond)
+	filters := []struct {
+		name   string
+		filter OrderFilter
+	}{
+		{"none", OrderFilter{}},
+		{"customer", OrderFilter{CustomerID: customerB}},
+		{"status", OrderFilter{Status: model.StatusConfirmed}},
+		{"customer and status", OrderFilter{CustomerID: customerA, Status: model.StatusPending}},
+		{"created range", OrderFilter{CreatedFrom: &from, CreatedTo: &to}},
+		{"customer and created range", OrderFilter{CustomerID: customerA, CreatedFrom: &from, CreatedTo: &to}},
+	}
+	for _, f := range filters {
+		for _, sort := range []SortOrder{SortCreatedAtAsc, SortCreatedAtDesc} {
+			for _, size := range []uint64{1, 2, 4, 5, 7, 100} {
+				t.Run(fmt.Sprintf("%s/%s/size=%d", f.name, sort, size), func(t *testing.T) {
+					page := FindAllPage{Size: size, Filter: f.filter, Sort: sort}
+					want := pageIDs(t, memoryRepo, page)
+					got := pageIDs(t, redisRepo, page)
+					if len(want) == 0 {
+						t.Fatalf("filter matches no orders")
+					}
+					if fmt.Sprint(got) != fmt.Sprint(want) {
+						t.Fatalf("redis pages = %v, want %v", got, want)
+					}
+				})
+			}
+		}
+	}
+}
+
+// Đơn bị xóa giữa hai trang không làm trang sau lặp lại hay bỏ sót đơn khác
+func TestRedisFindAllAfterDeletedPosition(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRedisRepo(t)
+
+	createdAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	for id := uint64(100); id < 106; id++ {
+		o := model.Order{OrderID: id, CustomerID: customerA, OrderStatus: model.StatusPending, Version: 1, CreateAt: &createdAt}
+		if err := repo.Insert(ctx, o); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+	}
+
+	first, err := repo.FindAll(ctx, FindAllPage{Size: 3})
+	if err != nil {
+		t.Fatalf("FindAll: %v", err)
+	}
+	if first.Next == nil || first.Next.OrderID != 102 {
+		t.Fatalf("first page next = %+v, want order 102", first.Next)
+	}
+	if err := repo.DeleteByID(ctx, 102, "test"); err != nil {
+		t.Fatalf("DeleteByID: %v", err)
+	}
+
+	got := pageIDs(t, repo, FindAllPage{Size: 3, After: first.Next})
+	if fmt.Sprint(got) != fmt.Sprint([]uint64{103, 104, 105}) {
+		t.Fatalf("second page = %v, want [103 104 105]", got)
+	}
+}
+
+// Lọc theo cả khách hàng và trạng thái đọc index khách hàng theo lô,
+// các đơn khớp nằm rải rác qua nhiều lô vẫn được phân trang đúng
+func TestRedisFindAllAcrossBatches(t *testing.T) {
+	ctx := context.Background()
+	redisRepo := newTestRedisRepo(t)
+	memoryRepo := NewMemoryRepo()
+
+	base := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	for i := uint64(0); i < 250; i++ {
+		createdAt := base.Add(time.Duration(i) * time.Millisecond)
+		o := model.Order{OrderID: 1000 + i, CustomerID: customerA, OrderStatus: model.StatusPending, Version: 1, CreateAt: &createdAt}
+		if i%40 == 39 {
+			o.OrderStatus = model.StatusConfirmed
+		}
+		for _, repo := range []OrderRepository{redisRepo, memoryRepo} {
+			if err := repo.Insert(ctx, o); err != nil {
+				t.Fatalf("Insert %d: %v", o.OrderID, err)
+			}
+		}
+	}
+
+	for _, sort := range []SortOrder{SortCreatedAtAsc, SortCreatedAtDesc} {
+		page := FindAllPage{Size: 2, Sort: sort, Filter: OrderFilter{CustomerID: customerA, Status: model.StatusConfirmed}}
+		want := pageIDs(t, memoryRepo, page)
+		got := pageIDs(t, redisRepo, page)
+		if len(want) != 6 || fmt.Sprint(got) != fmt.Sprint(want) {
+			t.Fatalf("%s: redis pages = %v, want %v", sort, got, want)
+		}
+	}
+}
+
+// Index dạng set của phiên bản cũ (kể cả theo order_status tự do) được Reindex
+// thay bằng sorted set của khách hàng và của trạng thái đã chuẩn hóa
+func TestRedisReindexLegacyStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRedisRepo(t)
+
+	createdAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	legacy := model.Order{OrderID: 100, CustomerID: customerA, OrderStatus: "Processing", Version: 1, CreateAt: &createdAt}
+	if err := repo.Insert(ctx, legacy); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	// Phiên bản cũ ghi index dạng set, index trạng thái theo chuỗi gốc
+	repo.Client.Del(ctx, customerIndexKey(customerA), statusIndexKey(model.StatusConfirmed))
+	repo.Client.SAdd(ctx, "orders:customer:"+customerA.String(), orderIDKey(100))
+	repo.Client.SAdd(ctx, "orders:status:Processing", orderIDKey(100))
+
+	needed, err := repo.NeedsReindex(ctx)
+	if err != nil || !needed {
+		t.Fatalf("NeedsReindex = %v, %v, want true", needed, err)
+	}
+	if err := repo.Reindex(ctx); err != nil {
+		t.Fatalf("Reindex: %v", err)
+	}
+	if needed, err := repo.NeedsReindex(ctx); err != nil || needed {
+		t.Fatalf("NeedsReindex after Reindex = %v, %v, want false", needed, err)
+	}
+
+	got := pageIDs(t, repo, FindAllPage{Size: 10, Filter: OrderFilter{CustomerID: customerA, Status: model.StatusConfirmed}})
+	if fmt.Sprint(got) != "[100]" {
+		t.Fatalf("confirmed orders = %v, want [100]", got)
+	}
+	if n, _ := repo.Client.Exists(ctx, "orders:customer:"+customerA.String(), "orders:status:Processing").Result(); n != 0 {
+		t.Fatalf("%d legacy indexes left after Reindex", n)
+	}
+}
@@ -0,0 +1,126 @@
+package order
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/events"
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/redis/go-redis/v9"
+)
+
+/*
+FindCompletedBefore quét index "orders:by_status:completed" bằng ZSCAN và trả về
+các order hoàn tất trước thời điểm before, dùng để chuyển order cũ sang lưu trữ lạnh.
+Order không có completed_at và không có lịch sử chuyển sang completed được bỏ qua.
+cursor bắt đầu từ 0, cursor trả về bằng 0 là đã quét hết. Một lần gọi có thể
+không trả về order nào dù chưa quét hết.
+*/
+func (r *RedisRepo) FindCompletedBefore(ctx context.Context, before time.Time, cursor uint64, count int64) ([]model.Order, uint64, error) {
+	// 1. Quét một đoạn của index trạng thái completed, ZSCAN trả về xen kẽ key và score
+	pairs, next, err := r.Client.ZScan(ctx, statusIndexKey(model.StatusCompleted), cursor, "*", count).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to scan completed orders: %w", err)
+	}
+	keys := make([]string, 0, len(pairs)/2)
+	for i := 0; i < len(pairs); i += 2 {
+		keys = append(keys, pairs[i])
+	}
+	if len(keys) == 0 {
+		return nil, next, nil
+	}
+
+	// 2. Đọc các order, order vừa bị xóa (MGET trả về nil) thì bỏ qua
+	xs, err := r.Client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get orders: %w", err)
+	}
+
+	orders := make([]model.Order, 0, len(xs))
+	for _, x := range xs {
+		value, ok := x.(string)
+		if !ok {
+			continue
+		}
+		var order model.Order
+		if err := json.Unmarshal([]byte(value), &order); err != nil {
+			return nil, 0, fmt.Errorf("failed to decode order json: %w", err)
+		}
+
+		// 3. Chỉ lấy order vẫn đang completed (kể cả order cũ có order_status tự do như "done")
+		// và đã hoàn tất đủ lâu
+		if lifecycle.Current(order) != model.StatusCompleted {
+			continue
+		}
+		completedAt, ok, err := r.completedAt(ctx, order)
+		if err != nil {
+			return nil, 0, err
+		}
+		if !ok || !completedAt.Before(before) {
+			continue
+		}
+		orders = append(orders, order)
+	}
+	return orders, next, nil
+}
+
+// completedAt trả về thời điểm order hoàn tất: completed_at, order cũ không có
+// completed_at thì lấy bản ghi lịch sử chuyển sang completed gần nhất.
+// false nếu không biết order hoàn tất lúc nào.
+func (r *RedisRepo) completedAt(ctx context.Context, order model.Order) (time.Time, bool, error) {
+	if order.CompletedAt != nil {
+		return time.Time(*order.CompletedAt), true, nil
+	}
+	history, err := r.FindHistory(ctx, order.OrderID)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].To == model.StatusCompleted {
+			return history[i].ChangedAt, true, nil
+		}
+	}
+	return time.Time{}, false, nil
+}
+
+/*
+Evict xóa hẳn khỏi Redis một order đã được ghi sang lưu trữ lạnh:
+key order, lịch sử trạng thái, set "orders" và các index phụ, kèm event order.archived.
+Chỉ xóa nếu order chưa bị sửa kể từ lúc đọc (cùng Version), ngược lại trả về ErrVersionMismatch.
+*/
+func (r *RedisRepo) Evict(ctx context.Context, order model.Order) error {
+	key := orderIDKey(order.OrderID)
+
+	err := r.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// 1. Đọc lại order để so version
+		current, err := getOrder(ctx, tx, key)
+		if err != nil {
+			return err
+		}
+		if current.Version != order.Version {
+			return ErrVersionMismatch
+		}
+
+		// 2. Xóa order, lịch sử và index trong một transaction
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, key, historyKey(order.OrderID))
+			pipe.SRem(ctx, "orders", key)
+			removeFromIndexes(ctx, pipe, current)
+			return events.Append(ctx, pipe, r.stream(), r.MaxLen, events.New(events.TypeOrderArchived, current, time.Now()))
+		})
+		return err
+	}, key)
+
+	if errors.Is(err, ErrNotExist) || errors.Is(err, ErrVersionMismatch) {
+		return err
+	} else if errors.Is(err, redis.TxFailedErr) {
+		return ErrVersionMismatch // order bị request khác sửa trong lúc đang xóa
+	} else if err != nil {
+		return fmt.Errorf("failed to exec: %w", err)
+	}
+	return nil
+}
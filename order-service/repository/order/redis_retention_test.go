@@ -0,0 +1,76 @@
+package order
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/util"
+)
+
+// Order cũ có order_status tự do ("done", "finished") vẫn được lưu trữ,
+// thời điểm hoàn tất lấy từ completed_at hoặc lịch sử
+func TestRedisFindCompletedBefore(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRedisRepo(t)
+	cutoff := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	old := util.CustomTime(cutoff.Add(-time.Hour))
+	recent := util.CustomTime(cutoff.Add(time.Hour))
+
+	tests := []struct {
+		id      uint64
+		status  model.Status
+		done    *util.CustomTime // completed_at
+		history *time.Time       // thời điểm chuyển sang completed trong lịch sử
+		want    bool
+	}{
+		{1, model.StatusCompleted, &old, nil, true},
+		{2, model.StatusCompleted, &recent, nil, false},
+		{3, "done", nil, ptr(cutoff.Add(-time.Hour)), true},
+		{4, "finished", &old, nil, true},
+		{5, "Complete", nil, ptr(cutoff.Add(time.Hour)), false},
+		{6, "done", nil, nil, false}, // không biết hoàn tất lúc nào
+		{7, model.StatusDelivered, nil, nil, false},
+	}
+	var want []uint64
+	for _, tt := range tests {
+		createdAt := cutoff.Add(-48 * time.Hour)
+		o := model.Order{OrderID: tt.id, CustomerID: customerA, OrderStatus: tt.status, CompletedAt: tt.done, Version: 1, CreateAt: &createdAt}
+		var changes []model.StatusChange
+		if tt.history != nil {
+			changes = append(changes, model.StatusChange{OrderID: tt.id, From: model.StatusDelivered, To: model.StatusCompleted, Actor: "legacy", ChangedAt: *tt.history})
+		}
+		if err := repo.Insert(ctx, o, changes...); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+		if tt.want {
+			want = append(want, tt.id)
+		}
+	}
+
+	var got []uint64
+	var cursor uint64
+	for {
+		orders, next, err := repo.FindCompletedBefore(ctx, cutoff, cursor, 2)
+		if err != nil {
+			t.Fatalf("FindCompletedBefore: %v", err)
+		}
+		for _, o := range orders {
+			got = append(got, o.OrderID)
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i] < got[j] })
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Fatalf("completed before cutoff = %v, want %v", got, want)
+	}
+}
+
+func ptr(t time.Time) *time.Time {
+	return &t
+}
@@ -0,0 +1,127 @@
+package order
+
+import (
+	"context"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+// OrderRepository là interface chung cho mọi backend lưu trữ đơn hàng.
+// Handler chỉ làm việc với interface này nên có thể thay Redis bằng
+// bộ nhớ (khi test) hoặc một database SQL mà không phải sửa handler.
+//
+// Insert không bao giờ ghi đè order cũ: nếu ID đã tồn tại thì trả về ErrAlreadyExists.
+// Các bản ghi lịch sử truyền vào (bước tạo đơn "" → pending) được ghi cùng lúc với order.
+// Update ghi order cùng với các bản ghi lịch sử trạng thái (nếu có) trong một lần,
+// để lịch sử luôn khớp với dữ liệu order. order.Version là version mới sau khi ghi:
+// Update chỉ thành công nếu version đang lưu bằng order.Version - 1,
+// ngược lại trả về ErrVersionMismatch (optimistic concurrency).
+//
+// DeleteByID là xóa mềm: order được đánh dấu deleted_at/deleted_by, tăng version,
+// bị loại khỏi FindByID/FindAll/Update nhưng vẫn được giữ trong vùng lưu trữ (archive)
+// cùng với lịch sử trạng thái để phục vụ đối soát. FindDeletedByID đọc order trong
+// vùng lưu trữ, Restore đưa order trở lại hoạt động.
+//
+// InsertMany lưu nhiều order trong một lần gọi (dùng khi nhập dữ liệu hàng loạt).
+// Phần tử thứ i của slice lỗi trả về là lỗi của orders[i] (ErrAlreadyExists hoặc nil),
+// error cuối cùng khác nil nghĩa là cả lô không được ghi.
+// Exists cho biết từng ID đã được dùng chưa (kể cả order đã xóa mềm), tức là Insert
+// với ID đó sẽ trả về ErrAlreadyExists; dùng để kiểm tra trước khi nhập (dry run).
+type OrderRepository interface {
+	Insert(ctx context.Context, order model.Order, changes ...model.StatusChange) error
+	InsertMany(ctx context.Context, orders []model.Order) ([]error, error)
+	Exists(ctx context.Context, ids []uint64) ([]bool, error)
+	FindByID(ctx context.Context, id uint64) (model.Order, error)
+	Update(ctx context.Context, order model.Order, changes ...model.StatusChange) error
+	DeleteByID(ctx context.Context, id uint64, deletedBy string) error
+	FindDeletedByID(ctx context.Context, id uint64) (model.Order, error)
+	Restore(ctx context.Context, id uint64) (model.Order, error)
+	FindAll(ctx context.Context, page FindAllPage) (FindResult, error)
+	FindHistory(ctx context.Context, id uint64) ([]model.StatusChange, error)
+}
+
+/*
+ArchiveRewriter là backend lưu bền cho phép sửa customer_id của order đã xóa mềm,
+chỉ dùng cho công cụ chuyển dữ liệu (cmd/migrate-customer-ids) để Restore
+không đưa customer_id cũ trở lại.
+
+RewriteDeletedCustomerIDs duyệt mọi order trong vùng lưu trữ và gọi rewrite với từng order,
+rewrite trả về ID mới và true thì order được ghi lại với customer_id mới và version tăng thêm 1.
+Trả về số order đã ghi lại, dừng ở lỗi đầu tiên.
+*/
+type ArchiveRewriter interface {
+	RewriteDeletedCustomerIDs(ctx context.Context, rewrite func(o model.Order) (model.CustomerID, bool)) (int, error)
+}
+
+// Đảm bảo các backend luôn thỏa mãn interface (lỗi sẽ báo lúc biên dịch)
+var (
+	_ OrderRepository = (*RedisRepo)(nil)
+	_ OrderRepository = (*MemoryRepo)(nil)
+	_ OrderRepository = (*SQLRepo)(nil)
+
+	_ ArchiveRewriter = (*RedisRepo)(nil)
+	_ ArchiveRewriter = (*SQLRepo)(nil)
+)
+
+// SortOrder là thứ tự sắp xếp khi liệt kê đơn hàng
+type SortOrder string
+
+const (
+	SortCreatedAtAsc  SortOrder = "created_at"  // cũ nhất trước (mặc định)
+	SortCreatedAtDesc SortOrder = "-created_at" // mới nhất trước
+)
+
+// OrderFilter là các điều kiện lọc khi liệt kê đơn hàng, trường rỗng nghĩa là không lọc
+type OrderFilter struct {
+	CustomerID  model.CustomerID // chỉ lấy đơn của khách hàng này
+	Status      model.Status     // chỉ lấy đơn đang ở trạng thái này
+	CreatedFrom *time.Time       // tạo từ thời điểm này (bao gồm)
+	CreatedTo   *time.Time       // tạo trước hoặc đúng thời điểm này
+}
+
+// Match kiểm tra order có thỏa bộ lọc hay không
+func (f OrderFilter) Match(o model.Order) bool {
+	if f.CustomerID != "" && o.CustomerID != f.CustomerID {
+		return false
+	}
+	if f.Status != "" && lifecycle.Current(o) != f.Status {
+		return false
+	}
+	if f.CreatedFrom != nil && (o.CreateAt == nil || o.CreateAt.Before(*f.CreatedFrom)) {
+		return false
+	}
+	if f.CreatedTo != nil && (o.CreateAt == nil || o.CreateAt.After(*f.CreatedTo)) {
+		return false
+	}
+	return true
+}
+
+/*
+Position là vị trí của một order trong thứ tự của FindAll: thời điểm tạo rồi đến order ID.
+Trang sau bắt đầu ngay sau Position của phần tử cuối trang trước (keyset pagination)
+nên order được thêm hay xóa giữa hai lần gọi không làm trang sau bị lặp hoặc bỏ sót phần tử.
+*/
+type Position struct {
+	CreatedAt time.Time
+	OrderID   uint64
+}
+
+/*
+	Định nghĩa thông tin phân trang: lấy bao nhiêu phần tử (Size),
+
+bắt đầu sau vị trí nào (After), lọc theo điều kiện nào và sắp xếp ra sao
+*/
+type FindAllPage struct {
+	Size   uint64      // Số lượng kết quả muốn lấy mỗi lần
+	After  *Position   // Chỉ lấy các order đứng sau vị trí này, nil là từ đầu
+	Filter OrderFilter // Điều kiện lọc
+	Sort   SortOrder   // Thứ tự sắp xếp, để trống là SortCreatedAtAsc
+}
+
+// là kết quả trả về khi truy vấn: danh sách đơn hàng + vị trí để lấy trang tiếp theo
+type FindResult struct {
+	Orders []model.Order // Danh sách đơn hàng lấy được
+	Next   *Position     // Vị trí cuối trang, truyền vào After để lấy trang tiếp theo, nil nếu đã hết
+}
@@ -0,0 +1,869 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/util"
+	"github.com/google/uuid"
+)
+
+// SQLRepo lưu đơn hàng vào một database quan hệ thông qua database/sql.
+// Câu lệnh dùng placeholder "?" và kiểu dữ liệu tương thích với SQLite.
+type SQLRepo struct {
+	DB *sql.DB
+}
+
+/*
+Các bước migrate schema, chạy theo thứ tự và chỉ chạy một lần.
+Bảng schema_migrations lưu số bước đã chạy, muốn đổi schema thì
+thêm bước mới vào cuối danh sách, không sửa các bước cũ.
+*/
+var sqlMigrations = []string{
+	// 1. Schema gồm 2 bảng: orders và line_items (mỗi dòng là một mặt hàng của order)
+	`CREATE TABLE IF NOT EXISTS orders (
+		order_id     INTEGER PRIMARY KEY,
+		customer_id  TEXT    NOT NULL,
+		order_status TEXT    NOT NULL DEFAULT '',
+		created_at   TEXT,
+		shipped_at   TEXT,
+		completed_at TEXT
+	);
+	CREATE TABLE IF NOT EXISTS line_items (
+		order_id INTEGER NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+		position INTEGER NOT NULL,
+		item_id  TEXT    NOT NULL,
+		quantity INTEGER NOT NULL,
+		price    INTEGER NOT NULL,
+		PRIMARY KEY (order_id, position)
+	)`,
+
+	// 2. Mốc thời gian cho từng bước trong vòng đời đơn hàng
+	`ALTER TABLE orders ADD COLUMN confirmed_at TEXT;
+	ALTER TABLE orders ADD COLUMN paid_at TEXT;
+	ALTER TABLE orders ADD COLUMN delivered_at TEXT;
+	ALTER TABLE orders ADD COLUMN cancelled_at TEXT;
+	ALTER TABLE orders ADD COLUMN refunded_at TEXT`,
+
+	// 3. Lịch sử thay đổi trạng thái, chỉ ghi thêm
+	`CREATE TABLE IF NOT EXISTS order_history (
+		id          INTEGER PRIMARY KEY AUTOINCREMENT,
+		order_id    INTEGER NOT NULL,
+		from_status TEXT    NOT NULL,
+		to_status   TEXT    NOT NULL,
+		actor       TEXT    NOT NULL,
+		reason      TEXT    NOT NULL DEFAULT '',
+		changed_at  TEXT    NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS order_history_order_id ON order_history (order_id)`,
+
+	// 4. Index phục vụ lọc và sắp xếp khi liệt kê đơn hàng
+	`CREATE INDEX IF NOT EXISTS orders_customer_id ON orders (customer_id, created_at);
+	CREATE INDEX IF NOT EXISTS orders_status ON orders (order_status, created_at);
+	CREATE INDEX IF NOT EXISTS orders_created_at ON orders (created_at)`,
+
+	// 5. Version của order phục vụ optimistic concurrency
+	`ALTER TABLE orders ADD COLUMN version INTEGER NOT NULL DEFAULT 0`,
+
+	// 6. Xóa mềm: order có deleted_at khác NULL nằm trong vùng lưu trữ
+	`ALTER TABLE orders ADD COLUMN deleted_at TEXT;
+	ALTER TABLE orders ADD COLUMN deleted_by TEXT NOT NULL DEFAULT ''`,
+
+	// 7. Khu vực giao hàng và các khoản tiền đã tính (minor units), lưu lại vì
+	// thuế suất có thể thay đổi sau khi đơn được tạo
+	`ALTER TABLE orders ADD COLUMN region TEXT NOT NULL DEFAULT '';
+	ALTER TABLE orders ADD COLUMN subtotal INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN tax INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN shipping INTEGER NOT NULL DEFAULT 0;
+	ALTER TABLE orders ADD COLUMN grand_total INTEGER NOT NULL DEFAULT 0`,
+
+	// 8. Tiền tệ của order (giá line item cùng tiền tệ với order nên không cần cột riêng)
+	// và tổng tiền quy đổi sang tiền tệ gốc
+	`ALTER TABLE orders ADD COLUMN currency TEXT NOT NULL DEFAULT '';
+	ALTER TABLE orders ADD COLUMN base_total INTEGER;
+	ALTER TABLE orders ADD COLUMN base_currency TEXT NOT NULL DEFAULT ''`,
+
+	// 9. SKU và tên mặt hàng lấy từ catalog-service lúc đặt
+	`ALTER TABLE line_items ADD COLUMN sku TEXT NOT NULL DEFAULT '';
+	ALTER TABLE line_items ADD COLUMN name TEXT NOT NULL DEFAULT ''`,
+
+	// 10. Các lần thanh toán của order, ghi lại toàn bộ mỗi lần cập nhật giống line_items
+	`CREATE TABLE IF NOT EXISTS payments (
+		order_id   INTEGER NOT NULL REFERENCES orders(order_id) ON DELETE CASCADE,
+		position   INTEGER NOT NULL,
+		intent_id  TEXT    NOT NULL DEFAULT '',
+		provider   TEXT    NOT NULL,
+		amount     INTEGER NOT NULL,
+		currency   TEXT    NOT NULL,
+		captured   INTEGER NOT NULL DEFAULT 0,
+		refunded   INTEGER NOT NULL DEFAULT 0,
+		status     TEXT    NOT NULL,
+		error      TEXT    NOT NULL DEFAULT '',
+		created_at TEXT    NOT NULL,
+		updated_at TEXT    NOT NULL,
+		PRIMARY KEY (order_id, position)
+	)`,
+
+	// 11. Mốc thời gian ghi trước khi có sqlTimeLayout (RFC3339Nano bỏ số 0 cuối phần lẻ giây)
+	// được viết lại đủ 9 chữ số để so sánh chuỗi đúng thứ tự thời gian
+	padTimes("orders", "created_at", "shipped_at", "completed_at", "confirmed_at", "paid_at",
+		"delivered_at", "cancelled_at", "refunded_at", "deleted_at") + ";" +
+		padTimes("order_history", "changed_at"),
+
+	// 12. order_status tự do của order cũ được chuẩn hóa như lifecycle.Current
+	// để lọc theo trạng thái ra cùng kết quả với index trạng thái của Redis
+	normalizeStatuses(),
+}
+
+/*
+normalizeStatuses tạo lệnh UPDATE đưa order_status không hợp lệ về trạng thái chuẩn
+theo cùng thứ tự với lifecycle.Current:
+ 1. Có completed_at thì là completed, có shipped_at thì là shipped
+ 2. Chuẩn hóa chuỗi (bỏ khoảng trắng hai đầu, chữ thường, "-"/khoảng trắng thành "_")
+    rồi so với trạng thái hợp lệ và bảng lifecycle.LegacyStatuses
+ 3. Không nhận ra thì là pending
+*/
+func normalizeStatuses() string {
+	const name = `replace(replace(lower(trim(order_status, ' ' || char(9) || char(10) || char(13))), '-', '_'), ' ', '_')`
+
+	valid := make([]string, 0, len(model.Statuses))
+	for _, status := range model.Statuses {
+		valid = append(valid, fmt.Sprintf("'%s'", status))
+	}
+	validList := strings.Join(valid, ", ")
+
+	legacy := lifecycle.LegacyStatuses()
+	names := make([]string, 0, len(legacy))
+	for n := range legacy {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(`UPDATE orders SET order_status = CASE`)
+	b.WriteString(fmt.Sprintf(` WHEN completed_at IS NOT NULL THEN '%s'`, model.StatusCompleted))
+	b.WriteString(fmt.Sprintf(` WHEN shipped_at IS NOT NULL THEN '%s'`, model.StatusShipped))
+	b.WriteString(fmt.Sprintf(` WHEN %s IN (%s) THEN %s`, name, validList, name))
+	for _, n := range names {
+		b.WriteString(fmt.Sprintf(` WHEN %s = '%s' THEN '%s'`, name, n, legacy[n]))
+	}
+	b.WriteString(fmt.Sprintf(` ELSE '%s' END WHERE order_status NOT IN (%s)`, model.StatusPending, validList))
+	return b.String()
+}
+
+/*
+padTimes tạo lệnh UPDATE viết lại các cột thời gian dạng RFC3339Nano (UTC) sang sqlTimeLayout:
+
+	2025-01-31T14:05:00Z      → 2025-01-31T14:05:00.000000000Z
+	2025-01-31T14:05:00.12Z   → 2025-01-31T14:05:00.120000000Z
+
+Giá trị đã đúng layout (30 ký tự) hoặc NULL được giữ nguyên.
+*/
+func padTimes(table string, columns ...string) string {
+	stmts := make([]string, 0, len(columns))
+	for _, c := range columns {
+		stmts = append(stmts, fmt.Sprintf(
+			`UPDATE %[1]s SET %[2]s = substr(%[2]s, 1, 19) || '.' || `+
+				`substr(CASE WHEN length(%[2]s) > 20 THEN substr(%[2]s, 21, length(%[2]s) - 21) ELSE '' END || '000000000', 1, 9) || 'Z' `+
+				`WHERE %[2]s LIKE '%%Z' AND length(%[2]s) <> 30`,
+			table, c))
+	}
+	return strings.Join(stmts, ";")
+}
+
+// Migrate chạy các bước migrate chưa được áp dụng, gọi một lần lúc khởi động service
+func (r *SQLRepo) Migrate(ctx context.Context) error {
+	// 1. Tạo bảng lưu version của schema
+	_, err := r.DB.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER NOT NULL)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	// 2. Lấy version hiện tại (0 nếu database mới)
+	var version int
+	err = r.DB.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&version)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+
+	// 3. Chạy từng bước còn thiếu trong một transaction riêng
+	for i := version; i < len(sqlMigrations); i++ {
+		tx, err := r.DB.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to begin tx: %w", err)
+		}
+		for _, stmt := range strings.Split(sqlMigrations[i], ";") {
+			if strings.TrimSpace(stmt) == "" {
+				continue
+			}
+			if _, err := tx.ExecContext(ctx, stmt); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to migrate schema to version %d: %w", i+1, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, `INSERT INTO schema_migrations (version) VALUES (?)`, i+1); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record schema version: %w", err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration: %w", err)
+		}
+	}
+	return nil
+}
+
+// SQLite chỉ có số nguyên 64 bit có dấu, nên order ID (uint64) được lưu
+// bằng cách giữ nguyên bit và đổi kiểu sang int64, khi đọc ra thì đổi ngược lại.
+func sqlOrderID(id uint64) int64 {
+	return int64(id)
+}
+
+// Thời gian được lưu dạng chuỗi RFC3339 (UTC, luôn đủ 9 chữ số phần lẻ giây)
+// để không phụ thuộc driver và so sánh chuỗi cũng đúng thứ tự thời gian
+const sqlTimeLayout = "2006-01-02T15:04:05.000000000Z07:00"
+
+func formatTime(t *time.Time) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: t.UTC().Format(sqlTimeLayout), Valid: true}
+}
+
+func formatCustomTime(t *util.CustomTime) sql.NullString {
+	if t == nil {
+		return sql.NullString{}
+	}
+	tt := time.Time(*t)
+	return formatTime(&tt)
+}
+
+func parseTime(s sql.NullString) (*time.Time, error) {
+	if !s.Valid {
+		return nil, nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s.String)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func parseCustomTime(s sql.NullString) (*util.CustomTime, error) {
+	t, err := parseTime(s)
+	if err != nil || t == nil {
+		return nil, err
+	}
+	ct := util.CustomTime(*t)
+	return &ct, nil
+}
+
+// insertLineItems ghi toàn bộ line item của order trong cùng transaction
+func insertLineItems(ctx context.Context, tx *sql.Tx, order model.Order) error {
+	for i, item := range order.LineItems {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO line_items (order_id, position, item_id, quantity, price, sku, name) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			sqlOrderID(order.OrderID), i, item.ItemID.String(), item.Quantity, int64(item.Price.Amount), item.SKU, item.Name,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert line item: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertPayments ghi toàn bộ lần thanh toán của order trong cùng transaction
+func insertPayments(ctx context.Context, tx *sql.Tx, order model.Order) error {
+	for i, p := range order.Payments {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO payments (order_id, position, intent_id, provider, amount, currency, captured, refunded, status, error, created_at, updated_at) `+
+				`VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			sqlOrderID(order.OrderID), i, p.IntentID, p.Provider, int64(p.Amount.Amount), p.Amount.Currency,
+			int64(p.Captured), int64(p.Refunded), p.Status, p.Error,
+			p.CreatedAt.UTC().Format(sqlTimeLayout), p.UpdatedAt.UTC().Format(sqlTimeLayout),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert payment: %w", err)
+		}
+	}
+	return nil
+}
+
+// Insert lưu order, các line item và bản ghi lịch sử của nó trong một transaction,
+// trả về ErrAlreadyExists nếu order ID đã có trong bảng
+func (r *SQLRepo) Insert(ctx context.Context, order model.Order, changes ...model.StatusChange) error {
+	// 1. Mở transaction để order và line item được ghi cùng lúc
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	// 2. Ghi order, line item, các lần thanh toán và lịch sử
+	if err := insertOrder(ctx, tx, order); err != nil {
+		return err
+	}
+	if err := insertHistory(ctx, tx, changes); err != nil {
+		return err
+	}
+
+	// 3. Commit transaction
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// InsertMany lưu cả lô order trong một transaction, order có ID đã tồn tại
+// được bỏ qua và báo ErrAlreadyExists ở vị trí tương ứng
+func (r *SQLRepo) InsertMany(ctx context.Context, orders []model.Order) ([]error, error) {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	errs := make([]error, len(orders))
+	for i, order := range orders {
+		err := insertOrder(ctx, tx, order)
+		if errors.Is(err, ErrAlreadyExists) {
+			errs[i] = err
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit: %w", err)
+	}
+	return errs, nil
+}
+
+// Exists kiểm tra từng ID trong bảng orders, bao gồm cả order đã xóa mềm
+func (r *SQLRepo) Exists(ctx context.Context, ids []uint64) ([]bool, error) {
+	exists := make([]bool, len(ids))
+	for i, id := range ids {
+		var exist int
+		err := r.DB.QueryRowContext(ctx, `SELECT 1 FROM orders WHERE order_id = ?`, sqlOrderID(id)).Scan(&exist)
+		if err == nil {
+			exists[i] = true
+		} else if !errors.Is(err, sql.ErrNoRows) {
+			return nil, fmt.Errorf("failed to check order id: %w", err)
+		}
+	}
+	return exists, nil
+}
+
+// insertHistory ghi thêm các bản ghi lịch sử trạng thái trong transaction tx
+func insertHistory(ctx context.Context, tx *sql.Tx, changes []model.StatusChange) error {
+	for _, change := range changes {
+		_, err := tx.ExecContext(ctx,
+			`INSERT INTO order_history (order_id, from_status, to_status, actor, reason, changed_at) VALUES (?, ?, ?, ?, ?, ?)`,
+			sqlOrderID(change.OrderID), change.From, change.To, change.Actor, change.Reason,
+			change.ChangedAt.UTC().Format(sqlTimeLayout),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert status change: %w", err)
+		}
+	}
+	return nil
+}
+
+// insertOrder ghi một order cùng line item và các lần thanh toán trong transaction tx
+func insertOrder(ctx context.Context, tx *sql.Tx, order model.Order) error {
+	// 1. Kiểm tra ID đã tồn tại chưa, không dựa vào mã lỗi riêng của từng driver
+	var exist int
+	err := tx.QueryRowContext(ctx, `SELECT 1 FROM orders WHERE order_id = ?`, sqlOrderID(order.OrderID)).Scan(&exist)
+	if err == nil {
+		return ErrAlreadyExists
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to check order id: %w", err)
+	}
+
+	// 2. Ghi thông tin chính của order
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO orders (order_id, customer_id, order_status, created_at, version, `+timestampColumns+`, `+amountColumns+`) `+
+			`VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		append(append([]any{sqlOrderID(order.OrderID), order.CustomerID.String(), order.OrderStatus, formatTime(order.CreateAt), order.Version},
+			timestampValues(order)...), amountValues(order)...)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert order: %w", err)
+	}
+
+	// 3. Ghi các line item và các lần thanh toán
+	if err := insertLineItems(ctx, tx, order); err != nil {
+		return err
+	}
+	return insertPayments(ctx, tx, order)
+}
+
+// Khu vực giao hàng và các khoản tiền của order, theo đúng thứ tự của amountValues.
+// Số tiền uint64 được lưu bằng cách giữ nguyên bit giống sqlOrderID,
+// tiền tệ chỉ lưu một lần ở cột currency.
+const amountColumns = `region, currency, subtotal, tax, shipping, grand_total, base_total, base_currency`
+
+func amountValues(order model.Order) []any {
+	var baseTotal sql.NullInt64
+	var baseCurrency string
+	if order.BaseTotal != nil {
+		baseTotal = sql.NullInt64{Int64: int64(order.BaseTotal.Amount), Valid: true}
+		baseCurrency = order.BaseTotal.Currency
+	}
+	return []any{
+		order.Region,
+		order.Currency,
+		int64(order.Subtotal.Amount),
+		int64(order.Tax.Amount),
+		int64(order.Shipping.Amount),
+		int64(order.GrandTotal.Amount),
+		baseTotal,
+		baseCurrency,
+	}
+}
+
+// rowScanner cho phép dùng chung hàm scan cho *sql.Row và *sql.Rows
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+// Các cột mốc thời gian trong vòng đời đơn hàng, theo đúng thứ tự của timestampValues
+const timestampColumns = `confirmed_at, paid_at, shipped_at, delivered_at, completed_at, cancelled_at, refunded_at`
+
+func timestampValues(order model.Order) []any {
+	return []any{
+		formatCustomTime(order.ConfirmedAt),
+		formatCustomTime(order.PaidAt),
+		formatCustomTime(order.ShippedAt),
+		formatCustomTime(order.DeliveredAt),
+		formatCustomTime(order.CompletedAt),
+		formatCustomTime(order.CancelledAt),
+		formatCustomTime(order.RefundedAt),
+	}
+}
+
+func scanOrder(row rowScanner) (model.Order, error) {
+	var (
+		order      model.Order
+		id         int64
+		customerID string
+		createdAt  sql.NullString
+		timestamps [7]sql.NullString
+		deletedAt  sql.NullString
+		amounts    [4]int64
+		baseTotal  sql.NullInt64
+		baseCode   string
+	)
+
+	err := row.Scan(&id, &customerID, &order.OrderStatus, &createdAt, &order.Version,
+		&timestamps[0], &timestamps[1], &timestamps[2], &timestamps[3], &timestamps[4], &timestamps[5], &timestamps[6],
+		&deletedAt, &order.DeletedBy,
+		&order.Region, &order.Currency, &amounts[0], &amounts[1], &amounts[2], &amounts[3], &baseTotal, &baseCode)
+	if err != nil {
+		return model.Order{}, err
+	}
+
+	order.OrderID = uint64(id)
+	order.CustomerID = model.CustomerID(customerID)
+	order.Subtotal = model.Money{Amount: uint64(amounts[0]), Currency: order.Currency}
+	order.Tax = model.Money{Amount: uint64(amounts[1]), Currency: order.Currency}
+	order.Shipping = model.Money{Amount: uint64(amounts[2]), Currency: order.Currency}
+	order.GrandTotal = model.Money{Amount: uint64(amounts[3]), Currency: order.Currency}
+	if baseTotal.Valid {
+		order.BaseTotal = &model.Money{Amount: uint64(baseTotal.Int64), Currency: baseCode}
+	}
+	if order.CreateAt, err = parseTime(createdAt); err != nil {
+		return model.Order{}, fmt.Errorf("invalid created_at: %w", err)
+	}
+
+	// Gán các mốc thời gian theo đúng thứ tự của timestampColumns
+	targets := []**util.CustomTime{
+		&order.ConfirmedAt, &order.PaidAt, &order.ShippedAt, &order.DeliveredAt,
+		&order.CompletedAt, &order.CancelledAt, &order.RefundedAt,
+	}
+	for i, target := range targets {
+		if *target, err = parseCustomTime(timestamps[i]); err != nil {
+			return model.Order{}, fmt.Errorf("invalid timestamp: %w", err)
+		}
+	}
+	if order.DeletedAt, err = parseCustomTime(deletedAt); err != nil {
+		return model.Order{}, fmt.Errorf("invalid deleted_at: %w", err)
+	}
+	return order, nil
+}
+
+// loadLineItems lấy line item của order theo đúng thứ tự lúc lưu
+func (r *SQLRepo) loadLineItems(ctx context.Context, order *model.Order) error {
+	rows, err := r.DB.QueryContext(ctx,
+		`SELECT item_id, quantity, price, sku, name FROM line_items WHERE order_id = ? ORDER BY position`,
+		sqlOrderID(order.OrderID),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query line items: %w", err)
+	}
+	defer rows.Close()
+
+	order.LineItems = []model.LineItem{}
+	for rows.Next() {
+		var (
+			item   model.LineItem
+			itemID string
+		)
+		var price int64
+		if err := rows.Scan(&itemID, &item.Quantity, &price, &item.SKU, &item.Name); err != nil {
+			return fmt.Errorf("failed to scan line item: %w", err)
+		}
+		item.Price = model.Money{Amount: uint64(price), Currency: order.Currency}
+		if item.ItemID, err = uuid.Parse(itemID); err != nil {
+			return fmt.Errorf("invalid item id: %w", err)
+		}
+		order.LineItems = append(order.LineItems, item)
+	}
+	return rows.Err()
+}
+
+// loadPayments lấy các lần thanh toán của order theo đúng thứ tự lúc lưu
+func (r *SQLRepo) loadPayments(ctx context.Context, order *model.Order) error {
+	rows, err := r.DB.QueryContext(ctx,
+		`SELECT intent_id, provider, amount, currency, captured, refunded, status, error, created_at, updated_at `+
+			`FROM payments WHERE order_id = ? ORDER BY position`,
+		sqlOrderID(order.OrderID),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to query payments: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			p                          model.PaymentIntent
+			amount, captured, refunded int64
+			createdAt, updatedAt       string
+		)
+		if err := rows.Scan(&p.IntentID, &p.Provider, &amount, &p.Amount.Currency, &captured, &refunded,
+			&p.Status, &p.Error, &createdAt, &updatedAt); err != nil {
+			return fmt.Errorf("failed to scan payment: %w", err)
+		}
+		p.Amount.Amount = uint64(amount)
+		p.Captured = uint64(captured)
+		p.Refunded = uint64(refunded)
+		if p.CreatedAt, err = time.Parse(time.RFC3339Nano, createdAt); err != nil {
+			return fmt.Errorf("invalid payment created_at: %w", err)
+		}
+		if p.UpdatedAt, err = time.Parse(time.RFC3339Nano, updatedAt); err != nil {
+			return fmt.Errorf("invalid payment updated_at: %w", err)
+		}
+		order.Payments = append(order.Payments, p)
+	}
+	return rows.Err()
+}
+
+const selectOrder = `SELECT order_id, customer_id, order_status, created_at, version, ` + timestampColumns + `, deleted_at, deleted_by, ` + amountColumns + ` FROM orders`
+
+// Điều kiện chỉ lấy order đang hoạt động (chưa bị xóa mềm)
+const activeOrder = `deleted_at IS NULL`
+
+func (r *SQLRepo) FindByID(ctx context.Context, id uint64) (model.Order, error) {
+	return r.findByID(ctx, id, activeOrder)
+}
+
+// FindDeletedByID đọc order đã bị xóa mềm
+func (r *SQLRepo) FindDeletedByID(ctx context.Context, id uint64) (model.Order, error) {
+	return r.findByID(ctx, id, `deleted_at IS NOT NULL`)
+}
+
+func (r *SQLRepo) findByID(ctx context.Context, id uint64, cond string) (model.Order, error) {
+	row := r.DB.QueryRowContext(ctx, selectOrder+` WHERE order_id = ? AND `+cond, sqlOrderID(id))
+
+	order, err := scanOrder(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.Order{}, ErrNotExist
+	} else if err != nil {
+		return model.Order{}, fmt.Errorf("get order: %w", err)
+	}
+
+	if err := r.loadLineItems(ctx, &order); err != nil {
+		return model.Order{}, err
+	}
+	if err := r.loadPayments(ctx, &order); err != nil {
+		return model.Order{}, err
+	}
+	return order, nil
+}
+
+// Update ghi đè order đã tồn tại, line item được xóa rồi ghi lại toàn bộ.
+// Các bản ghi lịch sử được thêm vào order_history trong cùng transaction.
+// Chỉ ghi khi version đang lưu bằng order.Version - 1 (compare-and-set).
+func (r *SQLRepo) Update(ctx context.Context, order model.Order, changes ...model.StatusChange) error {
+	tx, err := r.DB.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin tx: %w", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx,
+		`UPDATE orders SET customer_id = ?, order_status = ?, created_at = ?, version = ?, `+
+			`confirmed_at = ?, paid_at = ?, shipped_at = ?, delivered_at = ?, completed_at = ?, cancelled_at = ?, refunded_at = ?, `+
+			`region = ?, currency = ?, subtotal = ?, tax = ?, shipping = ?, grand_total = ?, base_total = ?, base_currency = ? `+
+			`WHERE order_id = ? AND version = ? AND `+activeOrder,
+		append(append(append([]any{order.CustomerID.String(), order.OrderStatus, formatTime(order.CreateAt), order.Version},
+			timestampValues(order)...), amountValues(order)...), sqlOrderID(order.OrderID), order.Version-1)...,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update order: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		// Không có dòng nào được cập nhật: order không tồn tại hoặc version đã thay đổi
+		var exist int
+		err := tx.QueryRowContext(ctx, `SELECT 1 FROM orders WHERE order_id = ? AND `+activeOrder, sqlOrderID(order.OrderID)).Scan(&exist)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrNotExist
+		} else if err != nil {
+			return fmt.Errorf("failed to check order: %w", err)
+		}
+		return ErrVersionMismatch
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM line_items WHERE order_id = ?`, sqlOrderID(order.OrderID)); err != nil {
+		return fmt.Errorf("failed to clear line items: %w", err)
+	}
+	if err := insertLineItems(ctx, tx, order); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM payments WHERE order_id = ?`, sqlOrderID(order.OrderID)); err != nil {
+		return fmt.Errorf("failed to clear payments: %w", err)
+	}
+	if err := insertPayments(ctx, tx, order); err != nil {
+		return err
+	}
+
+	if err := insertHistory(ctx, tx, changes); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit: %w", err)
+	}
+	return nil
+}
+
+// FindHistory trả về lịch sử trạng thái của order theo thứ tự ghi
+func (r *SQLRepo) FindHistory(ctx context.Context, id uint64) ([]model.StatusChange, error) {
+	rows, err := r.DB.QueryContext(ctx,
+		`SELECT order_id, from_status, to_status, actor, reason, changed_at FROM order_history WHERE order_id = ? ORDER BY id`,
+		sqlOrderID(id),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query order history: %w", err)
+	}
+	defer rows.Close()
+
+	history := []model.StatusChange{}
+	for rows.Next() {
+		var (
+			change    model.StatusChange
+			orderID   int64
+			changedAt string
+		)
+		if err := rows.Scan(&orderID, &change.From, &change.To, &change.Actor, &change.Reason, &changedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan status change: %w", err)
+		}
+		change.OrderID = uint64(orderID)
+		if change.ChangedAt, err = time.Parse(time.RFC3339Nano, changedAt); err != nil {
+			return nil, fmt.Errorf("invalid changed_at: %w", err)
+		}
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// DeleteByID xóa mềm order: ghi deleted_at/deleted_by và tăng version,
+// line item và lịch sử trạng thái được giữ nguyên
+func (r *SQLRepo) DeleteByID(ctx context.Context, id uint64, deletedBy string) error {
+	now := util.CustomTime(time.Now().UTC())
+	res, err := r.DB.ExecContext(ctx,
+		`UPDATE orders SET deleted_at = ?, deleted_by = ?, version = version + 1 WHERE order_id = ? AND `+activeOrder,
+		formatCustomTime(&now), deletedBy, sqlOrderID(id),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete order: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrNotExist
+	}
+	return nil
+}
+
+// Restore bỏ đánh dấu xóa mềm của order và tăng version
+func (r *SQLRepo) Restore(ctx context.Context, id uint64) (model.Order, error) {
+	res, err := r.DB.ExecContext(ctx,
+		`UPDATE orders SET deleted_at = NULL, deleted_by = '', version = version + 1 WHERE order_id = ? AND deleted_at IS NOT NULL`,
+		sqlOrderID(id),
+	)
+	if err != nil {
+		return model.Order{}, fmt.Errorf("failed to restore order: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return model.Order{}, ErrNotExist
+	}
+	return r.FindByID(ctx, id)
+}
+
+// RewriteDeletedCustomerIDs ghi lại customer_id của các order đã xóa mềm,
+// chỉ ghi khi version chưa đổi kể từ lúc đọc
+func (r *SQLRepo) RewriteDeletedCustomerIDs(ctx context.Context, rewrite func(o model.Order) (model.CustomerID, bool)) (int, error) {
+	// 1. Lấy ID các order đã xóa mềm, đóng rows trước khi đọc từng order
+	rows, err := r.DB.QueryContext(ctx, `SELECT order_id FROM orders WHERE deleted_at IS NOT NULL ORDER BY order_id`)
+	if err != nil {
+		return 0, fmt.Errorf("failed to query deleted orders: %w", err)
+	}
+	var ids []uint64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return 0, fmt.Errorf("failed to scan order id: %w", err)
+		}
+		ids = append(ids, uint64(id))
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("failed to query deleted orders: %w", err)
+	}
+
+	// 2. Đổi customer_id của từng order
+	rewritten := 0
+	for _, id := range ids {
+		archived, err := r.FindDeletedByID(ctx, id)
+		if errors.Is(err, ErrNotExist) {
+			continue // order vừa được Restore
+		} else if err != nil {
+			return rewritten, err
+		}
+
+		newID, ok := rewrite(archived)
+		if !ok {
+			continue
+		}
+		res, err := r.DB.ExecContext(ctx,
+			`UPDATE orders SET customer_id = ?, version = version + 1 WHERE order_id = ? AND version = ? AND deleted_at IS NOT NULL`,
+			newID.String(), sqlOrderID(id), archived.Version,
+		)
+		if err != nil {
+			return rewritten, fmt.Errorf("failed to update order %d: %w", id, err)
+		}
+		if n, err := res.RowsAffected(); err == nil && n == 0 {
+			return rewritten, fmt.Errorf("order %d: %w", id, ErrVersionMismatch)
+		}
+		rewritten++
+	}
+	return rewritten, nil
+}
+
+// FindAll lọc bằng WHERE, sắp xếp theo created_at rồi order_id và phân trang theo khóa:
+// chỉ lấy các dòng đứng sau Position page.After, LIMIT Size + 1 để biết còn trang sau.
+// Next là Position của dòng cuối trang, nil khi đã hết dữ liệu.
+func (r *SQLRepo) FindAll(ctx context.Context, page FindAllPage) (FindResult, error) {
+	// 1. Dựng điều kiện lọc
+	var (
+		conds = []string{activeOrder}
+		args  []any
+	)
+	if page.Filter.CustomerID != "" {
+		conds = append(conds, "customer_id = ?")
+		args = append(args, page.Filter.CustomerID.String())
+	}
+	if page.Filter.Status != "" {
+		conds = append(conds, "order_status = ?")
+		args = append(args, page.Filter.Status)
+	}
+	if page.Filter.CreatedFrom != nil {
+		conds = append(conds, "created_at >= ?")
+		args = append(args, formatTime(page.Filter.CreatedFrom))
+	}
+	if page.Filter.CreatedTo != nil {
+		conds = append(conds, "created_at <= ?")
+		args = append(args, formatTime(page.Filter.CreatedTo))
+	}
+
+	// Chỉ lấy các dòng đứng sau vị trí After theo thứ tự sắp xếp.
+	// created_at lưu dạng chuỗi cùng độ dài nên so sánh chuỗi đúng với so sánh thời gian.
+	desc := page.Sort == SortCreatedAtDesc
+	if page.After != nil {
+		op := ">"
+		if desc {
+			op = "<"
+		}
+		after := formatTime(&page.After.CreatedAt)
+		conds = append(conds, "(created_at "+op+" ? OR (created_at = ? AND order_id "+op+" ?))")
+		args = append(args, after, after, sqlOrderID(page.After.OrderID))
+	}
+
+	query := selectOrder + " WHERE " + strings.Join(conds, " AND ")
+
+	// 2. Sắp xếp, lấy dư 1 dòng để biết còn trang sau hay không
+	if desc {
+		query += " ORDER BY created_at DESC, order_id DESC"
+	} else {
+		query += " ORDER BY created_at, order_id"
+	}
+	query += " LIMIT ?"
+	args = append(args, int64(page.Size)+1)
+
+	rows, err := r.DB.QueryContext(ctx, query, args...)
+	if err != nil {
+		return FindResult{}, fmt.Errorf("failed to query orders: %w", err)
+	}
+
+	orders := []model.Order{}
+	for rows.Next() {
+		order, err := scanOrder(rows)
+		if err != nil {
+			rows.Close()
+			return FindResult{}, fmt.Errorf("failed to scan order: %w", err)
+		}
+		orders = append(orders, order)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return FindResult{}, fmt.Errorf("failed to read orders: %w", err)
+	}
+
+	// 3. Bỏ dòng dư nếu có, khi đó mới trả về vị trí cho trang sau
+	var next *Position
+	if uint64(len(orders)) > page.Size {
+		orders = orders[:page.Size]
+		next = sqlPosition(orders[len(orders)-1])
+	}
+
+	// 4. Lấy line item sau khi đã đóng rows để không giữ kết nối khi chạy query khác
+	for i := range orders {
+		if err := r.loadLineItems(ctx, &orders[i]); err != nil {
+			return FindResult{}, err
+		}
+		if err := r.loadPayments(ctx, &orders[i]); err != nil {
+			return FindResult{}, err
+		}
+	}
+
+	return FindResult{
+		Orders: orders,
+		Next:   next,
+	}, nil
+}
+
+// sqlPosition trả về vị trí của order trong thứ tự của FindAll
+func sqlPosition(o model.Order) *Position {
+	pos := &Position{OrderID: o.OrderID}
+	if o.CreateAt != nil {
+		pos.CreatedAt = *o.CreateAt
+	}
+	return pos
+}
@@ -0,0 +1,202 @@
+package order
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/util"
+	_ "modernc.org/sqlite"
+)
+
+func newTestSQLRepo(t *testing.T) *SQLRepo {
+	t.Helper()
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	db.SetMaxOpenConns(1) // mỗi kết nối ":memory:" là một database riêng
+	t.Cleanup(func() { db.Close() })
+	return &SQLRepo{DB: db}
+}
+
+// Mốc thời gian RFC3339Nano của dữ liệu cũ được viết lại theo sqlTimeLayout
+// để sắp xếp và lọc theo created_at đúng thứ tự
+func TestSQLMigratePadsLegacyTimes(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestSQLRepo(t)
+	db := repo.DB
+
+	// 1. Schema trước bước padTimes, ghi dữ liệu theo layout cũ
+	all := sqlMigrations
+	sqlMigrations = all[:10]
+	err := repo.Migrate(ctx)
+	sqlMigrations = all
+	if err != nil {
+		t.Fatalf("Migrate to version 10: %v", err)
+	}
+
+	legacy := map[int64]string{
+		1: "2025-01-31T14:05:00.5Z",
+		2: "2025-01-31T14:05:00Z",
+		3: "2025-01-31T14:05:00.123456789Z",
+		4: "2025-01-31T14:05:00.12Z",
+	}
+	for id, createdAt := range legacy {
+		_, err := db.ExecContext(ctx, `INSERT INTO orders (order_id, customer_id, order_status, created_at, shipped_at, version) VALUES (?, ?, 'pending', ?, NULL, 1)`,
+			id, customerA.String(), createdAt)
+		if err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+		_, err = db.ExecContext(ctx, `INSERT INTO order_history (order_id, from_status, to_status, actor, changed_at) VALUES (?, '', 'pending', 'test', ?)`, id, createdAt)
+		if err != nil {
+			t.Fatalf("insert history: %v", err)
+		}
+	}
+
+	// 2. Chạy tiếp các bước còn lại
+	if err := repo.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	want := map[int64]string{
+		1: "2025-01-31T14:05:00.500000000Z",
+		2: "2025-01-31T14:05:00.000000000Z",
+		3: "2025-01-31T14:05:00.123456789Z",
+		4: "2025-01-31T14:05:00.120000000Z",
+	}
+	for id, w := range want {
+		var createdAt, changedAt string
+		var shippedAt sql.NullString
+		if err := db.QueryRowContext(ctx, `SELECT created_at, shipped_at FROM orders WHERE order_id = ?`, id).Scan(&createdAt, &shippedAt); err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		if err := db.QueryRowContext(ctx, `SELECT changed_at FROM order_history WHERE order_id = ?`, id).Scan(&changedAt); err != nil {
+			t.Fatalf("select history: %v", err)
+		}
+		if createdAt != w || changedAt != w || shippedAt.Valid {
+			t.Fatalf("order %d: created_at=%q changed_at=%q shipped_at=%v, want %q", id, createdAt, changedAt, shippedAt, w)
+		}
+	}
+
+	got := pageIDs(t, repo, FindAllPage{Size: 2})
+	if fmt.Sprint(got) != "[2 4 3 1]" {
+		t.Fatalf("orders by created_at = %v, want [2 4 3 1]", got)
+	}
+}
+
+// order_status tự do của dữ liệu cũ được chuẩn hóa như lifecycle.Current
+// để lọc theo trạng thái ra cùng kết quả với Redis
+func TestSQLMigrateNormalizesLegacyStatuses(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestSQLRepo(t)
+
+	// 1. Schema trước bước chuẩn hóa, ghi trạng thái theo kiểu cũ
+	all := sqlMigrations
+	sqlMigrations = all[:11]
+	err := repo.Migrate(ctx)
+	sqlMigrations = all
+	if err != nil {
+		t.Fatalf("Migrate to version 11: %v", err)
+	}
+
+	shippedAt := time.Date(2025, 1, 31, 14, 5, 0, 0, time.UTC)
+	tests := []struct {
+		id      int64
+		status  string
+		shipped bool
+		want    model.Status
+	}{
+		{1, "done", false, model.StatusCompleted},
+		{2, " Processing ", false, model.StatusConfirmed},
+		{3, "canceled", false, model.StatusCancelled},
+		{4, "In-Transit", false, model.StatusShipped},
+		{5, "PAID", false, model.StatusPaid},
+		{6, "", false, model.StatusPending},
+		{7, "on hold", false, model.StatusPending},
+		{8, "open", true, model.StatusShipped},
+		{9, "delivered", true, model.StatusDelivered},
+	}
+	for _, tt := range tests {
+		o := model.Order{OrderStatus: model.Status(tt.status)}
+		var shipped sql.NullString
+		if tt.shipped {
+			o.ShippedAt = (*util.CustomTime)(&shippedAt)
+			shipped = formatTime(&shippedAt)
+		}
+		if got := lifecycle.Current(o); got != tt.want {
+			t.Fatalf("lifecycle.Current(%q) = %s, want %s", tt.status, got, tt.want)
+		}
+		createdAt := time.Date(2025, 1, 1, 0, 0, 0, int(tt.id), time.UTC)
+		_, err := repo.DB.ExecContext(ctx, `INSERT INTO orders (order_id, customer_id, order_status, created_at, shipped_at, version) VALUES (?, ?, ?, ?, ?, 1)`,
+			tt.id, customerA.String(), tt.status, formatTime(&createdAt), shipped)
+		if err != nil {
+			t.Fatalf("insert: %v", err)
+		}
+	}
+
+	// 2. Chạy tiếp bước chuẩn hóa
+	if err := repo.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	for _, tt := range tests {
+		var status string
+		if err := repo.DB.QueryRowContext(ctx, `SELECT order_status FROM orders WHERE order_id = ?`, tt.id).Scan(&status); err != nil {
+			t.Fatalf("select: %v", err)
+		}
+		if model.Status(status) != tt.want {
+			t.Fatalf("order %d: order_status = %q, want %s", tt.id, status, tt.want)
+		}
+	}
+
+	got := pageIDs(t, repo, FindAllPage{Size: 2, Filter: OrderFilter{Status: model.StatusShipped}})
+	if fmt.Sprint(got) != "[4 8]" {
+		t.Fatalf("shipped orders = %v, want [4 8]", got)
+	}
+}
+
+// Insert ghi bản ghi lịch sử tạo đơn cùng với order ở mọi backend
+func TestInsertRecordsHistory(t *testing.T) {
+	ctx := context.Background()
+	sqlRepo := newTestSQLRepo(t)
+	if err := sqlRepo.Migrate(ctx); err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+	repos := map[string]OrderRepository{
+		"memory": NewMemoryRepo(),
+		"redis":  newTestRedisRepo(t),
+		"sql":    sqlRepo,
+	}
+
+	createdAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	o := model.Order{OrderID: 7, CustomerID: customerA, OrderStatus: model.StatusPending, Version: 1, CreateAt: &createdAt}
+	change := lifecycle.Created(o, customerA.String())
+	for name, repo := range repos {
+		t.Run(name, func(t *testing.T) {
+			if err := repo.Insert(ctx, o, change); err != nil {
+				t.Fatalf("Insert: %v", err)
+			}
+			history, err := repo.FindHistory(ctx, o.OrderID)
+			if err != nil {
+				t.Fatalf("FindHistory: %v", err)
+			}
+			if len(history) != 1 || history[0] != change {
+				t.Fatalf("history = %+v, want [%+v]", history, change)
+			}
+
+			// ID đã tồn tại thì không ghi thêm lịch sử
+			if err := repo.Insert(ctx, o, change); !errors.Is(err, ErrAlreadyExists) {
+				t.Fatalf("second Insert error = %v, want ErrAlreadyExists", err)
+			}
+			if history, _ := repo.FindHistory(ctx, o.OrderID); len(history) != 1 {
+				t.Fatalf("history after duplicate Insert = %+v", history)
+			}
+		})
+	}
+}
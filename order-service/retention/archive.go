@@ -0,0 +1,203 @@
+package retention
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/redis/go-redis/v9"
+)
+
+// Dùng để báo lỗi khi order không có trong kho lưu trữ lạnh
+var ErrNotFound = errors.New("order is not in the archive")
+
+// Thư mục lưu trữ mặc định, tính từ thư mục làm việc của service
+const DefaultDir = "archive"
+
+// Hash trong Redis ánh xạ order ID -> tên file lưu trữ chứa order đó
+const indexKey = "retention:orders"
+
+// Record là một dòng trong file lưu trữ: order cùng lịch sử trạng thái của nó
+type Record struct {
+	Order      model.Order          `json:"order"`
+	History    []model.StatusChange `json:"history"`
+	ArchivedAt time.Time            `json:"archived_at"`
+}
+
+/*
+Archive là kho lưu trữ lạnh của đơn hàng trên đĩa:
+  - Mỗi tháng một file "orders-YYYY-MM.ndjson.gz" (theo thời điểm lưu trữ),
+    mỗi dòng là JSON của một Record
+  - Mỗi lần ghi là một gzip member nối vào cuối file, gzip.Reader đọc liền các member
+    nên file vẫn là một file .gz hợp lệ
+  - Order ID -> tên file được lưu trong hash "retention:orders" của Redis để tìm nhanh
+
+Khi chạy nhiều instance, Dir phải là thư mục dùng chung (ví dụ volume mạng).
+*/
+type Archive struct {
+	Dir    string
+	Client *redis.Client
+
+	mu sync.RWMutex // không đọc file khi đang nối thêm dữ liệu trong cùng process
+}
+
+// fileName trả về tên file lưu trữ của tháng chứa t
+func fileName(t time.Time) string {
+	return fmt.Sprintf("orders-%s.ndjson.gz", t.UTC().Format("2006-01"))
+}
+
+// Append ghi các record vào file của tháng hiện tại rồi ghi index order ID -> file.
+// Dữ liệu được fsync trước khi ghi index để order chỉ bị xóa khỏi Redis khi đã nằm trên đĩa.
+func (a *Archive) Append(ctx context.Context, records []Record, now time.Time) error {
+	if len(records) == 0 {
+		return nil
+	}
+	name := fileName(now)
+
+	a.mu.Lock()
+	err := a.appendFile(name, records)
+	a.mu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	values := make([]any, 0, 2*len(records))
+	for _, rec := range records {
+		values = append(values, strconv.FormatUint(rec.Order.OrderID, 10), name)
+	}
+	if err := a.Client.HSet(ctx, indexKey, values...).Err(); err != nil {
+		return fmt.Errorf("failed to index archived orders: %w", err)
+	}
+	return nil
+}
+
+// Forget xóa order khỏi index, Find không còn tìm thấy order dù dữ liệu vẫn nằm trong file
+func (a *Archive) Forget(ctx context.Context, id uint64) error {
+	if err := a.Client.HDel(ctx, indexKey, strconv.FormatUint(id, 10)).Err(); err != nil {
+		return fmt.Errorf("failed to unindex archived order: %w", err)
+	}
+	return nil
+}
+
+func (a *Archive) appendFile(name string, records []Record) error {
+	if err := os.MkdirAll(a.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create archive dir: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(a.Dir, name), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open archive file: %w", err)
+	}
+	defer f.Close()
+
+	zw := gzip.NewWriter(f)
+	enc := json.NewEncoder(zw)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return fmt.Errorf("failed to write archive: %w", err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("failed to sync archive file: %w", err)
+	}
+	return f.Close()
+}
+
+// Contains cho biết từng order ID có nằm trong kho lưu trữ hay không (chỉ đọc index)
+func (a *Archive) Contains(ctx context.Context, ids []uint64) ([]bool, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	fields := make([]string, len(ids))
+	for i, id := range ids {
+		fields[i] = strconv.FormatUint(id, 10)
+	}
+	names, err := a.Client.HMGet(ctx, indexKey, fields...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to find archived orders: %w", err)
+	}
+
+	found := make([]bool, len(ids))
+	for i, name := range names {
+		found[i] = name != nil
+	}
+	return found, nil
+}
+
+// Find tìm order trong kho lưu trữ, file có thể chứa nhiều bản của cùng order
+// (lần lưu trữ trước bị dừng giữa chừng) thì lấy bản ghi sau cùng
+func (a *Archive) Find(ctx context.Context, id uint64) (Record, error) {
+	// 1. Tìm file chứa order
+	name, err := a.Client.HGet(ctx, indexKey, strconv.FormatUint(id, 10)).Result()
+	if errors.Is(err, redis.Nil) {
+		return Record{}, ErrNotFound
+	} else if err != nil {
+		return Record{}, fmt.Errorf("failed to find archived order: %w", err)
+	}
+	if name != filepath.Base(name) {
+		return Record{}, fmt.Errorf("invalid archive file name: %q", name)
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	f, err := os.Open(filepath.Join(a.Dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return Record{}, fmt.Errorf("%w: missing archive file %s", ErrNotFound, name)
+	} else if err != nil {
+		return Record{}, fmt.Errorf("failed to open archive file: %w", err)
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return Record{}, fmt.Errorf("failed to read archive file: %w", err)
+	}
+
+	// 2. Đọc lần lượt từng dòng, chỉ giải mã toàn bộ dòng có đúng order ID
+	var (
+		found Record
+		ok    bool
+	)
+	r := bufio.NewReader(zr)
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			var head struct {
+				Order struct {
+					OrderID uint64 `json:"order_id"`
+				} `json:"order"`
+			}
+			if json.Unmarshal(line, &head) == nil && head.Order.OrderID == id {
+				var rec Record
+				if err := json.Unmarshal(line, &rec); err != nil {
+					return Record{}, fmt.Errorf("failed to decode archived order: %w", err)
+				}
+				found, ok = rec, true
+			}
+		}
+		// Member cuối bị ghi dở (service dừng giữa chừng) thì dùng những gì đã đọc được
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		} else if err != nil {
+			return Record{}, fmt.Errorf("failed to read archive file: %w", err)
+		}
+	}
+
+	if !ok {
+		return Record{}, ErrNotFound
+	}
+	return found, nil
+}
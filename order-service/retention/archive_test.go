@@ -0,0 +1,114 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestArchive(t *testing.T) *Archive {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return &Archive{Dir: t.TempDir(), Client: client}
+}
+
+func archivedRecord(id uint64, status model.Status) Record {
+	return Record{
+		Order:      model.Order{OrderID: id, OrderStatus: status, Version: 2},
+		History:    []model.StatusChange{{OrderID: id, From: model.StatusDelivered, To: status, Actor: "test"}},
+		ArchivedAt: time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestArchiveFind(t *testing.T) {
+	ctx := context.Background()
+	march := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+	april := march.AddDate(0, 1, 0)
+
+	tests := []struct {
+		name    string
+		prepare func(t *testing.T, a *Archive)
+		id      uint64
+		status  model.Status // trạng thái của bản ghi tìm được
+		wantErr error
+	}{
+		{"archived order", func(t *testing.T, a *Archive) {
+			a.Append(ctx, []Record{archivedRecord(1, model.StatusCompleted), archivedRecord(2, model.StatusCompleted)}, march)
+		}, 2, model.StatusCompleted, nil},
+		{"later copy wins", func(t *testing.T, a *Archive) {
+			a.Append(ctx, []Record{archivedRecord(1, model.StatusDelivered)}, march)
+			a.Append(ctx, []Record{archivedRecord(1, model.StatusCompleted)}, march)
+		}, 1, model.StatusCompleted, nil},
+		{"index points to the latest month", func(t *testing.T, a *Archive) {
+			a.Append(ctx, []Record{archivedRecord(1, model.StatusDelivered)}, march)
+			a.Append(ctx, []Record{archivedRecord(1, model.StatusCompleted)}, april)
+		}, 1, model.StatusCompleted, nil},
+		{"partially written member", func(t *testing.T, a *Archive) {
+			a.Append(ctx, []Record{archivedRecord(1, model.StatusCompleted)}, march)
+			f, err := os.OpenFile(filepath.Join(a.Dir, fileName(march)), os.O_APPEND|os.O_WRONLY, 0)
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			f.Write([]byte{0x1f, 0x8b, 0x08}) // đầu gzip member bị ghi dở
+			f.Close()
+		}, 1, model.StatusCompleted, nil},
+		{"not archived", nil, 1, "", ErrNotFound},
+		{"forgotten order", func(t *testing.T, a *Archive) {
+			a.Append(ctx, []Record{archivedRecord(1, model.StatusCompleted)}, march)
+			a.Forget(ctx, 1)
+		}, 1, "", ErrNotFound},
+		{"missing file", func(t *testing.T, a *Archive) {
+			a.Append(ctx, []Record{archivedRecord(1, model.StatusCompleted)}, march)
+			os.Remove(filepath.Join(a.Dir, fileName(march)))
+		}, 1, "", ErrNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newTestArchive(t)
+			if tt.prepare != nil {
+				tt.prepare(t, a)
+			}
+			rec, err := a.Find(ctx, tt.id)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Find error = %v, want %v", err, tt.wantErr)
+			}
+			if rec.Order.OrderStatus != tt.status {
+				t.Fatalf("Find status = %q, want %q", rec.Order.OrderStatus, tt.status)
+			}
+			if err == nil && (rec.Order.OrderID != tt.id || len(rec.History) != 1) {
+				t.Fatalf("Find = %+v", rec)
+			}
+		})
+	}
+}
+
+func TestArchiveRejectsPathInIndex(t *testing.T) {
+	ctx := context.Background()
+	a := newTestArchive(t)
+	a.Client.HSet(ctx, indexKey, "1", "../orders-2025-03.ndjson.gz")
+	if _, err := a.Find(ctx, 1); err == nil || errors.Is(err, ErrNotFound) {
+		t.Fatalf("Find with path in index error = %v", err)
+	}
+}
+
+func TestArchiveContains(t *testing.T) {
+	ctx := context.Background()
+	a := newTestArchive(t)
+	if err := a.Append(ctx, []Record{archivedRecord(1, model.StatusCompleted), archivedRecord(3, model.StatusCompleted)}, time.Now()); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	found, err := a.Contains(ctx, []uint64{1, 2, 3})
+	if err != nil || fmt.Sprint(found) != "[true false true]" {
+		t.Fatalf("Contains = %v, %v", found, err)
+	}
+}
@@ -0,0 +1,136 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/redislock"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+)
+
+const (
+	DefaultAfter    = 90 * 24 * time.Hour // thời gian giữ đơn trong Redis sau khi hoàn tất
+	DefaultInterval = time.Hour           // chu kỳ quét đơn cần lưu trữ
+)
+
+// Số order đọc từ index completed mỗi lần quét (gợi ý COUNT của ZSCAN)
+const sweepBatch = 100
+
+// Khóa trong Redis để chỉ một instance quét tại một thời điểm
+const lockKey = "retention:lock"
+
+/*
+Sweeper định kỳ chuyển các đơn đã hoàn tất quá After từ Redis sang Archive:
+ 1. Quét index completed, lấy các đơn hoàn tất (completed_at hoặc lịch sử) trước now - After
+ 2. Ghi đơn cùng lịch sử trạng thái vào file lưu trữ của tháng
+ 3. Xóa đơn khỏi Redis, đơn bị sửa trong lúc đó thì giữ lại cho lần quét sau
+
+Đơn đã lưu trữ chỉ còn đọc được (GET /orders/{id}), không hoàn tiền hay sửa được nữa.
+*/
+type Sweeper struct {
+	Orders   *order.RedisRepo
+	Archive  *Archive
+	After    time.Duration // 0 thì dùng DefaultAfter
+	Interval time.Duration // 0 thì dùng DefaultInterval
+}
+
+// Run quét ngay khi khởi động rồi theo chu kỳ cho đến khi ctx bị hủy,
+// lỗi chỉ được ghi log rồi thử lại ở lần quét sau
+func (s *Sweeper) Run(ctx context.Context) {
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.Sweep(ctx, time.Now()); err != nil && ctx.Err() == nil {
+			fmt.Println("failed to archive orders: ", err)
+		} else if n > 0 {
+			fmt.Printf("archived %d completed orders\n", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sweep lưu trữ các đơn hoàn tất trước now - After, trả về số đơn đã chuyển khỏi Redis
+func (s *Sweeper) Sweep(ctx context.Context, now time.Time) (int, error) {
+	after := s.After
+	if after <= 0 {
+		after = DefaultAfter
+	}
+	interval := s.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+
+	// 1. Instance khác đang quét thì bỏ qua lần này
+	lock, err := redislock.Acquire(ctx, s.Archive.Client, lockKey, interval)
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock retention: %w", err)
+	}
+	if lock == nil {
+		return 0, nil
+	}
+	defer func() {
+		if err := lock.Release(context.WithoutCancel(ctx)); err != nil {
+			fmt.Println("failed to unlock retention: ", err)
+		}
+	}()
+
+	cutoff := now.Add(-after)
+	archived := 0
+	var cursor uint64
+	for {
+		// 2. Lấy một đoạn đơn đủ điều kiện cùng lịch sử của từng đơn
+		orders, next, err := s.Orders.FindCompletedBefore(ctx, cutoff, cursor, sweepBatch)
+		if err != nil {
+			return archived, err
+		}
+
+		records := make([]Record, 0, len(orders))
+		for _, o := range orders {
+			history, err := s.Orders.FindHistory(ctx, o.OrderID)
+			if err != nil {
+				return archived, err
+			}
+			records = append(records, Record{Order: o, History: history, ArchivedAt: now.UTC()})
+		}
+
+		// 3. Ghi xuống đĩa trước, chỉ xóa khỏi Redis khi đã ghi xong
+		if err := s.Archive.Append(ctx, records, now); err != nil {
+			return archived, err
+		}
+		for _, rec := range records {
+			err := s.Orders.Evict(ctx, rec.Order)
+			if errors.Is(err, order.ErrVersionMismatch) || errors.Is(err, order.ErrNotExist) {
+				// Đơn vừa được sửa hoặc xóa, bỏ index để bản trong file lưu trữ không được dùng
+				if err := s.Archive.Forget(ctx, rec.Order.OrderID); err != nil {
+					return archived, err
+				}
+				continue
+			} else if err != nil {
+				return archived, err
+			}
+			archived++
+		}
+
+		cursor = next
+		if cursor == 0 {
+			return archived, nil
+		}
+
+		// Gia hạn khóa trước đoạn tiếp theo, mất khóa thì để instance đang giữ quét tiếp
+		if err := lock.Renew(ctx, interval); err != nil {
+			return archived, fmt.Errorf("failed to renew retention lock: %w", err)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/redislock"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/RibunLoc/microservices-learn/util"
+)
+
+const testCustomer = model.CustomerID("64b7f3a2c9e1d2a3b4c5d6e1")
+
+func TestSweep(t *testing.T) {
+	ctx := context.Background()
+	a := newTestArchive(t)
+	repo := &order.RedisRepo{Client: a.Client}
+	s := &Sweeper{Orders: repo, Archive: a, After: 24 * time.Hour}
+
+	now := time.Date(2025, 3, 10, 0, 0, 0, 0, time.UTC)
+	old := util.CustomTime(now.Add(-48 * time.Hour))
+	recent := util.CustomTime(now.Add(-time.Hour))
+	createdAt := now.Add(-30 * 24 * time.Hour)
+
+	tests := []struct {
+		id       uint64
+		status   model.Status
+		done     *util.CustomTime
+		archived bool
+	}{
+		{1, model.StatusCompleted, &old, true},
+		{2, model.StatusCompleted, &recent, false},
+		{3, model.StatusDelivered, nil, false},
+		{4, model.StatusCompleted, &old, true},
+	}
+	for _, tt := range tests {
+		o := model.Order{OrderID: tt.id, CustomerID: testCustomer, OrderStatus: tt.status, CompletedAt: tt.done, Version: 1, CreateAt: &createdAt}
+		created := model.StatusChange{OrderID: tt.id, To: tt.status, Actor: "test", ChangedAt: createdAt}
+		if err := repo.Insert(ctx, o, created); err != nil {
+			t.Fatalf("Insert: %v", err)
+		}
+	}
+
+	// 1. Instance khác đang giữ khóa thì không quét
+	lock, err := redislock.Acquire(ctx, a.Client, lockKey, time.Minute)
+	if err != nil || lock == nil {
+		t.Fatalf("Acquire = %v, %v", lock, err)
+	}
+	if n, err := s.Sweep(ctx, now); err != nil || n != 0 {
+		t.Fatalf("Sweep while locked = %d, %v, want 0", n, err)
+	}
+	lock.Release(ctx)
+
+	// 2. Đơn hoàn tất đủ lâu được chuyển sang kho lưu trữ kèm lịch sử
+	if n, err := s.Sweep(ctx, now); err != nil || n != 2 {
+		t.Fatalf("Sweep = %d, %v, want 2", n, err)
+	}
+	for _, tt := range tests {
+		_, err := repo.FindByID(ctx, tt.id)
+		if inRedis := err == nil; inRedis == tt.archived {
+			t.Fatalf("order %d in redis = %v, archived = %v", tt.id, inRedis, tt.archived)
+		}
+		rec, err := a.Find(ctx, tt.id)
+		if tt.archived && (err != nil || rec.Order.OrderID != tt.id || len(rec.History) == 0 || !rec.ArchivedAt.Equal(now)) {
+			t.Fatalf("archived order %d = %+v, %v", tt.id, rec, err)
+		}
+		if !tt.archived && !errors.Is(err, ErrNotFound) {
+			t.Fatalf("order %d found in archive: %v", tt.id, err)
+		}
+	}
+
+	// 3. Quét lại không còn gì để chuyển
+	if n, err := s.Sweep(ctx, now); err != nil || n != 0 {
+		t.Fatalf("second Sweep = %d, %v, want 0", n, err)
+	}
+}
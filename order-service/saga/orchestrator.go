@@ -0,0 +1,406 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/customer"
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/RibunLoc/microservices-learn/lifecycle"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/payment"
+	"github.com/RibunLoc/microservices-learn/redislock"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/redis/go-redis/v9"
+)
+
+// Chu kỳ chạy tiếp các saga bị bỏ dở mặc định
+const DefaultResumeInterval = time.Minute
+
+// Thời gian giữ quyền chạy một saga, được gia hạn trước mỗi bước.
+// Instance dừng giữa chừng thì sau thời gian này saga được instance khác chạy tiếp.
+const lockTTL = time.Minute
+
+// Số lần đọc lại order khi bị sửa đồng thời lúc đổi trạng thái
+const maxTransitionAttempts = 3
+
+// Actor ghi vào lịch sử khi saga đổi trạng thái đơn
+const sagaActor = "system:saga"
+
+/*
+Orchestrator chạy saga đặt đơn hàng qua các bước:
+
+	validate_customer → create_order → reserve_inventory → authorize_payment → confirm_order
+
+Bước nào lỗi thì các bước đã xong được bù trừ theo thứ tự ngược lại:
+hủy giữ tiền, trả hàng về kho, hủy đơn. Trạng thái saga được lưu sau mỗi bước
+nên saga bị bỏ dở (service dừng giữa chừng) được chạy tiếp bằng Resume.
+Mọi bước đều chạy lại được nhiều lần mà không bị trùng.
+*/
+type Orchestrator struct {
+	Store          *RedisStore
+	Orders         order.OrderRepository
+	Customers      customer.Validator // nil thì bỏ qua bước kiểm tra khách hàng
+	Inventory      inventory.Reserver // nil thì bỏ qua bước giữ chỗ hàng
+	Payments       payment.Provider   // nil thì bỏ qua bước giữ tiền
+	ResumeInterval time.Duration      // 0 thì dùng DefaultResumeInterval
+}
+
+// Place đặt đơn o (đã có OrderID) của actor bằng một saga mới. Thành công thì o được cập nhật
+// thành đơn đã xác nhận. Lỗi thì các bước đã xong được bù trừ và trả về lỗi của bước bị lỗi.
+// Trả về ErrExists nếu order ID đã có saga.
+func (o *Orchestrator) Place(ctx context.Context, theOrder *model.Order, actor string) error {
+	now := time.Now().UTC()
+	s := Saga{
+		OrderID:   theOrder.OrderID,
+		Status:    StatusRunning,
+		Step:      Steps[0],
+		Completed: []Step{},
+		Order:     *theOrder,
+		Actor:     actor,
+		StartedAt: now,
+		UpdatedAt: now,
+	}
+
+	// 1. Giữ quyền chạy và lưu saga trước khi làm bước đầu tiên
+	lock, err := o.Store.Lock(ctx, s.OrderID, lockTTL)
+	if err != nil {
+		return err
+	}
+	if lock == nil {
+		return ErrExists
+	}
+	defer o.unlock(lock)
+
+	if err := o.Store.Create(ctx, s); err != nil {
+		return err
+	}
+
+	// 2. Chạy các bước, lỗi thì bù trừ
+	err = o.drive(ctx, &s, lock)
+	*theOrder = s.Order
+	return err
+}
+
+// Run chạy tiếp các saga bị bỏ dở ngay khi khởi động, sau đó định kỳ cho đến khi ctx bị hủy
+func (o *Orchestrator) Run(ctx context.Context) {
+	interval := o.ResumeInterval
+	if interval <= 0 {
+		interval = DefaultResumeInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := o.Resume(ctx); err != nil && ctx.Err() == nil {
+			fmt.Println("failed to resume sagas: ", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Resume chạy tiếp các saga chưa kết thúc mà không instance nào đang chạy
+func (o *Orchestrator) Resume(ctx context.Context) error {
+	ids, err := o.Store.Active(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		lock, err := o.Store.Lock(ctx, id, lockTTL)
+		if err != nil {
+			return err
+		}
+		if lock == nil {
+			continue // đang được chạy ở nơi khác
+		}
+
+		if err := o.resume(ctx, id, lock); err != nil {
+			fmt.Printf("saga of order %d did not complete: %v\n", id, err)
+		}
+		o.unlock(lock)
+	}
+	return nil
+}
+
+func (o *Orchestrator) resume(ctx context.Context, orderID uint64, lock *redislock.Lock) error {
+	s, err := o.Store.Get(ctx, orderID)
+	if errors.Is(err, redis.Nil) {
+		return nil // saga đã hết hạn
+	} else if err != nil {
+		return err
+	}
+
+	fmt.Printf("Resuming saga of order %d at step %s (%s)\n", orderID, s.Step, s.Status)
+	if s.Finished() {
+		// Saga đã kết thúc nhưng chưa được bỏ khỏi danh sách đang chạy
+		return o.Store.Save(ctx, s)
+	}
+	return o.drive(ctx, &s, lock)
+}
+
+/*
+drive chạy saga từ trạng thái đang lưu:
+ 1. Đang chạy: chạy lần lượt các bước còn lại, lưu saga sau mỗi bước
+ 2. Có bước lỗi (hoặc đang bù trừ dở): bù trừ các bước đã xong theo thứ tự ngược lại
+
+Trả về lỗi của bước làm saga thất bại. Nếu bù trừ lỗi thì saga giữ trạng thái
+compensating để lần Resume sau làm tiếp. Quyền chạy được gia hạn trước mỗi bước,
+mất quyền (bước trước chạy quá lockTTL) thì dừng ngay để instance đang giữ quyền chạy tiếp.
+*/
+func (o *Orchestrator) drive(ctx context.Context, s *Saga, lock *redislock.Lock) error {
+	var failure error
+
+	// 1. Chạy các bước còn lại
+	for s.Status == StatusRunning {
+		step := s.Step
+		if err := o.renew(ctx, s, lock); err != nil {
+			return errors.Join(failure, err)
+		}
+		if err := o.run(ctx, s, step); err != nil {
+			failure = fmt.Errorf("%s: %w", step, err)
+			s.Status = StatusCompensating
+			s.Error = failure.Error()
+		} else {
+			s.Completed = append(s.Completed, step)
+			if next, ok := nextStep(step); ok {
+				s.Step = next
+			} else {
+				s.Status = StatusCompleted
+			}
+		}
+		if err := o.save(ctx, s); err != nil {
+			return errors.Join(failure, err)
+		}
+	}
+
+	if s.Status != StatusCompensating {
+		return nil
+	}
+	if failure == nil {
+		failure = errors.New(s.Error) // đang bù trừ dở từ lần chạy trước
+	}
+
+	// 2. Bù trừ các bước đã xong theo thứ tự ngược lại
+	for len(s.Completed) > 0 {
+		step := s.Completed[len(s.Completed)-1]
+		s.Step = step
+		if err := o.renew(ctx, s, lock); err != nil {
+			return errors.Join(failure, err)
+		}
+		if err := o.compensate(ctx, s, step); err != nil {
+			return errors.Join(failure, fmt.Errorf("failed to compensate %s: %w", step, err))
+		}
+		s.Completed = s.Completed[:len(s.Completed)-1]
+		if err := o.save(ctx, s); err != nil {
+			return errors.Join(failure, err)
+		}
+	}
+
+	s.Status = StatusFailed
+	if err := o.save(ctx, s); err != nil {
+		return errors.Join(failure, err)
+	}
+	return failure
+}
+
+// nextStep trả về bước chạy sau step, false nếu step là bước cuối
+func nextStep(step Step) (Step, bool) {
+	for i, s := range Steps {
+		if s == step && i+1 < len(Steps) {
+			return Steps[i+1], true
+		}
+	}
+	return "", false
+}
+
+func (o *Orchestrator) save(ctx context.Context, s *Saga) error {
+	s.UpdatedAt = time.Now().UTC()
+	return o.Store.Save(ctx, *s)
+}
+
+// renew gia hạn quyền chạy saga trước khi làm một bước
+func (o *Orchestrator) renew(ctx context.Context, s *Saga, lock *redislock.Lock) error {
+	err := lock.Renew(ctx, lockTTL)
+	if errors.Is(err, redislock.ErrLost) {
+		return fmt.Errorf("saga of order %d is no longer locked by this instance: %w", s.OrderID, err)
+	}
+	return err
+}
+
+func (o *Orchestrator) unlock(lock *redislock.Lock) {
+	// Vẫn trả quyền chạy khi ctx của request đã bị hủy
+	if err := lock.Release(context.Background()); err != nil {
+		fmt.Println("failed to unlock saga: ", err)
+	}
+}
+
+// run chạy một bước của saga
+func (o *Orchestrator) run(ctx context.Context, s *Saga, step Step) error {
+	switch step {
+	case StepValidateCustomer:
+		if o.Customers == nil {
+			return nil
+		}
+		return o.Customers.Validate(ctx, s.Order.CustomerID)
+
+	case StepCreateOrder:
+		actor := s.Actor
+		if actor == "" {
+			actor = sagaActor // saga lưu trước khi có Actor
+		}
+		err := o.Orders.Insert(ctx, s.Order, lifecycle.Created(s.Order, actor))
+		if errors.Is(err, order.ErrAlreadyExists) {
+			// Chạy lại sau khi khởi động lại: đơn đã được lưu ở lần chạy trước
+			existing, findErr := o.Orders.FindByID(ctx, s.OrderID)
+			if findErr == nil && sameOrder(existing, s.Order) {
+				s.Order = existing
+				return nil
+			}
+		}
+		return err
+
+	case StepReserveInventory:
+		if o.Inventory == nil {
+			return nil
+		}
+		err := o.Inventory.Reserve(ctx, s.OrderID, inventory.LinesOf(s.Order))
+		if errors.Is(err, inventory.ErrReservationExists) {
+			return nil // đã giữ chỗ ở lần chạy trước
+		}
+		return err
+
+	case StepAuthorizePayment:
+		if o.Payments == nil {
+			return nil
+		}
+		id, err := o.Payments.Authorize(ctx, s.OrderID, s.Order.GrandTotal)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		s.Payment = &model.PaymentIntent{
+			IntentID:  id,
+			Provider:  o.Payments.Name(),
+			Amount:    s.Order.GrandTotal,
+			Status:    model.PaymentAuthorized,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		return nil
+
+	case StepConfirmOrder:
+		// Lần giữ tiền được ghi vào đơn để thu tiền khi khách thanh toán
+		confirmed, err := o.transition(ctx, s.OrderID, model.StatusConfirmed, "order placed", func(theOrder *model.Order) {
+			if s.Payment != nil {
+				theOrder.SetPayment(*s.Payment)
+			}
+		})
+		if err != nil {
+			return err
+		}
+		s.Order = confirmed
+
+		// Đơn đã xác nhận thì hàng được giữ đến khi giao hoặc hủy
+		if o.Inventory != nil {
+			err := o.Inventory.Persist(ctx, s.OrderID)
+			if err != nil && !errors.Is(err, inventory.ErrNoReservation) {
+				fmt.Println("failed to persist inventory reservation: ", err)
+			}
+		}
+		return nil
+	}
+	return fmt.Errorf("unknown saga step %q", step)
+}
+
+// compensate hoàn tác một bước đã xong của saga
+func (o *Orchestrator) compensate(ctx context.Context, s *Saga, step Step) error {
+	switch step {
+	case StepValidateCustomer, StepConfirmOrder:
+		return nil // không có gì để hoàn tác
+
+	case StepCreateOrder:
+		cancelled, err := o.transition(ctx, s.OrderID, model.StatusCancelled, "order placement failed: "+s.Error, nil)
+		if errors.Is(err, order.ErrNotExist) {
+			return nil // đơn đã bị xóa
+		} else if err != nil {
+			return err
+		}
+		s.Order = cancelled
+		return nil
+
+	case StepReserveInventory:
+		if o.Inventory == nil {
+			return nil
+		}
+		err := o.Inventory.Release(ctx, s.OrderID)
+		if errors.Is(err, inventory.ErrNoReservation) {
+			return nil // đã trả hàng hoặc reservation đã hết hạn
+		}
+		return err
+
+	case StepAuthorizePayment:
+		if o.Payments == nil || s.Payment == nil {
+			return nil
+		}
+		return o.Payments.Void(ctx, s.Payment.IntentID)
+	}
+	return fmt.Errorf("unknown saga step %q", step)
+}
+
+// transition chuyển đơn sang trạng thái to, gọi edit (nếu có) để sửa thêm rồi ghi lịch sử.
+// Đơn đã ở trạng thái to thì coi như đã xong ở lần chạy trước.
+func (o *Orchestrator) transition(ctx context.Context, orderID uint64, to model.Status, reason string, edit func(o *model.Order)) (model.Order, error) {
+	var err error
+	for attempt := 0; attempt < maxTransitionAttempts; attempt++ {
+		var theOrder model.Order
+		theOrder, err = o.Orders.FindByID(ctx, orderID)
+		if err != nil {
+			return model.Order{}, err
+		}
+
+		from := lifecycle.Current(theOrder)
+		if from == to {
+			return theOrder, nil
+		}
+
+		now := time.Now().UTC()
+		if err := lifecycle.Apply(&theOrder, to, now); err != nil {
+			return model.Order{}, err
+		}
+		if edit != nil {
+			edit(&theOrder)
+		}
+		theOrder.Version++
+		err = o.Orders.Update(ctx, theOrder, model.StatusChange{
+			OrderID:   orderID,
+			From:      from,
+			To:        to,
+			Actor:     sagaActor,
+			Reason:    reason,
+			ChangedAt: now,
+		})
+		if err == nil {
+			return theOrder, nil
+		}
+		if !errors.Is(err, order.ErrVersionMismatch) {
+			return model.Order{}, err
+		}
+	}
+	return model.Order{}, err
+}
+
+// sameOrder cho biết existing có phải là đơn do saga lưu ở lần chạy trước hay không
+func sameOrder(existing, placed model.Order) bool {
+	if existing.CustomerID != placed.CustomerID || existing.CreateAt == nil || placed.CreateAt == nil {
+		return false
+	}
+	return existing.CreateAt.Equal(*placed.CreateAt)
+}
@@ -0,0 +1,220 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/inventory"
+	"github.com/RibunLoc/microservices-learn/model"
+	"github.com/RibunLoc/microservices-learn/payment"
+	"github.com/RibunLoc/microservices-learn/redislock"
+	"github.com/RibunLoc/microservices-learn/repository/order"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+var testItem = uuid.MustParse("00000000-0000-0000-0000-00000000000a")
+
+const testActor = "64b7f3a2c9e1d2a3b4c5d6e1"
+
+type testEnv struct {
+	orchestrator *Orchestrator
+	orders       *order.MemoryRepo
+	stock        *inventory.RedisStore
+	client       *redis.Client
+}
+
+func newTestEnv(t *testing.T, behavior payment.Behavior) testEnv {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	payments, err := payment.NewFake(behavior)
+	if err != nil {
+		t.Fatalf("NewFake: %v", err)
+	}
+	stock := &inventory.RedisStore{Client: client}
+	if err := stock.SetStock(context.Background(), testItem, 10); err != nil {
+		t.Fatalf("SetStock: %v", err)
+	}
+	orders := order.NewMemoryRepo()
+	return testEnv{
+		orchestrator: &Orchestrator{Store: &RedisStore{Client: client}, Orders: orders, Inventory: stock, Payments: payments},
+		orders:       orders,
+		stock:        stock,
+		client:       client,
+	}
+}
+
+func newTestOrder(id uint64) model.Order {
+	createdAt := time.Date(2025, 3, 1, 8, 0, 0, 0, time.UTC)
+	return model.Order{
+		OrderID:     id,
+		CustomerID:  model.CustomerID("64b7f3a2c9e1d2a3b4c5d6e1"),
+		OrderStatus: model.StatusPending,
+		Currency:    "VND",
+		LineItems:   []model.LineItem{{ItemID: testItem, Quantity: 3, Price: model.Money{Amount: 1000, Currency: "VND"}}},
+		GrandTotal:  model.Money{Amount: 3000, Currency: "VND"},
+		Version:     1,
+		CreateAt:    &createdAt,
+	}
+}
+
+func (e testEnv) reserved(t *testing.T) uint64 {
+	t.Helper()
+	stock, err := e.stock.GetStock(context.Background(), testItem)
+	if err != nil {
+		t.Fatalf("GetStock: %v", err)
+	}
+	return stock.Reserved
+}
+
+func TestPlaceCompletes(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, payment.BehaviorApprove)
+
+	o := newTestOrder(1)
+	if err := env.orchestrator.Place(ctx, &o, testActor); err != nil {
+		t.Fatalf("Place: %v", err)
+	}
+	if o.OrderStatus != model.StatusConfirmed || len(o.Payments) != 1 || o.Payments[0].Status != model.PaymentAuthorized {
+		t.Fatalf("placed order = %+v", o)
+	}
+
+	s, err := env.orchestrator.Store.Get(ctx, 1)
+	if err != nil || s.Status != StatusCompleted || len(s.Completed) != len(Steps) {
+		t.Fatalf("saga = %+v, %v", s, err)
+	}
+	if active, _ := env.orchestrator.Store.Active(ctx); len(active) != 0 {
+		t.Fatalf("active sagas = %v, want none", active)
+	}
+	if got := env.reserved(t); got != 3 {
+		t.Fatalf("reserved = %d, want 3", got)
+	}
+	// Đơn đã xác nhận thì reservation không còn hạn
+	if expired, _ := env.stock.Expired(ctx, time.Now().Add(24*time.Hour), 10); len(expired) != 0 {
+		t.Fatalf("expired reservations = %v, want none", expired)
+	}
+	// Lịch sử bắt đầu bằng bước tạo đơn của người đặt, sau đó saga xác nhận đơn
+	history, err := env.orders.FindHistory(ctx, 1)
+	if err != nil || len(history) != 2 {
+		t.Fatalf("history = %+v, %v", history, err)
+	}
+	if h := history[0]; h.From != "" || h.To != model.StatusPending || h.Actor != testActor || !h.ChangedAt.Equal(*o.CreateAt) {
+		t.Fatalf("first history entry = %+v", h)
+	}
+	if h := history[1]; h.From != model.StatusPending || h.To != model.StatusConfirmed || h.Actor != sagaActor {
+		t.Fatalf("second history entry = %+v", h)
+	}
+	if exists, _ := env.client.Exists(ctx, lockKey(1)).Result(); exists != 0 {
+		t.Fatalf("saga lock was not released")
+	}
+
+	dup := newTestOrder(1)
+	if err := env.orchestrator.Place(ctx, &dup, testActor); err == nil {
+		t.Fatalf("Place with the same order ID succeeded")
+	}
+}
+
+// Cổng thanh toán từ chối thì trả hàng về kho và hủy đơn
+func TestPlaceCompensatesDeclinedPayment(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, payment.BehaviorDecline)
+
+	o := newTestOrder(1)
+	err := env.orchestrator.Place(ctx, &o, testActor)
+	if !errors.Is(err, payment.ErrDeclined) {
+		t.Fatalf("Place error = %v, want ErrDeclined", err)
+	}
+
+	s, err := env.orchestrator.Store.Get(ctx, 1)
+	if err != nil || s.Status != StatusFailed || len(s.Completed) != 0 || s.Error == "" {
+		t.Fatalf("saga = %+v, %v", s, err)
+	}
+	if got := env.reserved(t); got != 0 {
+		t.Fatalf("reserved = %d after compensation, want 0", got)
+	}
+	saved, err := env.orders.FindByID(ctx, 1)
+	if err != nil || saved.OrderStatus != model.StatusCancelled {
+		t.Fatalf("order = %+v, %v, want cancelled", saved, err)
+	}
+}
+
+// Saga bị bỏ dở sau bước giữ chỗ được Resume chạy tiếp mà không giữ chỗ hay lưu đơn hai lần
+func TestResumeContinuesFromSavedStep(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, payment.BehaviorApprove)
+
+	o := newTestOrder(1)
+	if err := env.orders.Insert(ctx, o); err != nil {
+		t.Fatalf("Insert: %v", err)
+	}
+	if err := env.stock.Reserve(ctx, 1, inventory.LinesOf(o)); err != nil {
+		t.Fatalf("Reserve: %v", err)
+	}
+	now := time.Now().UTC()
+	err := env.orchestrator.Store.Create(ctx, Saga{
+		OrderID:   1,
+		Status:    StatusRunning,
+		Step:      StepReserveInventory, // instance dừng trước khi lưu kết quả bước này
+		Completed: []Step{StepValidateCustomer, StepCreateOrder},
+		Order:     o,
+		StartedAt: now,
+		UpdatedAt: now,
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	if err := env.orchestrator.Resume(ctx); err != nil {
+		t.Fatalf("Resume: %v", err)
+	}
+	s, err := env.orchestrator.Store.Get(ctx, 1)
+	if err != nil || s.Status != StatusCompleted {
+		t.Fatalf("saga = %+v, %v", s, err)
+	}
+	if got := env.reserved(t); got != 3 {
+		t.Fatalf("reserved = %d, want 3", got)
+	}
+	saved, err := env.orders.FindByID(ctx, 1)
+	if err != nil || saved.OrderStatus != model.StatusConfirmed {
+		t.Fatalf("order = %+v, %v, want confirmed", saved, err)
+	}
+}
+
+// Mất quyền chạy (khóa hết hạn và bị instance khác lấy) thì dừng ngay, không lưu đè saga
+func TestDriveStopsWhenLockIsLost(t *testing.T) {
+	ctx := context.Background()
+	env := newTestEnv(t, payment.BehaviorApprove)
+
+	now := time.Now().UTC()
+	s := Saga{OrderID: 1, Status: StatusRunning, Step: Steps[0], Completed: []Step{}, Order: newTestOrder(1), StartedAt: now, UpdatedAt: now}
+	if err := env.orchestrator.Store.Create(ctx, s); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	lock, err := env.orchestrator.Store.Lock(ctx, 1, lockTTL)
+	if err != nil || lock == nil {
+		t.Fatalf("Lock = %v, %v", lock, err)
+	}
+
+	// Khóa hết hạn, instance khác lấy quyền chạy
+	env.client.Del(ctx, lockKey(1))
+	if other, err := env.orchestrator.Store.Lock(ctx, 1, lockTTL); err != nil || other == nil {
+		t.Fatalf("Lock by other instance = %v, %v", other, err)
+	}
+
+	if err := env.orchestrator.drive(ctx, &s, lock); !errors.Is(err, redislock.ErrLost) {
+		t.Fatalf("drive error = %v, want ErrLost", err)
+	}
+	saved, err := env.orchestrator.Store.Get(ctx, 1)
+	if err != nil || saved.Status != StatusRunning || len(saved.Completed) != 0 {
+		t.Fatalf("saga = %+v, %v, want untouched", saved, err)
+	}
+	if _, err := env.orders.FindByID(ctx, 1); !errors.Is(err, order.ErrNotExist) {
+		t.Fatalf("order was created by an instance without the lock: %v", err)
+	}
+}
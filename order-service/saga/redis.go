@@ -0,0 +1,137 @@
+package saga
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/redislock"
+	"github.com/redis/go-redis/v9"
+)
+
+// Saga đã kết thúc được giữ lại trong thời gian này để tra cứu
+const finishedTTL = 7 * 24 * time.Hour
+
+// Set chứa order ID của các saga chưa kết thúc
+const activeKey = "sagas:active"
+
+// Tạo key Redis dạng: "saga:123"
+func sagaKey(orderID uint64) string {
+	return fmt.Sprintf("saga:%d", orderID)
+}
+
+// Tạo key Redis dạng: "saga:123:lock"
+func lockKey(orderID uint64) string {
+	return fmt.Sprintf("saga:%d:lock", orderID)
+}
+
+// RedisStore lưu trạng thái saga trong Redis dưới dạng JSON
+type RedisStore struct {
+	Client *redis.Client
+}
+
+// Create lưu saga mới, trả về ErrExists nếu order ID đã có saga.
+// Key saga và danh sách đang chạy được ghi trong cùng MULTI/EXEC để không có saga
+// đã lưu mà không nằm trong danh sách (và không bao giờ được chạy tiếp).
+func (s *RedisStore) Create(ctx context.Context, saga Saga) error {
+	data, err := json.Marshal(saga)
+	if err != nil {
+		return fmt.Errorf("failed to encode saga: %w", err)
+	}
+
+	key := sagaKey(saga.OrderID)
+	err = s.Client.Watch(ctx, func(tx *redis.Tx) error {
+		// 1. Saga đã có (kể cả saga đã kết thúc chưa hết hạn) thì không ghi đè
+		exists, err := tx.Exists(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if exists > 0 {
+			return ErrExists
+		}
+
+		// 2. Ghi saga và thêm vào danh sách đang chạy cùng lúc
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, data, 0)
+			pipe.SAdd(ctx, activeKey, saga.OrderID)
+			return nil
+		})
+		return err
+	}, key)
+
+	// Có request khác tạo saga cùng order sau khi WATCH
+	if errors.Is(err, redis.TxFailedErr) {
+		return ErrExists
+	}
+	if err != nil && !errors.Is(err, ErrExists) {
+		return fmt.Errorf("failed to create saga: %w", err)
+	}
+	return err
+}
+
+// Save ghi đè trạng thái saga, saga đã kết thúc được bỏ khỏi danh sách đang chạy
+// và tự hết hạn sau finishedTTL
+func (s *RedisStore) Save(ctx context.Context, saga Saga) error {
+	data, err := json.Marshal(saga)
+	if err != nil {
+		return fmt.Errorf("failed to encode saga: %w", err)
+	}
+
+	txn := s.Client.TxPipeline()
+	if saga.Finished() {
+		txn.Set(ctx, sagaKey(saga.OrderID), data, finishedTTL)
+		txn.SRem(ctx, activeKey, saga.OrderID)
+	} else {
+		txn.Set(ctx, sagaKey(saga.OrderID), data, 0)
+		txn.SAdd(ctx, activeKey, saga.OrderID)
+	}
+	if _, err := txn.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to save saga: %w", err)
+	}
+	return nil
+}
+
+// Get đọc saga của một order, trả về redis.Nil nếu không có
+func (s *RedisStore) Get(ctx context.Context, orderID uint64) (Saga, error) {
+	value, err := s.Client.Get(ctx, sagaKey(orderID)).Bytes()
+	if err != nil {
+		return Saga{}, err
+	}
+
+	var saga Saga
+	if err := json.Unmarshal(value, &saga); err != nil {
+		return Saga{}, fmt.Errorf("failed to decode saga: %w", err)
+	}
+	return saga, nil
+}
+
+// Active trả về order ID của các saga chưa kết thúc
+func (s *RedisStore) Active(ctx context.Context) ([]uint64, error) {
+	members, err := s.Client.SMembers(ctx, activeKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get active sagas: %w", err)
+	}
+
+	ids := make([]uint64, 0, len(members))
+	for _, member := range members {
+		id, err := strconv.ParseUint(member, 10, 64)
+		if err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+// Lock giữ quyền chạy saga trong ttl để hai instance không cùng chạy một saga.
+// Trả về nil nếu saga đang được chạy ở nơi khác.
+func (s *RedisStore) Lock(ctx context.Context, orderID uint64, ttl time.Duration) (*redislock.Lock, error) {
+	lock, err := redislock.Acquire(ctx, s.Client, lockKey(orderID), ttl)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lock saga: %w", err)
+	}
+	return lock, nil
+}
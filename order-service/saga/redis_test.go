@@ -0,0 +1,43 @@
+package saga
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Create ghi saga cùng danh sách đang chạy, không ghi đè saga đã có
+func TestRedisStoreCreate(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	store := &RedisStore{Client: client}
+
+	if err := store.Create(ctx, Saga{OrderID: 1, Status: StatusRunning}); err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if err := store.Create(ctx, Saga{OrderID: 1, Status: StatusRunning, Error: "again"}); !errors.Is(err, ErrExists) {
+		t.Fatalf("Create existing saga error = %v, want ErrExists", err)
+	}
+	if saved, _ := store.Get(ctx, 1); saved.Error != "" {
+		t.Fatalf("existing saga overwritten: %+v", saved)
+	}
+
+	// Saga đã kết thúc vẫn còn giữ thì không được tạo lại hay đưa lại vào danh sách đang chạy
+	if err := store.Save(ctx, Saga{OrderID: 2, Status: StatusCompleted}); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if err := store.Create(ctx, Saga{OrderID: 2, Status: StatusRunning}); !errors.Is(err, ErrExists) {
+		t.Fatalf("Create finished saga error = %v, want ErrExists", err)
+	}
+
+	active, err := store.Active(ctx)
+	if err != nil || fmt.Sprint(active) != "[1]" {
+		t.Fatalf("Active = %v, %v, want [1]", active, err)
+	}
+}
@@ -0,0 +1,61 @@
+package saga
+
+import (
+	"errors"
+	"time"
+
+	"github.com/RibunLoc/microservices-learn/model"
+)
+
+// Dùng để báo lỗi khi order ID đã có saga (ID bị trùng)
+var ErrExists = errors.New("saga already exists")
+
+// Status là trạng thái của một saga
+type Status string
+
+const (
+	StatusRunning      Status = "running"      // đang chạy các bước
+	StatusCompensating Status = "compensating" // một bước lỗi, đang bù trừ các bước đã xong
+	StatusCompleted    Status = "completed"    // mọi bước thành công, đơn đã được xác nhận
+	StatusFailed       Status = "failed"       // đã bù trừ xong sau khi lỗi
+)
+
+// Step là tên một bước của saga đặt đơn
+type Step string
+
+const (
+	StepValidateCustomer Step = "validate_customer" // kiểm tra khách hàng qua user-service
+	StepCreateOrder      Step = "create_order"      // lưu đơn ở trạng thái pending
+	StepReserveInventory Step = "reserve_inventory" // giữ chỗ hàng trong kho
+	StepAuthorizePayment Step = "authorize_payment" // giữ tiền của khách
+	StepConfirmOrder     Step = "confirm_order"     // chuyển đơn sang confirmed
+)
+
+// Steps là thứ tự chạy các bước, khi bù trừ thì chạy ngược lại
+var Steps = []Step{
+	StepValidateCustomer,
+	StepCreateOrder,
+	StepReserveInventory,
+	StepAuthorizePayment,
+	StepConfirmOrder,
+}
+
+// Saga là trạng thái đặt một đơn hàng, được lưu lại sau mỗi bước
+// để chạy tiếp khi service khởi động lại
+type Saga struct {
+	OrderID   uint64               `json:"order_id"`
+	Status    Status               `json:"status"`
+	Step      Step                 `json:"step"`              // bước đang chạy hoặc đang bù trừ
+	Completed []Step               `json:"completed"`         // các bước đã xong và chưa bù trừ
+	Order     model.Order          `json:"order"`             // đơn cần đặt
+	Actor     string               `json:"actor,omitempty"`   // người đặt đơn, ghi vào lịch sử khi tạo đơn
+	Payment   *model.PaymentIntent `json:"payment,omitempty"` // lần giữ tiền, ghi vào đơn khi xác nhận
+	Error     string               `json:"error,omitempty"`   // lỗi làm saga phải bù trừ
+	StartedAt time.Time            `json:"started_at"`
+	UpdatedAt time.Time            `json:"updated_at"`
+}
+
+// Finished cho biết saga đã kết thúc (thành công hoặc đã bù trừ xong)
+func (s Saga) Finished() bool {
+	return s.Status == StatusCompleted || s.Status == StatusFailed
+}
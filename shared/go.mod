@@ -0,0 +1,3 @@
+module github.com/RibunLoc/microservices-learn/shared
+
+go 1.23.4
@@ -0,0 +1,191 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// validationError là body của response 400 khi request không khớp tài liệu
+type validationError struct {
+	Error   string       `json:"error"`
+	Details []FieldError `json:"details"`
+}
+
+/*
+Middleware kiểm tra request theo operation khớp với method và path trong tài liệu:
+ 1. Tham số path và query: thiếu tham số bắt buộc, sai kiểu hoặc không thỏa schema
+ 2. Body JSON: đọc tối đa MaxBodyBytes, kiểm tra theo schema rồi trả lại body cho handler
+
+Body quá lớn trả về 413. Request không khớp thì trả về 400 kèm danh sách lỗi, ví dụ
+{"error":"invalid request","details":[{"in":"body","field":"line_items","message":"must have at least 1 item(s)"}]}.
+Route không có trong tài liệu thì cho qua để router trả về 404/405.
+Header (như If-Match, Authorization) và body không phải JSON (như import CSV/NDJSON)
+do handler tự kiểm tra.
+*/
+func (s *Spec) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		op, pathParams := s.match(r.Method, r.URL.Path)
+		if op == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// 1. Tham số
+		errs := op.checkParams(r, pathParams)
+
+		// 2. Body
+		errs, err := op.checkBody(w, r, s.maxBodyBytes(), errs)
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, fmt.Sprintf("request body must be at most %d bytes", tooLarge.Limit), http.StatusRequestEntityTooLarge)
+			return
+		} else if err != nil {
+			fmt.Println("failed to read request body: ", err)
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if len(errs) > 0 {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			enc := json.NewEncoder(w)
+			enc.SetEscapeHTML(false) // giữ nguyên ">=" trong thông báo lỗi
+			enc.Encode(validationError{Error: "invalid request", Details: errs})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Spec) maxBodyBytes() int64 {
+	if s.MaxBodyBytes > 0 {
+		return s.MaxBodyBytes
+	}
+	return DefaultMaxBodyBytes
+}
+
+// match tìm operation khớp với request, path có nhiều đoạn cố định hơn được ưu tiên
+// (ví dụ /orders/export trước /orders/{id}), trả về cả giá trị các tham số path
+func (s *Spec) match(method, path string) (*operation, map[string]string) {
+	segments := splitPath(path)
+
+	var (
+		best       *operation
+		bestParams map[string]string
+		bestFixed  = -1
+	)
+	for _, op := range s.operations {
+		if op.method != method || len(op.segments) != len(segments) {
+			continue
+		}
+		params := make(map[string]string)
+		fixed := 0
+		ok := true
+		for i, seg := range op.segments {
+			if name, isParam := strings.CutPrefix(seg, "{"); isParam {
+				params[strings.TrimSuffix(name, "}")] = segments[i]
+				continue
+			}
+			if seg != segments[i] {
+				ok = false
+				break
+			}
+			fixed++
+		}
+		if ok && fixed > bestFixed {
+			best, bestParams, bestFixed = op, params, fixed
+		}
+	}
+	return best, bestParams
+}
+
+// checkParams kiểm tra tham số path và query, query rỗng coi như không gửi giống các handler
+func (op *operation) checkParams(r *http.Request, pathParams map[string]string) []FieldError {
+	var errs []FieldError
+	query := r.URL.Query()
+	for _, p := range op.params {
+		var raw string
+		switch p.In {
+		case "path":
+			raw = pathParams[p.Name]
+		case "query":
+			raw = query.Get(p.Name)
+		default:
+			continue
+		}
+
+		if raw == "" {
+			if p.Required {
+				errs = append(errs, FieldError{In: p.In, Field: p.Name, Message: "is required"})
+			}
+			continue
+		}
+		if p.Schema == nil {
+			continue
+		}
+		v, ok := p.Schema.parseParam(raw)
+		if !ok {
+			errs = append(errs, FieldError{In: p.In, Field: p.Name, Message: "must be " + typeName(p.Schema.Type)})
+			continue
+		}
+		errs = p.Schema.validate(p.In, p.Name, v, errs)
+	}
+	return errs
+}
+
+// checkBody kiểm tra body JSON theo schema của media type trong Content-Type,
+// không gửi Content-Type thì coi là application/json. Body được đọc (tối đa limit byte,
+// lớn hơn thì trả về *http.MaxBytesError) rồi gắn lại vào request.
+func (op *operation) checkBody(w http.ResponseWriter, r *http.Request, limit int64, errs []FieldError) ([]FieldError, error) {
+	if op.body == nil {
+		return errs, nil
+	}
+
+	mediaType := "application/json"
+	if ct := r.Header.Get("Content-Type"); ct != "" {
+		if mt, _, err := mime.ParseMediaType(ct); err == nil {
+			mediaType = mt
+		}
+	}
+	schema, ok := op.body[mediaType]
+	if !ok {
+		// Handler giải mã JSON không cần Content-Type, media type lạ vẫn kiểm tra như JSON
+		if schema, ok = op.body["application/json"]; !ok {
+			return errs, nil
+		}
+		mediaType = "application/json"
+	}
+	if mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+		return errs, nil
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+	if err != nil {
+		return errs, err
+	}
+	r.Body = io.NopCloser(bytes.NewReader(data))
+
+	if len(bytes.TrimSpace(data)) == 0 {
+		if op.bodyRequired {
+			errs = append(errs, FieldError{In: "body", Message: "request body is required"})
+		}
+		return errs, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return append(errs, FieldError{In: "body", Message: fmt.Sprintf("invalid JSON: %v", err)}), nil
+	}
+	if schema == nil {
+		return errs, nil
+	}
+	return schema.validate("body", "", v, errs), nil
+}
@@ -0,0 +1,187 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testDocument = `{
+  "openapi": "3.0.3",
+  "paths": {
+    "/orders": {
+      "get": {
+        "parameters": [
+          {"name": "status", "in": "query", "schema": {"type": "string", "enum": ["pending", "paid"]}},
+          {"name": "limit", "in": "query", "schema": {"type": "integer", "minimum": 1, "maximum": 100}}
+        ]
+      },
+      "post": {
+        "requestBody": {
+          "required": true,
+          "content": {"application/json": {"schema": {"$ref": "#/components/schemas/CreateOrder"}}}
+        }
+      }
+    },
+    "/orders/export": {
+      "get": {
+        "parameters": [{"name": "format", "in": "query", "required": true, "schema": {"type": "string"}}]
+      }
+    },
+    "/orders/import": {
+      "post": {
+        "requestBody": {"content": {"text/csv": {"schema": {"type": "string"}}}}
+      }
+    },
+    "/orders/{id}": {
+      "parameters": [{"$ref": "#/components/parameters/OrderID"}],
+      "get": {},
+      "put": {
+        "requestBody": {
+          "content": {"application/json": {"schema": {
+            "type": "object",
+            "properties": {
+              "status": {"type": "string", "enum": ["paid", "shipped"]},
+              "reason": {"type": "string", "nullable": true, "maxLength": 5},
+              "shipped_at": {"type": "string", "format": "date-time"},
+              "amount": {"oneOf": [{"type": "integer"}, {"type": "string", "pattern": "^[0-9]+$"}]}
+            }
+          }}}
+        }
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "OrderID": {"name": "id", "in": "path", "required": true, "schema": {"type": "integer", "minimum": 1}}
+    },
+    "schemas": {
+      "CreateOrder": {
+        "type": "object",
+        "required": ["customer_id", "line_items"],
+        "additionalProperties": false,
+        "properties": {
+          "customer_id": {"type": "string", "pattern": "^[0-9a-f]{24}$"},
+          "email": {"type": "string", "format": "email"},
+          "line_items": {"type": "array", "minItems": 1, "items": {"$ref": "#/components/schemas/LineItem"}}
+        }
+      },
+      "LineItem": {
+        "type": "object",
+        "required": ["item_id", "quantity"],
+        "properties": {
+          "item_id": {"type": "string", "format": "uuid"},
+          "quantity": {"type": "integer", "minimum": 1}
+        }
+      }
+    }
+  }
+}`
+
+const testItem = "00000000-0000-0000-0000-00000000000a"
+
+func TestMiddleware(t *testing.T) {
+	spec, err := Load([]byte(testDocument))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	spec.MaxBodyBytes = 256
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+		fields      []string // "in:field" của từng lỗi khi status là 400
+	}{
+		{"valid query", http.MethodGet, "/orders?status=paid&limit=10", "", "", http.StatusOK, nil},
+		{"empty query is not sent", http.MethodGet, "/orders?status=", "", "", http.StatusOK, nil},
+		{"query outside enum and range", http.MethodGet, "/orders?status=lost&limit=0", "", "", http.StatusBadRequest,
+			[]string{"query:status", "query:limit"}},
+		{"query of the wrong type", http.MethodGet, "/orders?limit=ten", "", "", http.StatusBadRequest, []string{"query:limit"}},
+		{"fixed segment wins over parameter", http.MethodGet, "/orders/export", "", "", http.StatusBadRequest, []string{"query:format"}},
+		{"path parameter from components", http.MethodGet, "/orders/0", "", "", http.StatusBadRequest, []string{"path:id"}},
+		{"path parameter of the wrong type", http.MethodGet, "/orders/abc", "", "", http.StatusBadRequest, []string{"path:id"}},
+		{"valid body", http.MethodPost, "/orders", "application/json",
+			`{"customer_id":"64b7f3a2c9e1d2a3b4c5d6e1","line_items":[{"item_id":"` + testItem + `","quantity":2}]}`, http.StatusOK, nil},
+		{"missing body", http.MethodPost, "/orders", "application/json", " ", http.StatusBadRequest, []string{"body:"}},
+		{"invalid JSON", http.MethodPost, "/orders", "application/json", `{"customer_id":`, http.StatusBadRequest, []string{"body:"}},
+		{"body errors by field", http.MethodPost, "/orders", "",
+			`{"customer_id":"x","email":"not an email","extra":1,"line_items":[{"item_id":"x","quantity":0}]}`, http.StatusBadRequest,
+			[]string{"body:customer_id", "body:email", "body:extra", "body:line_items[0].item_id", "body:line_items[0].quantity"}},
+		{"required fields and minItems", http.MethodPost, "/orders", "application/json", `{"line_items":[]}`, http.StatusBadRequest,
+			[]string{"body:customer_id", "body:line_items"}},
+		{"body too large", http.MethodPost, "/orders", "application/json", `{"customer_id":"` + strings.Repeat("a", 300) + `"}`,
+			http.StatusRequestEntityTooLarge, nil},
+		{"non-JSON body is left to the handler", http.MethodPost, "/orders/import", "text/csv", "order_id\n1\n", http.StatusOK, nil},
+		{"nullable, date-time and oneOf", http.MethodPut, "/orders/1", "application/json",
+			`{"status":"paid","reason":null,"shipped_at":"2025-01-31T14:05:00Z","amount":"100"}`, http.StatusOK, nil},
+		{"oneOf without a match", http.MethodPut, "/orders/1", "application/json",
+			`{"status":"lost","reason":"too long","shipped_at":"yesterday","amount":"1.5"}`, http.StatusBadRequest,
+			[]string{"body:amount", "body:reason", "body:shipped_at", "body:status"}},
+		{"route not in the document", http.MethodDelete, "/orders/1", "", "", http.StatusOK, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got string
+			handler := spec.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// Body đã đọc để kiểm tra vẫn còn nguyên cho handler
+				body, _ := io.ReadAll(r.Body)
+				got = string(body)
+			}))
+			req := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.status {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.status, rec.Body.String())
+			}
+			if tt.status == http.StatusOK && got != tt.body {
+				t.Fatalf("handler body = %q, want %q", got, tt.body)
+			}
+			if tt.status != http.StatusBadRequest {
+				return
+			}
+
+			var res validationError
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Fatalf("decode error response: %v", err)
+			}
+			var fields []string
+			for _, e := range res.Details {
+				fields = append(fields, e.In+":"+e.Field)
+			}
+			if res.Error != "invalid request" || fmt.Sprint(fields) != fmt.Sprint(tt.fields) {
+				t.Fatalf("errors = %+v, want fields %v", res, tt.fields)
+			}
+		})
+	}
+}
+
+func TestLoadRejectsUnknownRef(t *testing.T) {
+	doc := `{"paths":{"/orders":{"post":{"requestBody":{"content":{"application/json":{"schema":{"$ref":"#/components/schemas/Missing"}}}}}}}}`
+	if _, err := Load([]byte(doc)); err == nil {
+		t.Fatalf("Load with unknown $ref succeeded")
+	}
+}
+
+func TestServeHTTP(t *testing.T) {
+	spec, err := Load([]byte(testDocument))
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	rec := httptest.NewRecorder()
+	spec.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != testDocument || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("ServeHTTP = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+}
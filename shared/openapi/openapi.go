@@ -0,0 +1,208 @@
+// Package openapi kiểm tra request theo tài liệu OpenAPI của service,
+// dùng chung cho order-service và user-service. Mỗi service nhúng file openapi.json của mình
+// rồi gọi Load.
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+// Kích thước tối đa của body JSON mà Middleware đọc để kiểm tra khi MaxBodyBytes = 0
+const DefaultMaxBodyBytes = 1 << 20
+
+/*
+Spec là tài liệu OpenAPI đã được đọc để kiểm tra request:
+  - ServeHTTP trả về nguyên văn tài liệu (GET /openapi.json)
+  - Middleware kiểm tra tham số path, query và body JSON của request theo tài liệu
+
+Chỉ hỗ trợ phần JSON Schema mà tài liệu dùng đến: type, enum, required, properties,
+additionalProperties (true/false), items, oneOf, nullable, minimum, maximum,
+minLength, maxLength, minItems, maxItems, pattern và format uuid, email, date-time.
+*/
+type Spec struct {
+	// Body JSON lớn hơn MaxBodyBytes bị từ chối với 413 trước khi được đọc hết,
+	// 0 thì dùng DefaultMaxBodyBytes
+	MaxBodyBytes int64
+
+	document   []byte
+	operations []*operation
+}
+
+type specDoc struct {
+	Paths      map[string]pathItem `json:"paths"`
+	Components struct {
+		Schemas    map[string]*Schema    `json:"schemas"`
+		Parameters map[string]*parameter `json:"parameters"`
+	} `json:"components"`
+}
+
+type pathItem struct {
+	Parameters []*parameter  `json:"parameters"`
+	Get        *operationDoc `json:"get"`
+	Put        *operationDoc `json:"put"`
+	Post       *operationDoc `json:"post"`
+	Delete     *operationDoc `json:"delete"`
+	Patch      *operationDoc `json:"patch"`
+}
+
+type operationDoc struct {
+	Parameters  []*parameter `json:"parameters"`
+	RequestBody *struct {
+		Required bool `json:"required"`
+		Content  map[string]struct {
+			Schema *Schema `json:"schema"`
+		} `json:"content"`
+	} `json:"requestBody"`
+}
+
+type parameter struct {
+	Ref      string  `json:"$ref"`
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+// operation là một route (method + path) đã được chuẩn bị để khớp với request
+type operation struct {
+	method       string
+	segments     []string     // các đoạn của path, "{id}" là tham số
+	params       []*parameter // tham số của path item và của operation
+	bodyRequired bool
+	body         map[string]*Schema // media type -> schema của body
+}
+
+// Load đọc tài liệu OpenAPI 3 (JSON) của service, thay $ref bằng schema được tham chiếu
+// và biên dịch các pattern
+func Load(document []byte) (*Spec, error) {
+	var doc specDoc
+	if err := json.Unmarshal(document, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse openapi document: %w", err)
+	}
+
+	spec := &Spec{document: document}
+	l := linker{doc: &doc, seen: make(map[*Schema]bool)}
+	for path, item := range doc.Paths {
+		methods := map[string]*operationDoc{
+			http.MethodGet:    item.Get,
+			http.MethodPut:    item.Put,
+			http.MethodPost:   item.Post,
+			http.MethodDelete: item.Delete,
+			http.MethodPatch:  item.Patch,
+		}
+		for method, op := range methods {
+			if op == nil {
+				continue
+			}
+			o := &operation{
+				method:   method,
+				segments: splitPath(path),
+			}
+			for _, p := range append(append([]*parameter{}, item.Parameters...), op.Parameters...) {
+				p, err := l.parameter(p)
+				if err != nil {
+					return nil, fmt.Errorf("%s %s: %w", method, path, err)
+				}
+				o.params = append(o.params, p)
+			}
+			if op.RequestBody != nil {
+				o.bodyRequired = op.RequestBody.Required
+				o.body = make(map[string]*Schema, len(op.RequestBody.Content))
+				for mediaType, content := range op.RequestBody.Content {
+					s, err := l.schema(content.Schema)
+					if err != nil {
+						return nil, fmt.Errorf("%s %s: %w", method, path, err)
+					}
+					o.body[mediaType] = s
+				}
+			}
+			spec.operations = append(spec.operations, o)
+		}
+	}
+	return spec, nil
+}
+
+// ServeHTTP trả về tài liệu OpenAPI dạng JSON
+func (s *Spec) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	w.Write(s.document)
+}
+
+// linker thay $ref bằng đối tượng trong components, mỗi schema chỉ được chuẩn bị một lần
+type linker struct {
+	doc  *specDoc
+	seen map[*Schema]bool
+}
+
+func (l *linker) parameter(p *parameter) (*parameter, error) {
+	if p.Ref != "" {
+		name, ok := strings.CutPrefix(p.Ref, "#/components/parameters/")
+		target := l.doc.Components.Parameters[name]
+		if !ok || target == nil {
+			return nil, fmt.Errorf("unknown parameter %q", p.Ref)
+		}
+		p = target
+	}
+	s, err := l.schema(p.Schema)
+	if err != nil {
+		return nil, fmt.Errorf("parameter %q: %w", p.Name, err)
+	}
+	p.Schema = s
+	return p, nil
+}
+
+func (l *linker) schema(s *Schema) (*Schema, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if s.Ref != "" {
+		name, ok := strings.CutPrefix(s.Ref, "#/components/schemas/")
+		target := l.doc.Components.Schemas[name]
+		if !ok || target == nil {
+			return nil, fmt.Errorf("unknown schema %q", s.Ref)
+		}
+		s = target
+	}
+	if l.seen[s] {
+		return s, nil
+	}
+	l.seen[s] = true
+
+	if s.Pattern != "" {
+		re, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = re
+	}
+
+	var err error
+	for name, prop := range s.Properties {
+		if s.Properties[name], err = l.schema(prop); err != nil {
+			return nil, err
+		}
+	}
+	if s.Items, err = l.schema(s.Items); err != nil {
+		return nil, err
+	}
+	for i, alt := range s.OneOf {
+		if s.OneOf[i], err = l.schema(alt); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// splitPath tách path thành các đoạn, bỏ dấu "/" ở đầu và cuối
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
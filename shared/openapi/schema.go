@@ -0,0 +1,247 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/mail"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+// Schema là phần JSON Schema của OpenAPI 3 mà Spec hỗ trợ
+type Schema struct {
+	Ref                  string             `json:"$ref"`
+	Type                 string             `json:"type"`
+	Format               string             `json:"format"`
+	Enum                 []any              `json:"enum"`
+	Nullable             bool               `json:"nullable"`
+	Required             []string           `json:"required"`
+	Properties           map[string]*Schema `json:"properties"`
+	AdditionalProperties *bool              `json:"additionalProperties"` // false thì không nhận trường lạ
+	Items                *Schema            `json:"items"`
+	OneOf                []*Schema          `json:"oneOf"`
+	Minimum              *float64           `json:"minimum"`
+	Maximum              *float64           `json:"maximum"`
+	MinLength            *int               `json:"minLength"`
+	MaxLength            *int               `json:"maxLength"`
+	MinItems             *int               `json:"minItems"`
+	MaxItems             *int               `json:"maxItems"`
+	Pattern              string             `json:"pattern"`
+
+	pattern *regexp.Regexp
+}
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// FieldError là một lỗi kiểm tra, Field là tên tham số hoặc đường dẫn tới trường trong body
+// (ví dụ "line_items[0].quantity")
+type FieldError struct {
+	In      string `json:"in"` // path, query, header hoặc body
+	Field   string `json:"field,omitempty"`
+	Message string `json:"message"`
+}
+
+/*
+validate kiểm tra giá trị v (giải mã từ JSON với UseNumber) theo schema s,
+field là đường dẫn của v, các lỗi được nối vào errs:
+ 1. null chỉ hợp lệ khi nullable
+ 2. oneOf phải khớp đúng một schema
+ 3. sai kiểu thì dừng, đúng kiểu thì kiểm tra các ràng buộc của kiểu đó
+ 4. enum
+*/
+func (s *Schema) validate(in, field string, v any, errs []FieldError) []FieldError {
+	fail := func(format string, args ...any) []FieldError {
+		return append(errs, FieldError{In: in, Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	// 1. null
+	if v == nil {
+		if s.Nullable || (s.Type == "" && len(s.OneOf) == 0) {
+			return errs
+		}
+		return fail("must not be null")
+	}
+
+	// 2. oneOf
+	if len(s.OneOf) > 0 {
+		matched := 0
+		for _, alt := range s.OneOf {
+			if len(alt.validate(in, field, v, nil)) == 0 {
+				matched++
+			}
+		}
+		if matched != 1 {
+			return fail("must match exactly one of the allowed schemas")
+		}
+	}
+
+	// 3. kiểu dữ liệu
+	switch s.Type {
+	case "":
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			return fail("must be an object")
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				errs = append(errs, FieldError{In: in, Field: joinField(field, name), Message: "is required"})
+			}
+		}
+		// Duyệt theo thứ tự tên trường để lỗi trả về luôn cùng thứ tự
+		names := make([]string, 0, len(obj))
+		for name := range obj {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			value := obj[name]
+			prop, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					errs = append(errs, FieldError{In: in, Field: joinField(field, name), Message: "is not allowed"})
+				}
+				continue
+			}
+			errs = prop.validate(in, joinField(field, name), value, errs)
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			return fail("must be an array")
+		}
+		if s.MinItems != nil && len(arr) < *s.MinItems {
+			errs = fail("must have at least %d item(s)", *s.MinItems)
+		}
+		if s.MaxItems != nil && len(arr) > *s.MaxItems {
+			errs = fail("must have at most %d item(s)", *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				errs = s.Items.validate(in, fmt.Sprintf("%s[%d]", field, i), item, errs)
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			return fail("must be a string")
+		}
+		for _, msg := range s.stringErrors(str) {
+			errs = fail("%s", msg)
+		}
+	case "integer", "number":
+		num, ok := v.(json.Number)
+		if !ok {
+			return fail("must be %s", typeName(s.Type))
+		}
+		if s.Type == "integer" && !isInteger(num) {
+			return fail("must be an integer")
+		}
+		f, err := num.Float64()
+		if err != nil {
+			return fail("must be %s", typeName(s.Type))
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			errs = fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			errs = fail("must be <= %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			return fail("must be a boolean")
+		}
+	default:
+		return fail("unsupported schema type %q", s.Type)
+	}
+
+	// 4. enum
+	if len(s.Enum) > 0 && !s.allows(v) {
+		errs = fail("must be one of %v", s.Enum)
+	}
+	return errs
+}
+
+// stringErrors trả về các ràng buộc mà chuỗi str không thỏa
+func (s *Schema) stringErrors(str string) []string {
+	var msgs []string
+	n := utf8.RuneCountInString(str)
+	if s.MinLength != nil && n < *s.MinLength {
+		msgs = append(msgs, fmt.Sprintf("must be at least %d characters", *s.MinLength))
+	}
+	if s.MaxLength != nil && n > *s.MaxLength {
+		msgs = append(msgs, fmt.Sprintf("must be at most %d characters", *s.MaxLength))
+	}
+	if s.pattern != nil && !s.pattern.MatchString(str) {
+		msgs = append(msgs, fmt.Sprintf("must match pattern %s", s.Pattern))
+	}
+	switch s.Format {
+	case "uuid":
+		if !uuidPattern.MatchString(str) {
+			msgs = append(msgs, "must be a UUID")
+		}
+	case "email":
+		if addr, err := mail.ParseAddress(str); err != nil || addr.Address != str {
+			msgs = append(msgs, "must be an email address")
+		}
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, str); err != nil {
+			msgs = append(msgs, "must be an RFC 3339 date-time")
+		}
+	}
+	return msgs
+}
+
+// allows kiểm tra v có nằm trong enum, số so sánh theo dạng chuỗi
+func (s *Schema) allows(v any) bool {
+	for _, e := range s.Enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseParam chuyển giá trị chuỗi của tham số path/query về kiểu của schema để kiểm tra
+func (s *Schema) parseParam(raw string) (any, bool) {
+	switch s.Type {
+	case "integer", "number":
+		if _, err := strconv.ParseFloat(raw, 64); err != nil {
+			return nil, false
+		}
+		return json.Number(raw), true
+	case "boolean":
+		b, err := strconv.ParseBool(raw)
+		return b, err == nil
+	default:
+		return raw, true
+	}
+}
+
+// isInteger trả về true nếu số JSON là số nguyên (kể cả lớn hơn int64 như ID uint64)
+func isInteger(num json.Number) bool {
+	if _, err := strconv.ParseInt(string(num), 10, 64); err == nil {
+		return true
+	}
+	_, err := strconv.ParseUint(string(num), 10, 64)
+	return err == nil
+}
+
+// typeName trả về tên kiểu kèm mạo từ dùng trong thông báo lỗi, ví dụ "an integer"
+func typeName(t string) string {
+	switch t {
+	case "integer", "object", "array":
+		return "an " + t
+	}
+	return "a " + t
+}
+
+func joinField(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
+}
@@ -2,22 +2,35 @@ package application
 
 import (
 	"context"
+	_ "embed"
 	"fmt"
+	"net"
 	"net/http"
 	"time"
 
+	"github.com/RibunLoc/microservices-learn/shared/openapi"
+	"github.com/RibunLoc/microservices-learn/user-service/internal/grpcserver"
+	userpb "github.com/RibunLoc/microservices-learn/user-service/proto"
+	repository "github.com/RibunLoc/microservices-learn/user-service/repository/user"
 	"github.com/redis/go-redis/v9"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"google.golang.org/grpc"
 )
 
 type App struct {
 	router http.Handler
 	rdb    *redis.Client // Dùng redis để lưu session key
 	mgdb   *mongo.Database
+	spec   *openapi.Spec // tài liệu OpenAPI, dùng để kiểm tra request
 	config Config
 }
 
+// Tài liệu OpenAPI 3 của REST API, sửa route hay body của handler thì cập nhật file này
+//
+//go:embed openapi.json
+var openapiDocument []byte
+
 func New(ctx context.Context, config Config) (*App, error) {
 	mongoClient, err := mongo.Connect(context.TODO(), options.Client().ApplyURI(config.MongoURI))
 	db := mongoClient.Database("demo_db")
@@ -33,6 +46,12 @@ func New(ctx context.Context, config Config) (*App, error) {
 		mgdb:   db,
 		config: config,
 	}
+
+	// Tài liệu OpenAPI nhúng trong binary, request được kiểm tra theo tài liệu trước khi tới handler
+	if app.spec, err = openapi.Load(openapiDocument); err != nil {
+		return nil, err
+	}
+
 	app.loadRoutes()
 
 	return app, nil
@@ -44,27 +63,45 @@ func (a *App) Start(ctx context.Context) error {
 		Handler: a.router,
 	}
 
+	// gRPC server cho các service nội bộ (order-service gọi GetUserByID)
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%d", a.config.GRPCPort))
+	if err != nil {
+		return fmt.Errorf("failed to listen grpc: %w", err)
+	}
+	grpcServer := grpc.NewServer()
+	userpb.RegisterUserServiceServer(grpcServer, &grpcserver.UserGRPCHandler{
+		Repo: &repository.RedisMongo{
+			Collection: a.mgdb.Collection("users"),
+		},
+	})
+
 	fmt.Println("Starting server")
 
-	ch := make(chan error, 1)
+	ch := make(chan error, 2)
 
 	go func() {
 		err := server.ListenAndServe()
 		if err != nil {
 			ch <- fmt.Errorf("failed to start server: %w", err)
 		}
-		close(ch)
+	}()
+
+	go func() {
+		err := grpcServer.Serve(lis)
+		if err != nil {
+			ch <- fmt.Errorf("failed to start grpc server: %w", err)
+		}
 	}()
 
 	select {
 	case err := <-ch:
+		grpcServer.Stop()
 		return err
 	case <-ctx.Done():
 		timeout, cancel := context.WithTimeout(context.Background(), time.Second*10)
 		defer cancel()
 
+		grpcServer.GracefulStop()
 		return server.Shutdown(timeout)
 	}
-
-	return nil
 }
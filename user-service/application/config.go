@@ -14,13 +14,15 @@ type Config struct {
 	RedisPassword string // mật khẩu login
 	MongoURI      string
 	ServerPort    uint16 // cổng lắng nghe của backend
+	GRPCPort      uint16 // cổng lắng nghe gRPC cho các service nội bộ
 	JwtSecret     string // Secret JWT
 }
 
 func LoadConfig() Config {
 	_ = godotenv.Load()
 	cfg := Config{
-		ServerPort: 3000, // default server port
+		ServerPort: 3000,  // default server port
+		GRPCPort:   50051, // default gRPC port
 	}
 
 	if redisAddr, exist := os.LookupEnv("REDIS_ADDR"); exist {
@@ -46,6 +48,12 @@ func LoadConfig() Config {
 		}
 	}
 
+	if grpcPort, exist := os.LookupEnv("GRPC_PORT"); exist {
+		if port, err := strconv.ParseUint(grpcPort, 10, 16); err == nil {
+			cfg.GRPCPort = uint16(port)
+		}
+	}
+
 	if jwtSecret, exist := os.LookupEnv("JWT_SECRET_KEY"); exist {
 		cfg.JwtSecret = jwtSecret
 	}
@@ -15,10 +15,16 @@ func (a *App) loadRoutes() {
 
 	router.Use(middleware.Logger)
 
+	// Kiểm tra tham số và body của request theo tài liệu OpenAPI, sai thì trả về 400 kèm danh sách lỗi
+	router.Use(a.spec.Middleware)
+
 	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 	})
 
+	// Tài liệu OpenAPI của REST API
+	router.Method(http.MethodGet, "/openapi.json", a.spec)
+
 	router.Route("/register", a.loadUserRoutes)
 	router.Route("/login", a.loadUserLogin)
 	router.Route("/user", a.loadUserChangePassword)
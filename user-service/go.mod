@@ -3,6 +3,7 @@ module github.com/RibunLoc/microservices-learn/user-service
 go 1.23.4
 
 require (
+	github.com/RibunLoc/microservices-learn/shared v0.0.0
 	github.com/go-chi/chi v1.5.5
 	github.com/go-chi/chi/v5 v5.2.2
 	github.com/golang-jwt/jwt/v5 v5.3.0
@@ -30,3 +31,5 @@ require (
 	golang.org/x/text v0.27.0 // indirect
 	google.golang.org/genproto/googleapis/rpc v0.0.0-20250528174236-200df99c418a // indirect
 )
+
+replace github.com/RibunLoc/microservices-learn/shared => ../shared
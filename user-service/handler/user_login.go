@@ -37,7 +37,7 @@ func (h *UserLogin) LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := util.GenerateJWT(user.ID.Hex(), h.Repo.JwtSecret)
+	token, err := util.GenerateJWT(user.ID.Hex(), user.Role, h.Repo.JwtSecret)
 	if err != nil {
 		http.Error(w, "failed to generate token", http.StatusInternalServerError)
 		return
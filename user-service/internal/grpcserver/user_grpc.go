@@ -2,9 +2,14 @@ package grpcserver
 
 import (
 	"context"
+	"errors"
 
-	"github.com/RibunLoc/microservices-learn/user-service/proto/userpb"
+	userpb "github.com/RibunLoc/microservices-learn/user-service/proto"
 	repository "github.com/RibunLoc/microservices-learn/user-service/repository/user"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type UserGRPCHandler struct {
@@ -13,14 +18,24 @@ type UserGRPCHandler struct {
 }
 
 func (h *UserGRPCHandler) GetUserByID(ctx context.Context, req *userpb.GetUserByIDRequest) (*userpb.GetUserByIDResponse, error) {
+	// 1. user_id phải là ObjectID hex hợp lệ
+	if !primitive.IsValidObjectID(req.UserId) {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid user id %q", req.UserId)
+	}
+
+	// 2. Tìm user, trả về NotFound để client phân biệt được với lỗi hệ thống
 	user, err := h.Repo.FindByID(ctx, req.UserId)
-	if err != nil {
-		return nil, err
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, status.Errorf(codes.NotFound, "user %s not found", req.UserId)
+	} else if err != nil {
+		return nil, status.Errorf(codes.Internal, "failed to find user: %v", err)
 	}
+
 	return &userpb.GetUserByIDResponse{
 		UserId:   user.ID.Hex(),
 		Email:    user.Email,
 		Fullname: user.Fullname,
 		Role:     user.Role,
+		IsActive: user.IsActive,
 	}, nil
 }
@@ -11,10 +11,11 @@ import (
 
 //var jwtSecretKey = []byte("your-very-secret-key")
 
-// Sinh token
-func GenerateJWT(userID, jwtSecret string) (string, error) {
+// Sinh token, role được các service khác (order-service) dùng để phân quyền
+func GenerateJWT(userID, role, jwtSecret string) (string, error) {
 	clamis := jwt.MapClaims{
 		"user_id": userID,
+		"role":    role,
 		"exp":     time.Now().Add(time.Hour * 24 * 7).Unix(),
 		"iat":     time.Now().Unix(),
 	}